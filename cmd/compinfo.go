@@ -21,8 +21,14 @@ var compinfoCmd = &cobra.Command{
 			fmt.Println(compinfo.GetDockerString())
 		case "sys", "system":
 			fmt.Println(compinfo.GetSysString())
+		case "mem", "memory":
+			fmt.Println(compinfo.GetMemString())
+		case "disk":
+			fmt.Println(compinfo.GetDiskString())
+		case "net", "network":
+			fmt.Println(compinfo.GetNetString())
 		default:
-			fmt.Printf("choose infogroup from %s", "docker, load, system")
+			fmt.Printf("choose infogroup from %s", "disk, docker, load, memory, network, system")
 		}
 	},
 }
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cleardataeng/mirach/plugin/langinfo"
+
+	"github.com/spf13/cobra"
+)
+
+var langinfoCmd = &cobra.Command{
+	Use:   "langinfo",
+	Short: "Run mirach's built in langinfo plugin.",
+	Long: "mirach plugins are primarily used from within mirach, but this allows " +
+		"you to run this one directly. It will return a json string of the " +
+		"Python, npm, gem, Go, and Maven packages found under the given roots, " +
+		"or under common install locations by default.",
+	Run: func(cmd *cobra.Command, args []string) {
+		l := &langinfo.LangInfoGroup{
+			Roots:   langRoots,
+			Exclude: langExclude,
+			Budget:  langBudget,
+		}
+		l.GetInfo()
+		fmt.Println(l.String())
+	},
+}
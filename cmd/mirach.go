@@ -3,9 +3,12 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/cleardataeng/mirach/mirachlib"
 	"github.com/cleardataeng/mirach/plugin/envinfo"
+	"github.com/cleardataeng/mirach/plugin/langinfo"
+	"github.com/cleardataeng/mirach/plugin/procinfo"
 	"github.com/cleardataeng/mirach/util"
 
 	"github.com/spf13/cobra"
@@ -14,10 +17,22 @@ import (
 // flag variables
 var (
 	compInfoGroup string
+	dryRun        string
 	incText       bool
+	langBudget    time.Duration
+	langExclude   []string
+	langRoots     []string
 	level         string
 	licenseGroup  string
+	pkgFormat     string
 	pkgInfoGroup  string
+	procMax       int
+	procNames     []string
+	procRedact    bool
+	procUsers     []string
+	runTimeout    time.Duration
+	socketPath    string
+	systemdFailed bool
 	version       bool
 )
 
@@ -37,6 +52,7 @@ var MirachCmd = &cobra.Command{
 			fmt.Println(err)
 			os.Exit(1)
 		}
+		mirachlib.SetDryRun(dryRun)
 		if err := mirachlib.Start(); err != nil {
 			util.CustomOut(nil, err)
 			os.Exit(1)
@@ -52,20 +68,55 @@ func init() {
 	MirachCmd.PersistentFlags().StringVarP(&level, "loglevel", "l", "error",
 		"log level: error, info, trace")
 	MirachCmd.Flags().BoolVar(&version, "version", false, "display current mirach version")
+	MirachCmd.Flags().StringVar(&dryRun, "dry-run", "",
+		`write data to stdout or the given file instead of sending it (--dry-run=path)`)
+	MirachCmd.Flags().Lookup("dry-run").NoOptDefVal = mirachlib.DryRunStdout
 
 	MirachCmd.AddCommand(compinfoCmd)
 	compinfoCmd.Flags().StringVarP(&compInfoGroup, "infogroup", "i", "system",
-		"compinfo group to check: docker, load, system")
+		"compinfo group to check: disk, docker, load, memory, network, system")
 
 	MirachCmd.AddCommand(pkginfoCmd)
 	pkginfoCmd.Flags().StringVarP(&pkgInfoGroup, "infogroup", "i", "all",
-		"pkginfo group to check: available, available_security, installed")
+		"pkginfo group to check: advisories, available, available_security, installed")
+	pkginfoCmd.Flags().StringVar(&pkgFormat, "format", "mirach",
+		"output format: mirach, or cyclonedx or spdx for an sbom of installed packages")
 	MirachCmd.AddCommand(envinfoCmd)
 	MirachCmd.AddCommand(ebsinfoCmd)
+	MirachCmd.AddCommand(langinfoCmd)
+	langinfoCmd.Flags().StringSliceVarP(&langRoots, "root", "r", nil,
+		"directory or glob to walk; may be repeated (default common install locations)")
+	langinfoCmd.Flags().StringSliceVarP(&langExclude, "exclude", "x", nil,
+		"directory to skip; may be repeated")
+	langinfoCmd.Flags().DurationVarP(&langBudget, "budget", "b", langinfo.DefaultBudget,
+		"how long the walk may take")
 	MirachCmd.AddCommand(licenseCmd)
 	licenseCmd.Flags().BoolVarP(&incText, "include-text", "t", false,
 		"display full text for each license")
 	licenseCmd.Flags().StringVarP(&licenseGroup, "group", "g", "mirach",
 		`which licenses to display: "all", "mirach", or "other" for libraries used in mirach`)
+	MirachCmd.AddCommand(portinfoCmd)
+	MirachCmd.AddCommand(procinfoCmd)
+	procinfoCmd.Flags().StringSliceVarP(&procUsers, "user", "u", nil,
+		"only list processes of users matching this glob; may be repeated")
+	procinfoCmd.Flags().StringSliceVarP(&procNames, "name", "n", nil,
+		"only list processes with names matching this glob; may be repeated")
+	procinfoCmd.Flags().BoolVar(&procRedact, "redact", false,
+		"replace command lines with the executable")
+	procinfoCmd.Flags().IntVarP(&procMax, "max", "m", procinfo.DefaultMax,
+		"most processes to list")
+	MirachCmd.AddCommand(runCmd)
+	runCmd.Flags().StringVarP(&socketPath, "socket", "s", "",
+		"path of the daemon's control socket (default from config)")
+	runCmd.Flags().DurationVarP(&runTimeout, "timeout", "t", 10*time.Minute,
+		"how long to wait for the plugin to run")
+	MirachCmd.AddCommand(statusCmd)
+	statusCmd.Flags().StringVarP(&socketPath, "socket", "s", "",
+		"path of the daemon's control socket (default from config)")
+	MirachCmd.AddCommand(systemdinfoCmd)
+	systemdinfoCmd.Flags().BoolVar(&systemdFailed, "failed", false,
+		"only list failed units")
+	MirachCmd.AddCommand(userinfoCmd)
 	MirachCmd.AddCommand(versionCmd)
+	MirachCmd.AddCommand(vulninfoCmd)
 }
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/cleardataeng/mirach/plugin/pkginfo"
 
@@ -13,8 +14,19 @@ var pkginfoCmd = &cobra.Command{
 	Short: "Run mirach's built in pkginfo plugin.",
 	Long: "mirach plugins are primarily used from within mirach, but this allows " +
 		"you to run this one directly. It will return a json string of the " +
-		"type passed in with the -i switch or system information by default.",
+		"type passed in with the -i switch or system information by default. " +
+		"With --format cyclonedx or spdx, it instead returns a software bill of " +
+		"materials of the installed packages in that format.",
 	Run: func(cmd *cobra.Command, args []string) {
+		switch pkgFormat {
+		case pkginfo.SBOMCycloneDX, pkginfo.SBOMSPDX:
+			fmt.Println(pkginfo.SBOMString(pkgFormat))
+			return
+		case "mirach":
+		default:
+			fmt.Fprintf(os.Stderr, "choose format from %s\n", "mirach, cyclonedx, spdx")
+			os.Exit(1)
+		}
 		if pkgInfoGroup != "all" {
 			fmt.Println(pkginfo.GetInfoGroup(pkgInfoGroup))
 		} else {
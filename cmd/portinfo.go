@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cleardataeng/mirach/plugin/portinfo"
+
+	"github.com/spf13/cobra"
+)
+
+var portinfoCmd = &cobra.Command{
+	Use:   "portinfo",
+	Short: "Run mirach's built in portinfo plugin.",
+	Long: "mirach plugins are primarily used from within mirach, but this allows " +
+		"you to run this one directly. It will return a json string of the " +
+		"listening sockets, with the process and package behind each, and the " +
+		"active firewall rules. Run as root to see other users' processes.",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(portinfo.String())
+	},
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cleardataeng/mirach/plugin/procinfo"
+
+	"github.com/spf13/cobra"
+)
+
+var procinfoCmd = &cobra.Command{
+	Use:   "procinfo",
+	Short: "Run mirach's built in procinfo plugin.",
+	Long: "mirach plugins are primarily used from within mirach, but this allows " +
+		"you to run this one directly. It will return a json string of the " +
+		"running processes, optionally filtered by user and name.",
+	Run: func(cmd *cobra.Command, args []string) {
+		g := &procinfo.ProcInfoGroup{
+			Users:      procUsers,
+			Names:      procNames,
+			RedactArgs: procRedact,
+			Max:        procMax,
+		}
+		g.GetInfo()
+		fmt.Println(g.String())
+	},
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cleardataeng/mirach/mirachlib"
+
+	"github.com/spf13/cobra"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run <plugin>",
+	Short: "Have the running mirach daemon run a plugin now.",
+	Long: "Ask the running mirach daemon, over its local control socket, to run " +
+		"the named built in or custom plugin immediately and send its data. " +
+		"This exits non-zero if the plugin fails.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		msg := mirachlib.CmdMsg{
+			Cmd:  "run_plugin",
+			Args: map[string]string{"plugin": args[0]},
+		}
+		res := controlCmd(msg, runTimeout)
+		fmt.Println(res.Output)
+	},
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cleardataeng/mirach/mirachlib"
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Display the state of the running mirach daemon.",
+	Long: "Ask the running mirach daemon, over its local control socket, for " +
+		"its asset, customer, broker connection, and the schedule, last run, " +
+		"next run, and last error of each loaded plugin.",
+	Run: func(cmd *cobra.Command, args []string) {
+		res := controlCmd(mirachlib.CmdMsg{Cmd: "status"}, 30*time.Second)
+		var status mirachlib.Status
+		if err := json.Unmarshal([]byte(res.Output), &status); err != nil {
+			util.CustomOut("malformed status from daemon", err)
+			os.Exit(1)
+		}
+		printStatus(status)
+	},
+}
+
+// controlCmd sends a command to the running daemon and returns its response.
+// It exits if the daemon cannot be reached or the command fails.
+func controlCmd(msg mirachlib.CmdMsg, timeout time.Duration) mirachlib.CmdResMsg {
+	path := socketPath
+	if path == "" {
+		if dirs, err := util.GetConfDirs(); err == nil {
+			util.GetConfig(dirs)
+		}
+		path = mirachlib.ControlSocketPath()
+	}
+	res, err := mirachlib.ControlCmd(path, msg, timeout)
+	if err != nil {
+		util.CustomOut("control command to mirach daemon at "+path+" failed", err)
+		os.Exit(1)
+	}
+	if res.Status != mirachlib.CmdStatusOK {
+		fmt.Fprintln(os.Stderr, res.Output)
+		os.Exit(res.Status)
+	}
+	return res
+}
+
+func printStatus(s mirachlib.Status) {
+	fmt.Printf("asset:      %s\n", s.AssetID)
+	fmt.Printf("customer:   %s\n", s.CustomerID)
+	fmt.Printf("broker:     %s\n", s.Broker)
+	fmt.Printf("transport:  %s (%s)\n", s.Transport, s.Connection)
+	fmt.Printf("version:    %s\n", s.Version)
+	fmt.Printf("outbox:     %d queued\n\n", s.OutboxDepth)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PLUGIN\tTYPE\tSCHEDULE\tLAST RUN\tNEXT RUN\tLAST ERROR")
+	for _, p := range s.Plugins {
+		next := formatTime(p.NextRun)
+		if p.Disabled {
+			next = "disabled"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			p.Label, p.Type, p.Schedule, formatTime(p.LastRun), next, p.LastError)
+	}
+	w.Flush()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
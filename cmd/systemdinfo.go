@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cleardataeng/mirach/plugin/systemdinfo"
+
+	"github.com/spf13/cobra"
+)
+
+var systemdinfoCmd = &cobra.Command{
+	Use:   "systemdinfo",
+	Short: "Run mirach's built in systemdinfo plugin.",
+	Long: "mirach plugins are primarily used from within mirach, but this allows " +
+		"you to run this one directly. It will return a json string of the state " +
+		"of the systemd service, timer, and socket units.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if systemdFailed {
+			fmt.Println(systemdinfo.FailedString())
+			return
+		}
+		fmt.Println(systemdinfo.String())
+	},
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cleardataeng/mirach/plugin/userinfo"
+
+	"github.com/spf13/cobra"
+)
+
+var userinfoCmd = &cobra.Command{
+	Use:   "userinfo",
+	Short: "Run mirach's built in userinfo plugin.",
+	Long: "mirach plugins are primarily used from within mirach, but this allows " +
+		"you to run this one directly. It will return a json string of the local " +
+		"users and groups, password status, sudoers entries, and authorized SSH " +
+		"keys. Run as root to read /etc/shadow and the sudoers files.",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(userinfo.String())
+	},
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/cleardataeng/mirach/plugin/vulninfo"
+
+	"github.com/spf13/cobra"
+)
+
+var vulninfoCmd = &cobra.Command{
+	Use:   "vulninfo",
+	Short: "Run mirach's built in vulninfo plugin.",
+	Long: "mirach plugins are primarily used from within mirach, but this allows " +
+		"you to run this one directly. It will return a json string of the " +
+		"installed packages affected by the vulnerabilities in the OSV and OVAL " +
+		"feeds in the vulninfo directory of the configuration directories.",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(vulninfo.String())
+	},
+}
@@ -2,34 +2,88 @@
 package cron
 
 import (
+	"errors"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/robfig/cron"
 )
 
+// ErrStopped is the result of a delayed add cancelled by Stop.
+var ErrStopped = errors.New("cron stopped before the job was added")
+
 // MirachCron has robfig/cron embedded and has the only purpose of adding
 // methods to that type.
 type MirachCron struct {
 	*cron.Cron
+	mu      sync.Mutex // serializes delayed adds with Stop
+	stopped bool
+	done    chan struct{} // closed by Stop
+}
+
+// NamedJob is a function scheduled under a name by which it can be found.
+type NamedJob struct {
+	Name string
+	Func func()
+}
+
+// Run calls the job's function.
+func (j NamedJob) Run() {
+	j.Func()
 }
 
 // New returns a pointer to MirachCron with an initialized Cron.
 func New() *MirachCron {
 	c := cron.New()
-	return &MirachCron{c}
+	return &MirachCron{Cron: c, done: make(chan struct{})}
+}
+
+// Stop stops the cron and cancels the delayed adds still pending, which
+// report ErrStopped. It may be called more than once.
+func (c *MirachCron) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		return
+	}
+	c.stopped = true
+	close(c.done)
+	c.Cron.Stop()
 }
 
 // AddFuncDelayed delays the call to AddFunc by the given delay.
 // You must also pass in a chan for the purpose of returning either a
 // result or an err that the caller can chose to utilize.
 func (c *MirachCron) AddFuncDelayed(spec string, cmd func(), delay time.Duration, res chan<- interface{}) {
+	c.addJobDelayed(spec, cron.FuncJob(cmd), delay, res)
+}
+
+// AddNamedFuncDelayed is like AddFuncDelayed, but the function is scheduled as
+// a NamedJob with the given name so that it can be found with Next.
+func (c *MirachCron) AddNamedFuncDelayed(name, spec string, cmd func(), delay time.Duration, res chan<- interface{}) {
+	c.addJobDelayed(spec, NamedJob{Name: name, Func: cmd}, delay, res)
+}
+
+func (c *MirachCron) addJobDelayed(spec string, job cron.Job, delay time.Duration, res chan<- interface{}) {
 	go func() {
-		time.Sleep(delay)
-		if err := c.AddFunc(spec, cmd); err != nil {
-			res <- err
+		t := time.NewTimer(delay)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-c.done:
+			res <- ErrStopped
+			return
+		}
+		c.mu.Lock()
+		if c.stopped {
+			c.mu.Unlock()
+			res <- ErrStopped
+			return
 		}
-		res <- nil
+		err := c.AddJob(spec, job)
+		c.mu.Unlock()
+		res <- err
 	}()
 }
 
@@ -55,3 +109,14 @@ func (c *MirachCron) AddFuncRandDelay(spec string, cmd func(), res chan<- interf
 	delay := time.Duration(rand.Int63n(max-min) + min)
 	c.AddFuncDelayed(spec, cmd, delay, res)
 }
+
+// Next returns the next time the NamedJob with the given name will run.
+// It returns false if no job with that name is scheduled.
+func (c *MirachCron) Next(name string) (time.Time, bool) {
+	for _, e := range c.Entries() {
+		if j, ok := e.Job.(NamedJob); ok && j.Name == name {
+			return e.Next, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -24,7 +24,8 @@ Features
 
 Installation
 
-The easiest way to install a go package from source is using the go get command.
+Building mirach requires Go 1.18 or newer. The easiest way to install a go
+package from source is using the go get command.
 
 	go get github.com/cleardataeng/mirach
 
@@ -80,6 +81,8 @@ The file structure in any (or a combination) of these folders is:
 				- keys/
 					- ca.pem.crt (get from ClearDATA)
 					- private.pem.key (get from ClearDATA)
+		- vulninfo/
+			- *.json, *.xml (optional OSV and OVAL vulnerability feeds)
 		- config.(hcl,json,prop,yaml) (automatic, but can be customized)
 
 Here is a sample configuration in yaml:
@@ -101,6 +104,42 @@ Here is a sample configuration in yaml:
 	    compinfo-load:
 	      schedule: '@every 15s'
 	      load_delay: '30s'
+	    pkginfo:
+	      delta: true
+	      full_every: 168h
+	    langinfo:
+	      roots: [/opt, /srv, '/usr/lib/python3*']
+	      exclude: [/opt/backups]
+	      budget: 1m
+	    procinfo:
+	      users: [root, 'www-*']
+	      redact_args: true
+	      max: 500
+	    sbom:
+	      disabled: false
+	      format: spdx
+	outbox:
+	  max_age: 72h
+	  max_size: 16777216
+	metrics:
+	  listen: 127.0.0.1:9739
+	control:
+	  socket: /var/run/mirach.sock
+	transport:
+	  type: https
+	  https:
+	    url: https://collector.example.com
+	    ca_path: /etc/mirach/collector-ca.pem
+	tls:
+	  server_name: broker.example.com
+	  pins:
+	    - 'base64 SHA-256 digest of the broker public key'
+
+A custom plugin's cmd must write a single json value, such as an object, to
+standard output. That value is sent as the plugin's data as is. Output that is
+not json, or a command that exits non-zero, is logged as an error and nothing
+is sent. A scheduled run of a plugin, custom or builtin, is skipped and logged
+while its previous run is still in progress.
 
 Notes
 
@@ -108,6 +147,155 @@ mirach will need to run as a user that has permissions to list installed and
 available packages, discover computer information like host and CPU data, make
 TCP connections, and any further permissions required by your custom plugins.
 
+The broker's certificate is verified against the certificate authority in
+ca.pem. The name expected in that certificate defaults to the broker's host name
+and can be set with tls.server_name. Setting tls.pins additionally requires a
+certificate of the verified chain to have a public key matching one of the
+given base64 encoded SHA-256 digests of its subject public key info.
+Verification can be disabled with tls.insecure_skip_verify, but this leaves the
+connection open to impersonation of the broker and is logged as an error on
+every connection; with pins set, the chain is still verified against ca.pem,
+though not the broker's name.
+
+When the broker cannot be reached, collected data is queued in the outbox
+directory of the system-wide configuration directory and sent, oldest first,
+once the connection is restored. While data is queued, newly collected data is
+queued behind it, so that data always arrives in order. Queued data older than
+outbox.max_age (default 168h) is discarded, as is the oldest data once the
+queue grows larger than outbox.max_size bytes (default 64 MiB).
+
+Data is sent to the broker over MQTT unless transport.type is https. Then the
+same messages are instead posted to the collector at transport.https.url, on
+the path of the topic to which they would have been published, authenticating
+with the asset's client certificate. Data too large for a message is put to
+mirach/put/<customer id>/<asset id>/<uuid> under that url. The collector's
+certificate is verified against transport.https.ca_path, if set, or else the
+system's certificate authorities. The asset must already be registered and the
+customer id configured, and commands are only received over MQTT.
+
+In a dry run, set with the --dry-run flag or dry_run in the configuration,
+mirach neither registers nor connects to the broker. Instead, each message it
+would publish, including data, chunks, and chunk and presigned url messages, is
+written as a line of json with its topic to standard output or to the given
+file. Data that would have been put to a presigned url is written with a stub
+url. Customer and asset IDs default to "dry-run" when not configured. A dry
+run serves neither the control socket nor metrics, so it can run alongside the
+daemon. Log messages are also written to standard output, so prefer a file when
+comparing output:
+
+	mirach --dry-run=/tmp/mirach.json
+
+When plugins.builtin.pkginfo.delta is set, pkginfo sends only what changed
+since the last inventory it sent, as data of type pkginfo_delta: packages added,
+removed, and upgraded, updates newly available or no longer available, and new
+advisories. The last inventory sent is kept in pkginfo/baseline.json in the
+system-wide configuration directory. A full snapshot is sent when there is no
+baseline and every plugins.builtin.pkginfo.full_every (default 168h) to
+resynchronize. Snapshots and deltas carry a sequence number, seq, and deltas
+the number of the inventory they apply to, base_seq, so that gaps can be
+detected. Deltas carry the status of each source, and a source that failed,
+such as when the dpkg lock was held, is neither reported as removed nor
+replaced in the baseline. Advisories of packages whose changelogs could not be
+fetched are likewise kept in the baseline, while new ones are still reported
+and those of other packages, fixed since, are dropped. A dry run writes the
+full inventory and leaves the baseline untouched.
+
+The langinfo plugin inventories Python, npm, gem, Go binary, and Maven jar
+packages found under plugins.builtin.langinfo.roots, by default common install
+locations such as /usr/local, /opt, and /srv. Directories under
+plugins.builtin.langinfo.exclude are skipped, and the walk stops after
+plugins.builtin.langinfo.budget (default 2m), reporting what it found so far.
+
+The procinfo plugin lists the running processes hourly with their user, command
+line, start time, CPU and memory use, and listening sockets. Only processes of
+users matching plugins.builtin.procinfo.users and with names matching
+plugins.builtin.procinfo.names, both lists of glob patterns, are listed when
+set, at most plugins.builtin.procinfo.max (default 1000). Command lines are
+replaced by the executable, or the process name when it is unknown, unless
+plugins.builtin.procinfo.redact_args is false.
+
+The portinfo plugin reports hourly every listening TCP and UDP socket with the
+process, executable, and, where dpkg or rpm can tell, package behind it, along
+with the active nftables and iptables rules, so that exposure can be judged.
+
+The userinfo plugin reports daily the local users and groups, the status and
+aging of passwords, but never their hashes, the sudoers entries, and the
+fingerprints of each user's authorized SSH keys, for access reviews.
+
+The systemdinfo plugin reports hourly the state of every systemd service, timer,
+and socket unit: its load, active, and sub state, whether it is enabled, its
+main PID, how often it has been restarted, and when it failed. The
+systemdinfo-events plugin checks for failed units every minute and sends each
+new failure as a systemdinfo_event message of its own, so that it is known
+within a minute without waiting for the next inventory. Units are read with
+systemctl rather than systemd's D-Bus API.
+
+The sbom plugin, disabled by default, sends a software bill of materials of the
+installed packages, in CycloneDX json or, when plugins.builtin.sbom.format is
+spdx, SPDX json. Each package is identified by its package url, such as
+pkg:deb/debian/openssl@1.1.1n-0%2Bdeb11u4?arch=amd64&distro=debian-11.7, with
+the distribution and release taken from compinfo's host information. The same
+SBOM can be printed with:
+
+	mirach pkginfo --format cyclonedx
+	mirach pkginfo --format spdx
+
+The vulninfo plugin matches installed packages against the OSV (*.json) and
+OVAL (*.xml) vulnerability feeds in the vulninfo directory of the configuration
+directories, comparing versions as dpkg or rpm would, and reports each affected
+package with its installed version, the advisory or vulnerability ID, CVEs,
+severity, and fixed version. Nothing is sent when there is no feed. Feeds can be
+dropped into that directory or delivered with the update_vuln_feed command,
+which downloads the feed at its https url argument to the system-wide vulninfo
+directory as its name argument, checking its sha256 argument if given.
+
+When metrics.listen is set, mirach serves metrics about itself in the
+Prometheus text format at /metrics on that address. These include plugin runs,
+failures, recovered panics, and run time; bytes sent by path (inline, chunked,
+or presigned); the state of the connection to the broker; and the depth of the
+outbox.
+
+Commands can be sent to a running mirach on the topic mirach/cmd/<customer
+id>/<asset id>. A command is a json object such as:
+
+	{"id": "c0ffee", "cmd": "run_plugin", "args": {"plugin": "pkginfo"},
+	 "customer_id": "12345678", "asset_id": "12345678-asset-1",
+	 "nonce": "5f2b9c", "expires": 1500000060}
+
+Commands must be delivered in a signed envelope:
+
+	{"payload": "<base64 command>", "signature": "<base64 signature>"}
+
+The signature is checked against the trusted command key, a PEM encoded public
+key or certificate found at commands/keys/trusted.pem in the configuration
+directories or at commands.trusted_key_path. Ed25519 keys sign the payload
+itself; ECDSA and RSA keys sign its SHA-256 digest. A command is only accepted
+by the asset named by its customer_id and asset_id. Each nonce is accepted only
+once, and commands must expire within an hour. Nonces are kept until they
+expire in commands/nonces.json in the system-wide configuration directory, so
+commands cannot be replayed after a restart; if that file cannot be read,
+commands are refused for an hour. Without a trusted key every
+command is rejected, unless commands.allow_unsigned is set, in which case bare
+commands are accepted, still subject to these checks, and a warning is logged
+for each.
+
+The built in commands are reload_config, run_plugin, send_envinfo, status, and
+update_vuln_feed.
+Each command is answered on mirach/cmd_res/<customer id>/<asset id> with the
+command's id, an exit status (0 on success, 1 on failure, 126 for rejected
+commands, and 127 for unknown commands), and its output.
+
+The running mirach also accepts these commands, unsigned, on a local control
+socket that only its user can access: /var/run/mirach.sock on Linux and
+control.sock in the system-wide configuration directory on Windows, or the path
+in control.socket. The status subcommand uses it to display the asset, the
+connection to the broker, and the schedule, last run, next run, and last error
+of each plugin, and the run subcommand uses it to run a plugin immediately and
+send its data:
+
+	mirach status
+	mirach run pkginfo
+
 When your asset client certificates are revoked or lost, mirach will attempt to
 re-register the asset. If, at that time, the customer client certificate is
 still valid, and new asset certificate will be issue, downloaded, and used. If
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/cleardataeng/mirach/cron"
 	"github.com/cleardataeng/mirach/util"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
@@ -14,20 +16,23 @@ import (
 	"github.com/theherk/viper"
 )
 
-// CmdMsg is a json response object from IoT containing an asset command.
-type CmdMsg struct {
-	Cmd string `json:"cmd"`
-}
-
 // Asset is a Mirach IoT thing representing this machine.
 type Asset struct {
 	MirachNode
 
 	cust       *Customer
+	conn       *Connection
+	cronMu     sync.Mutex
+	cron       *cron.MirachCron // scheduler running the asset's plugins; guarded by cronMu
+	outbox     *Outbox
 	cmdHandler mqtt.MessageHandler
 	urlHandler mqtt.MessageHandler
-	cmdChan    chan CmdMsg    // channel receiving command messages
+	cmdChan    chan []byte    // channel receiving signed command envelopes
+	transport  Transport      // delivers the asset's messages
+	verifier   *cmdVerifier
 	urlChan    chan getURLMsg // channel receiving url messages
+	subsMu     sync.Mutex
+	subs       map[string]mqtt.MessageHandler // subscriptions restored on reconnect
 }
 
 func getCustomer() (*Customer, error) {
@@ -42,8 +47,12 @@ func getCustomer() (*Customer, error) {
 }
 
 // Init initializes an Asset MirachNode.
+// In a dry run the asset is neither registered nor connected to the broker.
 func (a *Asset) Init() error {
 	a.urlChan = make(chan getURLMsg, 1)
+	if output := getDryRunOutput(); output != "" {
+		return a.initDryRun(output)
+	}
 	var err error
 	a.cust, err = getCustomer()
 	if err != nil {
@@ -92,26 +101,102 @@ func (a *Asset) Init() error {
 	if err != nil {
 		return err
 	}
-	a.client, err = NewClient(viper.GetString("broker"), ca, a.privKey, a.cert, a.cust.id+":"+a.id)
+	a.outbox = newOutboxFromConfig()
+	a.cmdChan = make(chan []byte, 1)
+	a.verifier = newCmdVerifier(cmdNoncesPath())
+	switch t := viper.GetString("transport.type"); t {
+	case "", TransportMQTT:
+		if err := a.initMQTT(ca); err != nil {
+			return err
+		}
+	case TransportHTTPS:
+		// Commands are only received from the broker.
+		if a.transport, err = a.newHTTPSTransportFromConfig(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown transport.type: %s", t)
+	}
+	go a.flushOutbox()
+	return nil
+}
+
+// initMQTT connects the asset to the broker and subscribes to its commands.
+func (a *Asset) initMQTT(ca []byte) error {
+	var err error
+	a.conn = &Connection{OnConnect: func(c mqtt.Client) {
+		// The initial connection is handled below, once a.client is set.
+		if a.client != nil {
+			go func() {
+				a.resubscribe()
+				a.flushOutbox()
+			}()
+		}
+	}}
+	a.client, err = NewClient(viper.GetString("broker"), ca, a.privKey, a.cert, a.cust.id+":"+a.id, a.conn)
 	if err != nil {
 		return errors.New("asset client connection failed")
 	}
-	a.cmdChan = make(chan CmdMsg, 1)
+	a.transport = &mqttTransport{a}
 	a.cmdHandler = func(client mqtt.Client, msg mqtt.Message) {
-		res := CmdMsg{}
-		err := json.Unmarshal(msg.Payload(), &res)
-		if err != nil {
-			panic(err)
-		}
-		a.cmdChan <- res
+		a.cmdChan <- msg.Payload()
 	}
 	path := fmt.Sprintf("mirach/cmd/%s/%s", a.cust.id, a.id)
-	if subToken := a.client.Subscribe(path, 1, a.cmdHandler); subToken.Wait() && subToken.Error() != nil {
-		panic(subToken.Error())
+	if err := a.subscribe(path, a.cmdHandler); err != nil {
+		panic(err)
 	}
 	return nil
 }
 
+// ConnState returns the state of the asset's connection to the broker, or
+// for other transports whether messages can be delivered.
+func (a *Asset) ConnState() ConnState {
+	if _, ok := a.transport.(*mqttTransport); ok || a.transport == nil {
+		return a.conn.State()
+	}
+	if a.transport.Connected() {
+		return Connected
+	}
+	return Disconnected
+}
+
+// getCron returns the scheduler running the asset's plugins, or nil if they
+// have not been scheduled.
+func (a *Asset) getCron() *cron.MirachCron {
+	a.cronMu.Lock()
+	defer a.cronMu.Unlock()
+	return a.cron
+}
+
+// subscribe subscribes to the given path and records the subscription so it
+// can be restored if the connection to the broker is lost.
+func (a *Asset) subscribe(path string, handler mqtt.MessageHandler) error {
+	a.subsMu.Lock()
+	if a.subs == nil {
+		a.subs = make(map[string]mqtt.MessageHandler)
+	}
+	a.subs[path] = handler
+	a.subsMu.Unlock()
+	if subToken := a.client.Subscribe(path, 1, handler); subToken.Wait() && subToken.Error() != nil {
+		return subToken.Error()
+	}
+	return nil
+}
+
+// resubscribe re-establishes every subscription the asset holds.
+func (a *Asset) resubscribe() {
+	a.subsMu.Lock()
+	defer a.subsMu.Unlock()
+	for path, handler := range a.subs {
+		if subToken := a.client.Subscribe(path, 1, handler); subToken.Wait() && subToken.Error() != nil {
+			msg := fmt.Sprintf("failed to resubscribe to %s", path)
+			util.CustomOut(msg, subToken.Error())
+			continue
+		}
+		jww.DEBUG.Printf("resubscribed to %s", path)
+	}
+}
+
 // SubscribeURLTopic is a function to new up a subscription to s3/put/url topic
 func (a *Asset) SubscribeURLTopic() error {
 	custID := viper.GetString("customer.id")
@@ -125,10 +210,7 @@ func (a *Asset) SubscribeURLTopic() error {
 			a.urlChan <- res
 		}
 	}
-	if subToken := a.client.Subscribe(path, 1, urlHandler); subToken.Wait() && subToken.Error() != nil {
-		return subToken.Error()
-	}
-	return nil
+	return a.subscribe(path, urlHandler)
 }
 
 // Register an IoT asset using a customer's client cert.
@@ -137,7 +219,7 @@ func (a *Asset) Register(c *Customer) error {
 	if err != nil {
 		return err
 	}
-	c.client, err = NewClient(viper.GetString("broker"), ca, c.privKey, c.cert, c.id)
+	c.client, err = NewClient(viper.GetString("broker"), ca, c.privKey, c.cert, c.id, nil)
 	defer c.client.Disconnect(0)
 	c.regMsg = make(chan RegMsg, 1)
 	c.regHandler = func(client mqtt.Client, msg mqtt.Message) {
@@ -194,11 +276,34 @@ func (a *Asset) CheckRegistration(c *Customer) bool {
 	return true
 }
 
+// flushOutbox sends data queued while the broker was unreachable, including
+// data queued while it was flushing.
+func (a *Asset) flushOutbox() {
+	if a.outbox == nil {
+		return
+	}
+	send := func(t string, b []byte) error {
+		return sendData(b, t, a)
+	}
+	for {
+		n, err := a.outbox.Flush(send)
+		if err != nil {
+			util.CustomOut("outbox flush interrupted; remaining data stays queued", err)
+		}
+		if n > 0 {
+			jww.INFO.Printf("outbox: sent %d queued messages", n)
+		}
+		if err != nil || n == 0 || a.outbox.Depth() == 0 {
+			return
+		}
+	}
+}
+
 func (a *Asset) readCmds() error {
 	go func() {
 		for {
 			msg := <-a.cmdChan
-			util.CustomOut("cmd received: "+msg.Cmd, nil)
+			go a.handleCmd(msg)
 		}
 	}()
 	util.CustomOut("command channel open", nil)
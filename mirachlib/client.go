@@ -1,29 +1,167 @@
 package mirachlib
 
 import (
-	"crypto/tls"
-	"crypto/x509"
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
+	jww "github.com/spf13/jwalterweatherman"
 )
 
+const (
+	// MinReconnectDelay is the base delay before the first reconnect attempt.
+	MinReconnectDelay = time.Second
+	// MaxReconnectDelay is the upper bound of the delay between reconnect attempts.
+	MaxReconnectDelay = 5 * time.Minute
+)
+
+// ConnState is the state of a client's connection to the broker.
+type ConnState int32
+
+// Possible values of ConnState.
+const (
+	Disconnected ConnState = iota
+	Connected
+	Reconnecting
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case Connected:
+		return "connected"
+	case Reconnecting:
+		return "reconnecting"
+	}
+	return "disconnected"
+}
+
+// Connection tracks the state of an MQTT client's connection to the broker.
+// When the connection is lost it is restored with jittered exponential backoff.
+type Connection struct {
+	// OnConnect, if not nil, is called each time the client connects,
+	// including when it reconnects after losing its connection.
+	OnConnect mqtt.OnConnectHandler
+
+	state   int32
+	mu      sync.Mutex
+	backoff backoff
+}
+
+// State returns the current state of the connection.
+func (c *Connection) State() ConnState {
+	if c == nil {
+		return Disconnected
+	}
+	return ConnState(atomic.LoadInt32(&c.state))
+}
+
+func (c *Connection) setState(s ConnState) {
+	atomic.StoreInt32(&c.state, int32(s))
+}
+
+func (c *Connection) onConnect(client mqtt.Client) {
+	c.mu.Lock()
+	c.backoff.reset()
+	c.mu.Unlock()
+	c.setState(Connected)
+	jww.INFO.Println("connected to broker")
+	opts := client.OptionsReader()
+	if conf := opts.TLSConfig(); conf != nil && conf.InsecureSkipVerify {
+		jww.ERROR.Println(insecureWarning)
+	}
+	if c.OnConnect != nil {
+		c.OnConnect(client)
+	}
+}
+
+func (c *Connection) onConnectionLost(client mqtt.Client, err error) {
+	jww.ERROR.Printf("connection to broker lost: %s", err)
+	c.setState(Reconnecting)
+	go c.reconnect(client)
+}
+
+// reconnect attempts to connect the client until it succeeds.
+func (c *Connection) reconnect(client mqtt.Client) {
+	for {
+		c.mu.Lock()
+		delay := c.backoff.next()
+		c.mu.Unlock()
+		jww.INFO.Printf("reconnecting to broker in %s", delay)
+		time.Sleep(delay)
+		token := client.Connect()
+		if token.Wait() && token.Error() != nil {
+			jww.ERROR.Printf("reconnect failed: %s", token.Error())
+			continue
+		}
+		return
+	}
+}
+
+// backoff computes jittered, exponentially increasing delays.
+type backoff struct {
+	min, max time.Duration
+	attempt  uint
+	rnd      *rand.Rand
+}
+
+// newJitterSource returns a source of random numbers seeded differently on
+// each agent, so that a fleet reconnecting at once spreads out. The global
+// source of math/rand is the same on every agent until seeded.
+func newJitterSource() *rand.Rand {
+	var seed int64
+	if err := binary.Read(crand.Reader, binary.LittleEndian, &seed); err != nil {
+		seed = time.Now().UnixNano()
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// next returns the delay before the next attempt. The delay is chosen at
+// random from the upper half of the current exponential step.
+func (b *backoff) next() time.Duration {
+	min, max := b.min, b.max
+	if min <= 0 {
+		min = MinReconnectDelay
+	}
+	if max <= 0 {
+		max = MaxReconnectDelay
+	}
+	d := max
+	if b.attempt < 32 && min<<b.attempt < max && min<<b.attempt > 0 {
+		d = min << b.attempt
+	}
+	b.attempt++
+	if b.rnd == nil {
+		b.rnd = newJitterSource()
+	}
+	half := int64(d / 2)
+	return time.Duration(half + b.rnd.Int63n(half+1))
+}
+
+func (b *backoff) reset() {
+	b.attempt = 0
+}
+
 // NewClient creates and connects to a new MQTT client.
-func NewClient(broker string, ca, privKey, cert []byte, id string) (mqtt.Client, error) {
-	pair, err := tls.X509KeyPair(cert, privKey)
+// If conn is not nil it is used to track the state of the client's connection
+// and to run its OnConnect handler.
+func NewClient(broker string, ca, privKey, cert []byte, id string, conn *Connection) (mqtt.Client, error) {
+	conf, err := newTLSConfig(ca, privKey, cert)
 	if err != nil {
 		return nil, err
 	}
-	pool := x509.NewCertPool()
-	pool.AppendCertsFromPEM(ca)
-	conf := &tls.Config{
-		Certificates:       []tls.Certificate{pair},
-		RootCAs:            pool,
-		InsecureSkipVerify: true,
+	if conn == nil {
+		conn = new(Connection)
 	}
-	conf.BuildNameToCertificate()
 	options := mqtt.NewClientOptions().AddBroker(broker)
 	options.SetTLSConfig(conf)
 	options.SetClientID(id)
+	options.SetAutoReconnect(false)
+	options.SetOnConnectHandler(conn.onConnect)
+	options.SetConnectionLostHandler(conn.onConnectionLost)
 	c := mqtt.NewClient(options)
 	token := c.Connect()
 	if token.Wait() && token.Error() != nil {
@@ -0,0 +1,45 @@
+// +build unit
+
+package mirachlib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	assert := assert.New(t)
+	b := backoff{min: time.Second, max: 10 * time.Second}
+	expected := []time.Duration{1, 2, 4, 8, 10, 10}
+	for i, step := range expected {
+		d := b.next()
+		step *= time.Second
+		assert.True(d >= step/2 && d <= step, "attempt %d: %s not within [%s, %s]", i, d, step/2, step)
+	}
+	b.reset()
+	assert.True(b.next() <= time.Second, "reset starts over")
+}
+
+func TestConnStateString(t *testing.T) {
+	assert := assert.New(t)
+	var c *Connection
+	assert.Equal(Disconnected, c.State(), "nil connection is disconnected")
+	c = new(Connection)
+	c.setState(Reconnecting)
+	assert.Equal("reconnecting", c.State().String())
+}
+
+func TestBackoffJitterSeeded(t *testing.T) {
+	assert := assert.New(t)
+	a := backoff{min: time.Minute, max: time.Hour}
+	b := backoff{min: time.Minute, max: time.Hour}
+	var same int
+	for i := 0; i < 5; i++ {
+		if a.next() == b.next() {
+			same++
+		}
+	}
+	assert.True(same < 5, "each backoff has its own seed")
+}
@@ -0,0 +1,251 @@
+package mirachlib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cleardataeng/mirach/cron"
+	"github.com/cleardataeng/mirach/plugin/envinfo"
+	"github.com/cleardataeng/mirach/util"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/theherk/viper"
+)
+
+// Exit statuses reported in a CmdResMsg.
+const (
+	CmdStatusOK       = 0
+	CmdStatusFailed   = 1
+	CmdStatusRejected = 126
+	CmdStatusUnknown  = 127
+)
+
+// CmdMsg is a json response object from IoT containing an asset command.
+// CustomerID and AssetID name the asset the command is for. Nonce must be
+// unique and Expires is the unix time after which the command is no longer
+// accepted.
+type CmdMsg struct {
+	ID         string            `json:"id"`
+	Cmd        string            `json:"cmd"`
+	Args       map[string]string `json:"args,omitempty"`
+	CustomerID string            `json:"customer_id"`
+	AssetID    string            `json:"asset_id"`
+	Nonce      string            `json:"nonce"`
+	Expires    int64             `json:"expires"`
+}
+
+// CmdResMsg is the response published once a command has been handled.
+type CmdResMsg struct {
+	ID     string `json:"id"`
+	Cmd    string `json:"cmd"`
+	Status int    `json:"status"`
+	Output string `json:"output"`
+}
+
+// CmdHandler handles a command for an asset and returns the command's output.
+type CmdHandler func(asset *Asset, msg CmdMsg) (string, error)
+
+var (
+	cmdHandlersMu sync.RWMutex
+	cmdHandlers   = map[string]CmdHandler{
+		"reload_config":    reloadConfigCmd,
+		"run_plugin":       runPluginCmd,
+		"send_envinfo":     sendEnvinfoCmd,
+		"status":           statusCmd,
+		"update_vuln_feed": updateVulnFeedCmd,
+	}
+)
+
+// RegisterCmd registers a handler for the named command, replacing any handler
+// already registered under that name.
+func RegisterCmd(name string, handler CmdHandler) {
+	cmdHandlersMu.Lock()
+	defer cmdHandlersMu.Unlock()
+	cmdHandlers[name] = handler
+}
+
+func getCmdHandler(name string) (CmdHandler, bool) {
+	cmdHandlersMu.RLock()
+	defer cmdHandlersMu.RUnlock()
+	h, ok := cmdHandlers[name]
+	return h, ok
+}
+
+// dispatchCmd runs the handler registered for the command and returns the
+// response to publish.
+func dispatchCmd(asset *Asset, msg CmdMsg) CmdResMsg {
+	res := CmdResMsg{ID: msg.ID, Cmd: msg.Cmd}
+	handler, ok := getCmdHandler(msg.Cmd)
+	if !ok {
+		res.Status = CmdStatusUnknown
+		res.Output = fmt.Sprintf("unknown command: %s", msg.Cmd)
+		return res
+	}
+	out, err := handler(asset, msg)
+	res.Output = out
+	if err != nil {
+		res.Status = CmdStatusFailed
+		if res.Output == "" {
+			res.Output = err.Error()
+		}
+	}
+	return res
+}
+
+// handleCmd verifies a command envelope, dispatches the command in it, and
+// publishes the response on the asset's command response topic. Commands that
+// fail verification are rejected and never run.
+func (a *Asset) handleCmd(b []byte) {
+	var res CmdResMsg
+	msg, err := a.openCmd(b)
+	if err != nil {
+		res = CmdResMsg{ID: msg.ID, Cmd: msg.Cmd, Status: CmdStatusRejected}
+		res.Output = "command rejected: " + err.Error()
+	} else {
+		util.CustomOut("cmd received: "+msg.Cmd, nil)
+		res = dispatchCmd(a, msg)
+	}
+	if res.Status != CmdStatusOK {
+		jww.ERROR.Printf("cmd %s (%s) exited %d: %s", res.Cmd, res.ID, res.Status, res.Output)
+	}
+	if err := a.publishCmdRes(res); err != nil {
+		util.CustomOut("failed to publish command response", err)
+	}
+}
+
+// openCmd returns the command in an envelope once it has been verified
+// against the trusted command key and found to be for this asset.
+func (a *Asset) openCmd(b []byte) (CmdMsg, error) {
+	key, err := loadCmdKey()
+	if err != nil {
+		return CmdMsg{}, err
+	}
+	if key == nil {
+		jww.ERROR.Println("WARNING: commands.allow_unsigned is set; accepting unsigned command")
+	}
+	return a.verifier.open(b, key, a.cust.id, a.id)
+}
+
+func (a *Asset) publishCmdRes(res CmdResMsg) error {
+	b, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("mirach/cmd_res/%s/%s", a.cust.id, a.id)
+	return a.transport.Publish(path, b)
+}
+
+// reloadConfigCmd rereads the configuration and reschedules all plugins.
+// Plugins that run at load are not run again.
+func reloadConfigCmd(asset *Asset, msg CmdMsg) (string, error) {
+	asset.cronMu.Lock()
+	defer asset.cronMu.Unlock()
+	file, err := util.GetConfig(confDirs)
+	if err != nil {
+		return "", err
+	}
+	resetPlugins()
+	if asset.cron != nil {
+		old := asset.cron
+		asset.cron = cron.New()
+		old.Stop()
+		schedulePlugins(asset, asset.cron, false)
+	}
+	return "reloaded " + file, nil
+}
+
+// runPluginCmd runs the plugin named by the "plugin" argument and sends its data.
+func runPluginCmd(asset *Asset, msg CmdMsg) (string, error) {
+	label := msg.Args["plugin"]
+	if label == "" {
+		return "", errors.New("missing argument: plugin")
+	}
+	if err := runPlugin(asset, label); err != nil {
+		return "", err
+	}
+	if asset.ConnState() != Connected {
+		return label + ": ran; data queued in outbox until connected", nil
+	}
+	return label + ": ran and sent data", nil
+}
+
+// sendEnvinfoCmd refreshes environment information and sends it.
+func sendEnvinfoCmd(asset *Asset, msg CmdMsg) (string, error) {
+	env := new(envinfo.EnvInfoGroup)
+	env.GetInfo()
+	envinfo.Env = env
+	if err := SendData([]byte(env.String()), "envinfo", asset); err != nil {
+		return "", err
+	}
+	return "envinfo sent", nil
+}
+
+// Status describes the state of a running mirach instance.
+type Status struct {
+	AssetID     string         `json:"asset_id"`
+	CustomerID  string         `json:"customer_id"`
+	Broker      string         `json:"broker"`
+	Transport   string         `json:"transport"`
+	Connection  string         `json:"connection"`
+	Version     string         `json:"version"`
+	OutboxDepth int            `json:"outbox_depth"`
+	Plugins     []PluginStatus `json:"plugins"`
+}
+
+// PluginStatus describes the state of a loaded plugin.
+// LastRun and NextRun are zero if the plugin has not run or is not scheduled.
+type PluginStatus struct {
+	Label     string    `json:"label"`
+	Type      string    `json:"type"`
+	Schedule  string    `json:"schedule"`
+	Disabled  bool      `json:"disabled"`
+	LastRun   time.Time `json:"last_run"`
+	NextRun   time.Time `json:"next_run"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// statusCmd reports the state of this mirach instance and its plugins.
+func statusCmd(asset *Asset, msg CmdMsg) (string, error) {
+	status := Status{
+		AssetID:    asset.id,
+		CustomerID: asset.cust.id,
+		Broker:     viper.GetString("broker"),
+		Connection: asset.ConnState().String(),
+		Version:    util.Version,
+	}
+	if asset.transport != nil {
+		status.Transport = asset.transport.Name()
+	}
+	if asset.outbox != nil {
+		status.OutboxDepth = asset.outbox.Depth()
+	}
+	var plugins []Plugin
+	for _, p := range getBuiltinPlugins() {
+		plugins = append(plugins, p.Plugin)
+	}
+	for _, p := range getCustomPlugins() {
+		if !p.conflictsWithBuiltin() {
+			plugins = append(plugins, p.Plugin)
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Label < plugins[j].Label })
+	for _, p := range plugins {
+		ps := PluginStatus{
+			Label:    p.Label,
+			Type:     p.Type,
+			Schedule: p.Schedule,
+			Disabled: p.Disabled,
+		}
+		ps.LastRun, ps.LastError = metrics.lastPluginRun(p.Label)
+		if c := asset.getCron(); c != nil {
+			ps.NextRun, _ = c.Next(p.Label)
+		}
+		status.Plugins = append(status.Plugins, ps)
+	}
+	b, err := json.Marshal(status)
+	return string(b), err
+}
@@ -0,0 +1,45 @@
+// +build unit
+
+package mirachlib
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatchCmd(t *testing.T) {
+	assert := assert.New(t)
+	asset := new(Asset)
+	RegisterCmd("echo", func(a *Asset, msg CmdMsg) (string, error) {
+		return msg.Args["text"], nil
+	})
+	RegisterCmd("fail", func(a *Asset, msg CmdMsg) (string, error) {
+		return "", errors.New("it broke")
+	})
+	res := dispatchCmd(asset, CmdMsg{ID: "1", Cmd: "echo", Args: map[string]string{"text": "hi"}})
+	assert.Equal(CmdResMsg{ID: "1", Cmd: "echo", Status: CmdStatusOK, Output: "hi"}, res)
+	res = dispatchCmd(asset, CmdMsg{ID: "2", Cmd: "fail"})
+	assert.Equal(CmdStatusFailed, res.Status)
+	assert.Equal("it broke", res.Output)
+	res = dispatchCmd(asset, CmdMsg{ID: "3", Cmd: "format_disk"})
+	assert.Equal("3", res.ID, "response correlated to request")
+	assert.Equal(CmdStatusUnknown, res.Status)
+	res = dispatchCmd(asset, CmdMsg{ID: "4", Cmd: "run_plugin"})
+	assert.Equal(CmdStatusFailed, res.Status, "run_plugin requires a plugin")
+}
+
+func TestUpdateVulnFeedCmdArgs(t *testing.T) {
+	assert := assert.New(t)
+	asset := new(Asset)
+	for _, args := range []map[string]string{
+		{"url": "https://example.com/feed.json"},
+		{"url": "http://example.com/feed.json", "name": "feed.json"},
+		{"url": "https://example.com/feed.json", "name": "../feed.json"},
+		{"url": "https://example.com/feed.json", "name": "feed.sh"},
+	} {
+		res := dispatchCmd(asset, CmdMsg{Cmd: "update_vuln_feed", Args: args})
+		assert.Equal(CmdStatusFailed, res.Status, "%v", args)
+	}
+}
@@ -0,0 +1,105 @@
+package mirachlib
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/cleardataeng/mirach/util"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/theherk/viper"
+)
+
+// ControlSocketPath returns the path of the running daemon's control socket.
+// It is read from control.socket in the configuration, or defaults to the
+// platform's control socket path.
+func ControlSocketPath() string {
+	if p := viper.GetString("control.socket"); p != "" {
+		return p
+	}
+	return util.ControlSocket()
+}
+
+// serveControl serves commands to the asset on a Unix domain socket at path.
+// Each connection carries one json encoded CmdMsg and receives one CmdResMsg.
+// Commands are not signed; the socket is only accessible to its owner.
+// It runs until the listener fails.
+func serveControl(asset *Asset, path string) {
+	if err := removeStaleSocket(path); err != nil {
+		util.CustomOut("not serving control socket", err)
+		return
+	}
+	l, err := util.ListenPrivate(path)
+	if err != nil {
+		util.CustomOut("failed to open control socket", err)
+		return
+	}
+	defer l.Close()
+	jww.INFO.Printf("serving control socket on %s", path)
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			util.CustomOut("control listener stopped", err)
+			return
+		}
+		go handleControlConn(asset, conn)
+	}
+}
+
+// removeStaleSocket removes a control socket left behind by a daemon that is
+// no longer running. It refuses to remove anything that is not a socket, or a
+// socket on which another process is still listening.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("another mirach is listening on %s", path)
+	}
+	return os.Remove(path)
+}
+
+func handleControlConn(asset *Asset, conn net.Conn) {
+	defer conn.Close()
+	var msg CmdMsg
+	if err := json.NewDecoder(conn).Decode(&msg); err != nil {
+		jww.ERROR.Printf("malformed control command: %s", err)
+		return
+	}
+	jww.INFO.Printf("control command received: %s", msg.Cmd)
+	res := dispatchCmd(asset, msg)
+	if err := json.NewEncoder(conn).Encode(res); err != nil {
+		jww.ERROR.Printf("failed to write control response: %s", err)
+	}
+}
+
+// ControlCmd sends a command to the daemon listening on the control socket at
+// path and returns its response. If timeout is not zero, the exchange fails
+// once it has taken that long.
+func ControlCmd(path string, msg CmdMsg, timeout time.Duration) (CmdResMsg, error) {
+	var res CmdResMsg
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return res, err
+	}
+	defer conn.Close()
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	if err := json.NewEncoder(conn).Encode(msg); err != nil {
+		return res, err
+	}
+	err = json.NewDecoder(conn).Decode(&res)
+	return res, err
+}
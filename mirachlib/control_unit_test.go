@@ -0,0 +1,69 @@
+// +build unit
+
+package mirachlib
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControlCmd(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "mirach")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "control.sock")
+	RegisterCmd("echo", func(a *Asset, msg CmdMsg) (string, error) {
+		return msg.Args["text"], nil
+	})
+	go serveControl(new(Asset), path)
+	var res CmdResMsg
+	for i := 0; i < 50; i++ {
+		res, err = ControlCmd(path, CmdMsg{ID: "1", Cmd: "echo", Args: map[string]string{"text": "hi"}}, time.Second)
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NoError(err)
+	assert.Equal(CmdResMsg{ID: "1", Cmd: "echo", Status: CmdStatusOK, Output: "hi"}, res)
+	info, err := os.Stat(path)
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0600), info.Mode().Perm(), "socket only accessible to owner")
+	_, err = ControlCmd(filepath.Join(dir, "missing.sock"), CmdMsg{Cmd: "echo"}, time.Second)
+	assert.Error(err)
+}
+
+func TestRemoveStaleSocket(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "mirach")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "control.sock")
+	assert.NoError(removeStaleSocket(path), "nothing to remove")
+
+	assert.NoError(ioutil.WriteFile(path, []byte("config"), 0600))
+	assert.Error(removeStaleSocket(path), "not a socket")
+	_, err = os.Stat(path)
+	assert.NoError(err, "file left in place")
+	os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	assert.NoError(err)
+	assert.Error(removeStaleSocket(path), "socket in use")
+	_, err = os.Stat(path)
+	assert.NoError(err, "live socket left in place")
+
+	// Close without unlinking, as a daemon that died would leave it.
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	assert.NoError(removeStaleSocket(path))
+	_, err = os.Stat(path)
+	assert.True(os.IsNotExist(err), "stale socket removed")
+}
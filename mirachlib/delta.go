@@ -0,0 +1,154 @@
+package mirachlib
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cleardataeng/mirach/plugin/pkginfo"
+	"github.com/cleardataeng/mirach/plugin/pkginfo/parsers"
+	"github.com/cleardataeng/mirach/util"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/theherk/viper"
+)
+
+// DefaultPkginfoFullEvery is how often a full pkginfo snapshot is sent when
+// reporting deltas.
+const DefaultPkginfoFullEvery = 7 * 24 * time.Hour
+
+// pkginfoBaseline is the last pkginfo inventory sent, against which the next
+// delta is computed.
+type pkginfoBaseline struct {
+	Seq    uint64            `json:"seq"`
+	FullAt time.Time         `json:"full_at"` // when the last full snapshot was sent
+	Status pkginfo.PkgStatus `json:"status"`
+}
+
+// pkginfoMu serializes pkginfo sends, which read and replace the baseline.
+var pkginfoMu sync.Mutex
+
+func pkginfoBaselinePath() string {
+	return filepath.Join(sysConfDir, "pkginfo", "baseline.json")
+}
+
+// loadPkginfoBaseline returns the stored baseline, or nil if there is none or
+// it cannot be read.
+func loadPkginfoBaseline() *pkginfoBaseline {
+	b, err := util.ReadFile(pkginfoBaselinePath())
+	if err != nil {
+		return nil
+	}
+	base := new(pkginfoBaseline)
+	if err := json.Unmarshal(b, base); err != nil {
+		jww.ERROR.Printf("pkginfo: discarding unreadable baseline: %s", err)
+		return nil
+	}
+	return base
+}
+
+func (b *pkginfoBaseline) save() error {
+	d, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	return util.ForceWrite(pkginfoBaselinePath(), string(d))
+}
+
+// carryFailedSources returns cur with the packages of the groups it failed to
+// collect taken from base, so that the next delta is against what was last
+// known rather than reporting them all as new. When only some changelogs could
+// not be fetched, the advisories of base for the packages of those changelogs
+// are kept alongside those of cur, while the others, fixed since, are dropped.
+func carryFailedSources(base, cur *pkginfo.PkgStatus) pkginfo.PkgStatus {
+	next := *cur
+	next.Packages = map[string]map[string]parsers.LinuxPackage{}
+	for group, pkgs := range cur.Packages {
+		next.Packages[group] = pkgs
+	}
+	for _, group := range []string{"available", "available_security", "installed"} {
+		if cur.Failed(group) {
+			next.Packages[group] = base.Packages[group]
+		}
+	}
+	switch {
+	case cur.Failed("advisories"):
+		next.Advisories = base.Advisories
+	case cur.Failed("changelogs"):
+		failed := map[string]bool{}
+		for _, name := range cur.Sources["changelogs"].Packages {
+			failed[name] = true
+		}
+		next.Advisories = map[string]parsers.Advisory{}
+		for id, adv := range base.Advisories {
+			for name := range adv.Packages {
+				if failed[name] {
+					next.Advisories[id] = adv
+					break
+				}
+			}
+		}
+		for id, adv := range cur.Advisories {
+			next.Advisories[id] = adv
+		}
+	}
+	return next
+}
+
+// sendPkginfo sends pkginfo data. If plugins.builtin.pkginfo.delta is set,
+// only the changes since the last inventory sent are sent, as pkginfo_delta,
+// except for a full snapshot every plugins.builtin.pkginfo.full_every. Each
+// snapshot and delta is numbered so that gaps can be detected. The inventory
+// becomes the baseline once sent or queued in the outbox. In a dry run the
+// baseline is neither read nor replaced, as nothing reaches the backend, and
+// the inventory is written in full.
+func sendPkginfo(b []byte, t string, asset *Asset) error {
+	if !viper.GetBool("plugins.builtin.pkginfo.delta") || getDryRunOutput() != "" {
+		return SendData(b, t, asset)
+	}
+	cur := new(pkginfo.PkgStatus)
+	if err := json.Unmarshal(b, cur); err != nil || cur.OS == "" {
+		// Windows KB articles are always sent in full.
+		return SendData(b, t, asset)
+	}
+	fullEvery := DefaultPkginfoFullEvery
+	if s := viper.GetString("plugins.builtin.pkginfo.full_every"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			util.CustomOut("invalid plugins.builtin.pkginfo.full_every: using default", err)
+		} else {
+			fullEvery = d
+		}
+	}
+	pkginfoMu.Lock()
+	defer pkginfoMu.Unlock()
+	base := loadPkginfoBaseline()
+	next := &pkginfoBaseline{Seq: 1, FullAt: time.Now(), Status: *cur}
+	var (
+		msg interface{}
+		typ = t
+	)
+	if base == nil || base.Status.OS != cur.OS || time.Since(base.FullAt) >= fullEvery {
+		if base != nil {
+			next.Seq = base.Seq + 1
+		}
+		cur.Seq = next.Seq
+		msg = cur
+	} else {
+		next.Seq, next.FullAt = base.Seq+1, base.FullAt
+		delta := pkginfo.Diff(&base.Status, cur)
+		delta.Seq, delta.BaseSeq = next.Seq, base.Seq
+		msg, typ = delta, t+"_delta"
+		next.Status = carryFailedSources(&base.Status, cur)
+	}
+	d, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err := SendData(d, typ, asset); err != nil {
+		return err
+	}
+	next.Status.Seq = 0
+	return next.save()
+}
@@ -0,0 +1,114 @@
+// +build unit
+
+package mirachlib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cleardataeng/mirach/plugin/pkginfo"
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/theherk/viper"
+)
+
+func TestSendPkginfo(t *testing.T) {
+	assert := assert.New(t)
+	util.ResetTestFs()
+	util.SetFs(util.TestFs)
+	sysConfDir = "/etc/mirach/"
+	viper.Reset()
+	defer viper.Reset()
+	var buf bytes.Buffer
+	asset := &Asset{transport: &dryRunWriter{w: &buf}}
+	sent := func() (string, []byte) {
+		s := bufio.NewScanner(&buf)
+		s.Buffer(nil, 1024*1024)
+		var last dryRunRecord
+		for s.Scan() {
+			assert.NoError(json.Unmarshal(s.Bytes(), &last))
+		}
+		var msg dataMsg
+		assert.NoError(json.Unmarshal(last.Payload, &msg))
+		return msg.Type, msg.Data
+	}
+	inv := func(installed string) []byte {
+		return []byte(`{"OS":"debian","pkg_info":{"installed":` + installed + `,"available":{}}}`)
+	}
+
+	// Without delta set, data is sent as is.
+	assert.NoError(sendPkginfo(inv(`{}`), "pkginfo", asset))
+	typ, _ := sent()
+	assert.Equal("pkginfo", typ)
+
+	viper.Set("plugins.builtin.pkginfo.delta", true)
+	assert.NoError(sendPkginfo(inv(`{"ssh":{"version":"1.0"},"vim":{"version":"8.0"}}`), "pkginfo", asset))
+	typ, data := sent()
+	assert.Equal("pkginfo", typ, "full snapshot without a baseline")
+	var full pkginfo.PkgStatus
+	assert.NoError(json.Unmarshal(data, &full))
+	assert.Equal(uint64(1), full.Seq)
+
+	assert.NoError(sendPkginfo(inv(`{"ssh":{"version":"1.1"},"curl":{"version":"7.0"}}`), "pkginfo", asset))
+	typ, data = sent()
+	assert.Equal("pkginfo_delta", typ)
+	var delta pkginfo.PkgDelta
+	assert.NoError(json.Unmarshal(data, &delta))
+	assert.Equal(uint64(2), delta.Seq)
+	assert.Equal(uint64(1), delta.BaseSeq)
+	assert.Contains(delta.Added, "curl")
+	assert.Contains(delta.Removed, "vim")
+	assert.Equal("1.1", delta.Upgraded["ssh"].To.Version)
+
+	base := loadPkginfoBaseline()
+	base.FullAt = time.Now().Add(-DefaultPkginfoFullEvery)
+	assert.NoError(base.save())
+	assert.NoError(sendPkginfo(inv(`{"ssh":{"version":"1.1"}}`), "pkginfo", asset))
+	typ, data = sent()
+	assert.Equal("pkginfo", typ, "periodic full snapshot")
+	assert.NoError(json.Unmarshal(data, &full))
+	assert.Equal(uint64(3), full.Seq)
+
+	// A source that failed is kept from the baseline.
+	assert.NoError(sendPkginfo([]byte(`{"OS":"debian","pkg_info":{"installed":null,"available":{}},"sources":{"installed":{"ok":false,"error":"dpkg: locked"}}}`), "pkginfo", asset))
+	typ, data = sent()
+	assert.Equal("pkginfo_delta", typ)
+	delta = pkginfo.PkgDelta{}
+	assert.NoError(json.Unmarshal(data, &delta))
+	assert.Empty(delta.Removed)
+	assert.Equal("dpkg: locked", delta.Sources["installed"].Error)
+	assert.Contains(loadPkginfoBaseline().Status.Packages["installed"], "ssh")
+
+	// Changelogs that could not be fetched leave the other advisories reported,
+	// and keep only the advisories of their packages.
+	assert.NoError(sendPkginfo([]byte(`{"OS":"debian","pkg_info":{"installed":{"ssh":{"version":"1.1"},"curl":{"version":"7.0"}},"available":{}},"advisories":{"USN-1-1":{"cves":["CVE-2023-1"],"packages":{"ssh":"1.2"}},"USN-3-1":{"cves":["CVE-2023-3"],"packages":{"curl":"7.1"}}}}`), "pkginfo", asset))
+	sent()
+	assert.NoError(sendPkginfo([]byte(`{"OS":"debian","pkg_info":{"installed":{"ssh":{"version":"1.1"},"curl":{"version":"7.1"}},"available":{}},"advisories":{"USN-2-1":{"cves":["CVE-2023-2"],"packages":{"vim":"9.1"}}},"sources":{"changelogs":{"ok":false,"error":"apt-get changelog ssh: exit status 100","packages":["ssh"]}}}`), "pkginfo", asset))
+	typ, data = sent()
+	assert.Equal("pkginfo_delta", typ)
+	delta = pkginfo.PkgDelta{}
+	assert.NoError(json.Unmarshal(data, &delta))
+	assert.Contains(delta.Advisories, "USN-2-1", "new advisories are reported")
+	advs := loadPkginfoBaseline().Status.Advisories
+	assert.Contains(advs, "USN-1-1", "advisories whose changelog failed are kept")
+	assert.Contains(advs, "USN-2-1")
+	assert.NotContains(advs, "USN-3-1", "fixed advisories are dropped while other changelogs fail")
+
+	// A dry run neither reads nor replaces the baseline.
+	viper.Set("dry_run", DryRunStdout)
+	before, err := util.ReadFile(pkginfoBaselinePath())
+	assert.NoError(err)
+	assert.NoError(sendPkginfo(inv(`{"curl":{"version":"7.0"}}`), "pkginfo", asset))
+	typ, data = sent()
+	assert.Equal("pkginfo", typ, "full inventory in a dry run")
+	full = pkginfo.PkgStatus{}
+	assert.NoError(json.Unmarshal(data, &full))
+	assert.Zero(full.Seq)
+	after, err := util.ReadFile(pkginfoBaselinePath())
+	assert.NoError(err)
+	assert.Equal(string(before), string(after))
+}
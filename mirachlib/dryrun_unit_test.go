@@ -0,0 +1,63 @@
+// +build unit
+
+package mirachlib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/theherk/viper"
+)
+
+func TestDryRunSendData(t *testing.T) {
+	assert := assert.New(t)
+	viper.Reset()
+	defer viper.Reset()
+	viper.Set("customer.id", "cust")
+	viper.Set("asset.id", "asset")
+	var buf bytes.Buffer
+	asset := &Asset{transport: &dryRunWriter{w: &buf, custID: "cust", assetID: "asset"}}
+
+	records := func() []dryRunRecord {
+		var rs []dryRunRecord
+		s := bufio.NewScanner(&buf)
+		s.Buffer(nil, 2*MaxChunkedSize)
+		for s.Scan() {
+			var r dryRunRecord
+			assert.NoError(json.Unmarshal(s.Bytes(), &r))
+			rs = append(rs, r)
+		}
+		return rs
+	}
+
+	assert.NoError(SendData([]byte(`{"a":1}`), "test", asset))
+	rs := records()
+	assert.Len(rs, 1)
+	assert.Equal("mirach/data/cust/asset", rs[0].Topic)
+	var data dataMsg
+	assert.NoError(json.Unmarshal(rs[0].Payload, &data))
+	assert.Equal("test", data.Type)
+	assert.JSONEq(`{"a":1}`, string(data.Data))
+
+	big := []byte(`"` + strings.Repeat("x", MaxMQTTDataSize+10) + `"`)
+	assert.NoError(SendData(big, "test", asset))
+	rs = records()
+	assert.Len(rs, 3, "two chunks and the chunks message")
+	assert.True(strings.HasPrefix(rs[0].Topic, "mirach/chunk/"))
+	var chunks chunksMsg
+	assert.NoError(json.Unmarshal(rs[2].Payload, &chunks))
+	assert.Equal(2, chunks.NumChunks)
+
+	huge := []byte(`"` + strings.Repeat("x", MaxChunkedSize+10) + `"`)
+	assert.NoError(SendData(huge, "test", asset))
+	rs = records()
+	assert.Len(rs, 2, "the put body and the put message")
+	var put putHTTPMsg
+	assert.NoError(json.Unmarshal(rs[1].Payload, &put))
+	assert.Equal(rs[0].URL, put.URL)
+	assert.True(strings.HasPrefix(put.URL, "dry-run://mirach/put/cust/asset/"))
+}
@@ -0,0 +1,163 @@
+package mirachlib
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cleardataeng/mirach/util"
+
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// Paths by which SendData delivers data, used to label sent bytes.
+const (
+	sendPathInline    = "inline"
+	sendPathChunked   = "chunked"
+	sendPathPresigned = "presigned"
+)
+
+// agentMetrics holds counters describing the running agent.
+type agentMetrics struct {
+	mu             sync.Mutex
+	pluginRuns     map[string]float64
+	pluginFailures map[string]float64
+	pluginPanics   map[string]float64
+	pluginSeconds  map[string]float64
+	sentBytes      map[string]float64
+	lastRun        map[string]time.Time
+	lastErr        map[string]string
+}
+
+var metrics = newAgentMetrics()
+
+func newAgentMetrics() *agentMetrics {
+	return &agentMetrics{
+		pluginRuns:     make(map[string]float64),
+		pluginFailures: make(map[string]float64),
+		pluginPanics:   make(map[string]float64),
+		pluginSeconds:  make(map[string]float64),
+		sentBytes:      make(map[string]float64),
+		lastRun:        make(map[string]time.Time),
+		lastErr:        make(map[string]string),
+	}
+}
+
+// observePluginRun records a run of the plugin with the given label.
+func (m *agentMetrics) observePluginRun(label string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pluginRuns[label]++
+	m.pluginSeconds[label] += d.Seconds()
+	m.lastRun[label] = time.Now()
+	m.lastErr[label] = ""
+	if err != nil {
+		m.pluginFailures[label]++
+		m.lastErr[label] = err.Error()
+	}
+}
+
+// lastPluginRun returns when the plugin with the given label last ran and the
+// error, if any, with which that run ended.
+func (m *agentMetrics) lastPluginRun(label string) (time.Time, string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastRun[label], m.lastErr[label]
+}
+
+// observePluginPanic records a panic recovered while running a plugin.
+func (m *agentMetrics) observePluginPanic(label string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pluginPanics[label]++
+}
+
+// observeSent records n bytes of data sent by the given path.
+func (m *agentMetrics) observeSent(path string, n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sentBytes[path] += float64(n)
+}
+
+// writeTo writes the metrics and the state of the asset, if not nil, in the
+// Prometheus text exposition format.
+func (m *agentMetrics) writeTo(w io.Writer, asset *Asset) {
+	// Gather the asset's state first, without holding m.mu.
+	gauges := map[string]float64{}
+	if asset != nil {
+		gauges["mirach_mqtt_connected"] = 0
+		if asset.ConnState() == Connected {
+			gauges["mirach_mqtt_connected"] = 1
+		}
+		if asset.outbox != nil {
+			gauges["mirach_outbox_depth"] = float64(asset.outbox.Depth())
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writeMetric(w, "mirach_plugin_runs_total", "counter", "Plugin runs.", "plugin", m.pluginRuns)
+	writeMetric(w, "mirach_plugin_failures_total", "counter", "Plugin runs that returned an error.", "plugin", m.pluginFailures)
+	writeMetric(w, "mirach_plugin_panics_total", "counter", "Panics recovered while running plugins.", "plugin", m.pluginPanics)
+	fmt.Fprintln(w, "# HELP mirach_plugin_run_seconds Time spent running plugins.")
+	fmt.Fprintln(w, "# TYPE mirach_plugin_run_seconds summary")
+	for _, k := range sortedKeys(m.pluginRuns) {
+		fmt.Fprintf(w, "mirach_plugin_run_seconds_sum{plugin=\"%s\"} %g\n", escapeLabel(k), m.pluginSeconds[k])
+		fmt.Fprintf(w, "mirach_plugin_run_seconds_count{plugin=\"%s\"} %g\n", escapeLabel(k), m.pluginRuns[k])
+	}
+	writeMetric(w, "mirach_sent_bytes_total", "counter", "Bytes of data sent, by transport path.", "path", m.sentBytes)
+	if v, ok := gauges["mirach_mqtt_connected"]; ok {
+		writeMetric(w, "mirach_mqtt_connected", "gauge", "Whether the asset is connected to the broker.", "", map[string]float64{"": v})
+	}
+	if v, ok := gauges["mirach_outbox_depth"]; ok {
+		writeMetric(w, "mirach_outbox_depth", "gauge", "Messages queued in the outbox.", "", map[string]float64{"": v})
+	}
+}
+
+// writeMetric writes one metric family. If label is empty, the value under
+// the empty key is written without labels.
+func writeMetric(w io.Writer, name, typ, help, label string, values map[string]float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	if label == "" {
+		fmt.Fprintf(w, "%s %g\n", name, values[""])
+		return
+	}
+	for _, k := range sortedKeys(values) {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %g\n", name, label, escapeLabel(k), values[k])
+	}
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// serveMetrics serves the agent's metrics at /metrics on the given address.
+// It runs until the listener fails.
+func serveMetrics(asset *Asset, addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		metrics.writeTo(&buf, asset)
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		buf.WriteTo(w)
+	})
+	jww.INFO.Printf("serving metrics on %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		util.CustomOut("metrics listener stopped", err)
+	}
+}
@@ -0,0 +1,38 @@
+// +build unit
+
+package mirachlib
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsWriteTo(t *testing.T) {
+	assert := assert.New(t)
+	m := newAgentMetrics()
+	m.observePluginRun("pkginfo", 2*time.Second, nil)
+	m.observePluginRun("pkginfo", time.Second, errors.New("apt lock held"))
+	m.observePluginPanic("compinfo-docker")
+	m.observeSent(sendPathChunked, 100000)
+	m.observeSent(sendPathChunked, 50000)
+	asset := &Asset{conn: new(Connection)}
+	asset.conn.setState(Connected)
+	var buf bytes.Buffer
+	m.writeTo(&buf, asset)
+	out := buf.String()
+	assert.Contains(out, "# TYPE mirach_plugin_runs_total counter\n")
+	assert.Contains(out, `mirach_plugin_runs_total{plugin="pkginfo"} 2`)
+	assert.Contains(out, `mirach_plugin_failures_total{plugin="pkginfo"} 1`)
+	assert.Contains(out, `mirach_plugin_panics_total{plugin="compinfo-docker"} 1`)
+	assert.Contains(out, `mirach_plugin_run_seconds_sum{plugin="pkginfo"} 3`)
+	assert.Contains(out, `mirach_sent_bytes_total{path="chunked"} 150000`)
+	assert.Contains(out, "mirach_mqtt_connected 1\n")
+}
+
+func TestEscapeLabel(t *testing.T) {
+	assert.Equal(t, `a\"b\\c\nd`, escapeLabel("a\"b\\c\nd"))
+}
@@ -67,6 +67,11 @@ func logResChan(successMsg, errMsg string, res chan interface{}) {
 	case string:
 		jww.INFO.Println(successMsg + ": " + r.(string))
 	case error:
+		if r == cron.ErrStopped {
+			// Cancelled by a reload or shutdown.
+			jww.INFO.Println(errMsg + ": " + r.(error).Error())
+			return
+		}
 		msg := fmt.Sprintf("go routine experienced error: %s", r.(error).Error())
 		util.CustomOut(msg, r)
 	default:
@@ -85,7 +90,8 @@ func PrepResources() (*Asset, error) {
 	}
 	userConfDir, sysConfDir = confDirs[1], confDirs[2]
 	_, err = util.GetConfig(confDirs)
-	if err != nil {
+	dryRun := getDryRunOutput() != ""
+	if err != nil && !dryRun {
 		cfgType := readCfgType()
 		err := util.BlankConfig(cfgType, sysConfDir)
 		if err != nil {
@@ -93,7 +99,7 @@ func PrepResources() (*Asset, error) {
 		}
 	}
 	brokerURL := viper.GetString("broker")
-	if brokerURL == "" {
+	if brokerURL == "" && !dryRun {
 		brokerURL = readBroker()
 		viper.Set("broker", brokerURL)
 		if err = viper.WriteConfig(); err != nil {
@@ -113,17 +119,28 @@ func PrepResources() (*Asset, error) {
 func RunLoop(asset *Asset) {
 	signalChannel := make(chan os.Signal, 1)
 	signal.Notify(signalChannel, os.Interrupt)
-	cron := cron.New()
+	c := cron.New()
+	asset.cronMu.Lock()
+	asset.cron = c
+	asset.cronMu.Unlock()
 	if envinfo.Env == nil {
 		envinfo.Env = new(envinfo.EnvInfoGroup)
 		envinfo.Env.GetInfo()
 	}
-	handlePlugins(asset, cron)
+	// A dry run may share the host with a running daemon, whose control socket
+	// and metrics port it must leave alone.
+	if getDryRunOutput() == "" {
+		if addr := viper.GetString("metrics.listen"); addr != "" {
+			go serveMetrics(asset, addr)
+		}
+		go serveControl(asset, ControlSocketPath())
+	}
+	handlePlugins(asset, c)
 	handleCommands(asset)
 	for _ = range signalChannel {
 		// sig is a ^c, handle it
 		jww.DEBUG.Println("SIGINT, stopping")
-		cron.Stop()
+		asset.getCron().Stop()
 		os.Exit(1)
 	}
 }
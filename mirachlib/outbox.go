@@ -0,0 +1,219 @@
+package mirachlib
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/spf13/afero"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/theherk/viper"
+)
+
+const (
+	// DefaultOutboxMaxAge is the age after which queued data is discarded.
+	DefaultOutboxMaxAge = 7 * 24 * time.Hour
+	// DefaultOutboxMaxSize is the total size in bytes of queued data retained.
+	DefaultOutboxMaxSize = 64 * 1024 * 1024
+)
+
+// Outbox is a durable queue of data that could not be sent to the broker.
+// Each entry is a single file in dir, named so that lexical order is the order
+// in which entries were queued. All file operations go through util.Fs.
+type Outbox struct {
+	depth   int64 // entries queued; first for 64-bit alignment of atomic access
+	dir     string
+	maxAge  time.Duration
+	maxSize int64
+
+	mu      sync.Mutex // guards the files of dir and seq
+	flushMu sync.Mutex // held for the length of a flush
+	seq     int
+}
+
+type outboxEntry struct {
+	Type string `json:"type"`
+	Data []byte `json:"data"`
+}
+
+// NewOutbox returns an Outbox storing entries in the given directory, which
+// may already hold entries.
+func NewOutbox(dir string, maxSize int64, maxAge time.Duration) *Outbox {
+	o := &Outbox{dir: dir, maxAge: maxAge, maxSize: maxSize}
+	names, _ := o.list()
+	o.depth = int64(len(names))
+	return o
+}
+
+// newOutboxFromConfig returns an Outbox in the system configuration directory
+// using limits from configuration or their defaults.
+func newOutboxFromConfig() *Outbox {
+	maxSize := int64(viper.GetInt("outbox.max_size"))
+	if maxSize <= 0 {
+		maxSize = DefaultOutboxMaxSize
+	}
+	maxAge, err := time.ParseDuration(viper.GetString("outbox.max_age"))
+	if err != nil || maxAge <= 0 {
+		if viper.GetString("outbox.max_age") != "" {
+			util.CustomOut("invalid outbox.max_age: using default", err)
+		}
+		maxAge = DefaultOutboxMaxAge
+	}
+	return NewOutbox(filepath.Join(sysConfDir, "outbox"), maxSize, maxAge)
+}
+
+// Enqueue writes data of the given type to the outbox, then discards the
+// oldest entries until the outbox is within its limits.
+func (o *Outbox) Enqueue(t string, b []byte) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	e, err := json.Marshal(outboxEntry{Type: t, Data: b})
+	if err != nil {
+		return err
+	}
+	o.seq++
+	name := fmt.Sprintf("%020d-%06d.json", time.Now().UnixNano(), o.seq%1000000)
+	if err := util.ForceWrite(filepath.Join(o.dir, name), string(e)); err != nil {
+		return err
+	}
+	return o.prune()
+}
+
+// Flush sends queued entries, oldest first, using the given function.
+// Entries are removed once sent. Flushing stops at the first failure, leaving
+// that entry and all newer ones queued. The number of entries sent is returned.
+// Only one flush runs at a time, but o.mu is not held while sending, so that
+// data can be queued and the depth read during a slow flush.
+func (o *Outbox) Flush(send func(t string, b []byte) error) (int, error) {
+	o.flushMu.Lock()
+	defer o.flushMu.Unlock()
+	o.mu.Lock()
+	err := o.prune()
+	var names []string
+	if err == nil {
+		names, err = o.list()
+	}
+	o.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, name := range names {
+		path := filepath.Join(o.dir, name)
+		e, err := readOutboxEntry(path)
+		if os.IsNotExist(err) {
+			// Pruned since it was listed.
+			continue
+		}
+		if err != nil {
+			jww.ERROR.Printf("outbox: discarding unreadable entry %s: %s", name, err)
+			o.remove(path)
+			continue
+		}
+		if err := send(e.Type, e.Data); err != nil {
+			return n, err
+		}
+		if err := o.remove(path); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// remove removes a queued entry, which may already have been pruned.
+func (o *Outbox) remove(path string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if err := util.Fs.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	atomic.AddInt64(&o.depth, -1)
+	return nil
+}
+
+// Depth returns the number of entries currently queued. It does not wait for
+// the outbox lock, so it can be read while data is being queued or flushed.
+func (o *Outbox) Depth() int {
+	return int(atomic.LoadInt64(&o.depth))
+}
+
+// list returns the names of queued entries in the order they were queued.
+func (o *Outbox) list() ([]string, error) {
+	if exists, _ := afero.DirExists(util.Fs, o.dir); !exists {
+		return nil, nil
+	}
+	infos, err := afero.ReadDir(util.Fs, o.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, fi := range infos {
+		if !fi.IsDir() && strings.HasSuffix(fi.Name(), ".json") {
+			names = append(names, fi.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// prune removes entries older than maxAge, then removes the oldest entries
+// until the total size of the outbox is no larger than maxSize, and recounts
+// the depth. Callers must hold o.mu.
+func (o *Outbox) prune() error {
+	names, err := o.list()
+	if err != nil {
+		return err
+	}
+	var (
+		kept  []string
+		sizes = map[string]int64{}
+		total int64
+	)
+	for _, name := range names {
+		path := filepath.Join(o.dir, name)
+		fi, err := util.Fs.Stat(path)
+		if err != nil {
+			continue
+		}
+		if o.maxAge > 0 && time.Since(fi.ModTime()) > o.maxAge {
+			jww.INFO.Printf("outbox: discarding expired entry %s", name)
+			util.Fs.Remove(path)
+			continue
+		}
+		kept = append(kept, name)
+		sizes[name] = fi.Size()
+		total += fi.Size()
+	}
+	defer func() { atomic.StoreInt64(&o.depth, int64(len(kept))) }()
+	for len(kept) > 0 && o.maxSize > 0 && total > o.maxSize {
+		jww.ERROR.Printf("outbox: size limit reached; discarding entry %s", kept[0])
+		if err := util.Fs.Remove(filepath.Join(o.dir, kept[0])); err != nil {
+			return err
+		}
+		total -= sizes[kept[0]]
+		kept = kept[1:]
+	}
+	return nil
+}
+
+func readOutboxEntry(path string) (outboxEntry, error) {
+	var e outboxEntry
+	b, err := util.ReadFile(path)
+	if err != nil {
+		return e, err
+	}
+	err = json.Unmarshal(b, &e)
+	return e, err
+}
@@ -0,0 +1,132 @@
+// +build unit
+
+package mirachlib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxFlushInOrder(t *testing.T) {
+	assert := assert.New(t)
+	util.ResetTestFs()
+	util.SetFs(util.TestFs)
+	o := NewOutbox("/etc/mirach/outbox", DefaultOutboxMaxSize, DefaultOutboxMaxAge)
+	for _, d := range []string{`{"n":1}`, `{"n":2}`, `{"n":3}`} {
+		assert.Nil(o.Enqueue("pkginfo", []byte(d)))
+	}
+	assert.Equal(3, o.Depth())
+	assert.Equal(3, NewOutbox("/etc/mirach/outbox", DefaultOutboxMaxSize, DefaultOutboxMaxAge).Depth(),
+		"entries already queued are counted")
+	var sent []string
+	n, err := o.Flush(func(typ string, b []byte) error {
+		assert.Equal("pkginfo", typ)
+		sent = append(sent, string(b))
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(3, n)
+	assert.Equal([]string{`{"n":1}`, `{"n":2}`, `{"n":3}`}, sent)
+	assert.Equal(0, o.Depth(), "flushed entries removed")
+}
+
+func TestOutboxFlushStopsOnFailure(t *testing.T) {
+	assert := assert.New(t)
+	util.ResetTestFs()
+	util.SetFs(util.TestFs)
+	o := NewOutbox("/etc/mirach/outbox", DefaultOutboxMaxSize, DefaultOutboxMaxAge)
+	o.Enqueue("a", []byte("1"))
+	o.Enqueue("b", []byte("2"))
+	n, err := o.Flush(func(typ string, b []byte) error {
+		if typ == "b" {
+			return errors.New("broker gone")
+		}
+		return nil
+	})
+	assert.NotNil(err)
+	assert.Equal(1, n)
+	assert.Equal(1, o.Depth(), "failed entry stays queued")
+}
+
+func TestOutboxLimits(t *testing.T) {
+	assert := assert.New(t)
+	util.ResetTestFs()
+	util.SetFs(util.TestFs)
+	dir := "/etc/mirach/outbox"
+	o := NewOutbox(dir, 200, time.Hour)
+	o.Enqueue("old", []byte("expired"))
+	names, _ := o.list()
+	past := time.Now().Add(-2 * time.Hour)
+	util.Fs.Chtimes(filepath.Join(dir, names[0]), past, past)
+	o.Enqueue("new", []byte("fresh"))
+	assert.Equal(1, o.Depth(), "expired entry pruned")
+	for i := 0; i < 5; i++ {
+		o.Enqueue("filler", []byte("0123456789"))
+	}
+	names, _ = o.list()
+	var total int64
+	for _, name := range names {
+		fi, _ := util.Fs.Stat(filepath.Join(dir, name))
+		total += fi.Size()
+	}
+	assert.True(total <= 200, "outbox within size limit")
+	last, _ := afero.ReadFile(util.Fs, filepath.Join(dir, names[len(names)-1]))
+	assert.Contains(string(last), "filler", "newest entry kept")
+}
+
+func TestOutboxEnqueueDuringFlush(t *testing.T) {
+	assert := assert.New(t)
+	util.ResetTestFs()
+	util.SetFs(util.TestFs)
+	o := NewOutbox("/etc/mirach/outbox", DefaultOutboxMaxSize, DefaultOutboxMaxAge)
+	o.Enqueue("a", []byte("1"))
+	o.Enqueue("b", []byte("2"))
+	var sent []string
+	n, err := o.Flush(func(typ string, b []byte) error {
+		sent = append(sent, typ)
+		if typ == "a" {
+			// Would deadlock if the outbox were locked while sending.
+			assert.Nil(o.Enqueue("c", []byte("3")))
+			assert.Equal(3, o.Depth())
+		}
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(2, n)
+	assert.Equal([]string{"a", "b"}, sent, "entries queued during a flush wait for the next")
+	assert.Equal(1, o.Depth())
+}
+
+func TestSendDataBehindOutbox(t *testing.T) {
+	assert := assert.New(t)
+	util.ResetTestFs()
+	util.SetFs(util.TestFs)
+	var buf bytes.Buffer
+	asset := &Asset{
+		transport: &dryRunWriter{w: &buf},
+		outbox:    NewOutbox("/etc/mirach/outbox", DefaultOutboxMaxSize, DefaultOutboxMaxAge),
+	}
+	assert.Nil(asset.outbox.Enqueue("first", []byte(`{"n":1}`)))
+	assert.Nil(SendData([]byte(`{"n":2}`), "second", asset))
+	var types []string
+	s := bufio.NewScanner(&buf)
+	for s.Scan() {
+		var rec dryRunRecord
+		assert.Nil(json.Unmarshal(s.Bytes(), &rec))
+		var msg dataMsg
+		assert.Nil(json.Unmarshal(rec.Payload, &msg))
+		types = append(types, msg.Type)
+	}
+	assert.Equal([]string{"first", "second"}, types, "new data sent behind the backlog")
+	assert.Equal(0, asset.outbox.Depth())
+}
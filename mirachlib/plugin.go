@@ -1,22 +1,29 @@
 package mirachlib
 
 import (
-	"bytes"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
 	"os/exec"
 	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cleardataeng/mirach/cron"
 	"github.com/cleardataeng/mirach/plugin/compinfo"
 	"github.com/cleardataeng/mirach/plugin/ebsinfo"
 	"github.com/cleardataeng/mirach/plugin/envinfo"
+	"github.com/cleardataeng/mirach/plugin/langinfo"
 	"github.com/cleardataeng/mirach/plugin/pkginfo"
+	"github.com/cleardataeng/mirach/plugin/portinfo"
+	"github.com/cleardataeng/mirach/plugin/procinfo"
+	"github.com/cleardataeng/mirach/plugin/systemdinfo"
+	"github.com/cleardataeng/mirach/plugin/userinfo"
+	"github.com/cleardataeng/mirach/plugin/vulninfo"
 	"github.com/cleardataeng/mirach/util"
 
 	"github.com/google/uuid"
@@ -41,6 +48,8 @@ type chunksMsg struct {
 type BuiltinPlugin struct {
 	Plugin  `mapstructure:",squash"`
 	StrFunc func() string
+	// SendFunc, if not nil, sends the plugin's data in place of SendData.
+	SendFunc func(b []byte, t string, asset *Asset) error
 }
 
 // CustomPlugin is a regularly run command that collects data.
@@ -79,60 +88,111 @@ type putHTTPMsg struct {
 }
 
 var (
+	pluginsMu      sync.Mutex // guards customPlugins and builtinPlugins
 	customPlugins  map[string]CustomPlugin
 	builtinPlugins map[string]BuiltinPlugin
 )
 
-// Run will run custom plugin and publishes its results.
-func (p *CustomPlugin) Run(asset *Asset) func() {
-	pCmd := p.Cmd
-	pLabel := p.Label
-	pType := p.Type
+// resetPlugins discards the loaded plugin configuration, so that it is read
+// again from the configuration when next needed. Maps already returned by
+// getBuiltinPlugins and getCustomPlugins are not changed.
+func resetPlugins() {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+	builtinPlugins, customPlugins = nil, nil
+}
+
+// skipOverlapping returns f made to do nothing, but log, when called while a
+// previous call is still running. Cron starts each scheduled run regardless of
+// the last, so runs of a plugin that hangs would otherwise pile up.
+func skipOverlapping(label string, f func()) func() {
+	var running int32
 	return func() {
-		jww.INFO.Printf("%s: running", pLabel)
-		cmd := exec.Command(pCmd)
-		stdout, err := cmd.StdoutPipe()
-		if err != nil {
-			jww.ERROR.Println(err)
-		}
-		if err := cmd.Start(); err != nil {
-			jww.ERROR.Println(err)
-		}
-		var d []byte
-		if err := json.NewDecoder(stdout).Decode(&d); err != nil {
-			jww.ERROR.Println(err)
+		if !atomic.CompareAndSwapInt32(&running, 0, 1) {
+			jww.WARN.Printf("%s: skipping run: previous run still in progress", label)
+			return
 		}
-		err = cmd.Wait()
-		if err := SendData(d, pType, asset); err != nil {
+		defer atomic.StoreInt32(&running, 0)
+		f()
+	}
+}
+
+// Run will run custom plugin and publishes its results. A run is skipped
+// while the previous one is still in progress.
+func (p *CustomPlugin) Run(asset *Asset) func() {
+	plug := *p
+	return skipOverlapping(plug.Label, func() {
+		if err := plug.Exec(asset); err != nil {
 			jww.ERROR.Println(err)
 		}
+	})
+}
+
+// Exec runs the custom plugin once and publishes its results. The command's
+// output must be a single json value, which is sent unchanged.
+func (p *CustomPlugin) Exec(asset *Asset) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.observePluginRun(p.Label, time.Since(start), err)
+	}()
+	jww.INFO.Printf("%s: running", p.Label)
+	cmd := exec.Command(p.Cmd)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	var d json.RawMessage
+	if err := json.NewDecoder(stdout).Decode(&d); err != nil {
+		cmd.Wait()
+		return fmt.Errorf("%s: invalid output: %s", p.Label, err)
 	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("%s: %s", p.Label, err)
+	}
+	return SendData(d, p.Type, asset)
 }
 
-// Run will run an internal function and publish its results.
+// Run will run an internal function and publish its results. A run is
+// skipped while the previous one is still in progress.
 func (p *BuiltinPlugin) Run(asset *Asset) func() {
-	pFunc := p.StrFunc
-	pLabel := p.Label
-	pType := p.Type
-	return func() {
-		defer func() {
-			if r := recover(); r != nil {
-				if reflect.TypeOf(r).String() == "plugin.Exception" {
-					jww.TRACE.Println(r)
-					return
-				}
-				jww.ERROR.Println(r)
-			}
-		}()
-		jww.INFO.Printf("%s: running", pLabel)
-		d := pFunc()
-		if err := SendData([]byte(d), pType, asset); err != nil {
+	plug := *p
+	return skipOverlapping(plug.Label, func() {
+		if err := plug.Exec(asset); err != nil {
 			jww.ERROR.Println(err)
 		}
+	})
+}
+
+// Exec runs the internal function once and publishes its results.
+// A panic in the function is recovered and returned as an error, unless it is
+// a plugin.Exception, which indicates an expected condition.
+func (p *BuiltinPlugin) Exec(asset *Asset) (err error) {
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			if reflect.TypeOf(r).String() == "plugin.Exception" {
+				jww.TRACE.Println(r)
+			} else {
+				metrics.observePluginPanic(p.Label)
+				err = fmt.Errorf("%s: %v", p.Label, r)
+			}
+		}
+		metrics.observePluginRun(p.Label, time.Since(start), err)
+	}()
+	jww.INFO.Printf("%s: running", p.Label)
+	d := p.StrFunc()
+	if p.SendFunc != nil {
+		return p.SendFunc([]byte(d), p.Type, asset)
 	}
+	return SendData([]byte(d), p.Type, asset)
 }
 
-func (p *Plugin) loadPlugin(cron *cron.MirachCron, f func()) {
+// loadPlugin schedules f on cron. If atLoad is true, as when mirach starts, a
+// plugin set to run at load is also run once its load delay has passed.
+func (p *Plugin) loadPlugin(cron *cron.MirachCron, f func(), atLoad bool) {
 	if p.Disabled {
 		jww.INFO.Printf("%s: disabled, skipping", p.Label)
 		return
@@ -159,9 +219,9 @@ func (p *Plugin) loadPlugin(cron *cron.MirachCron, f func()) {
 	}
 	jww.INFO.Println(addMsg)
 	res := make(chan interface{})
-	cron.AddFuncDelayed(p.Schedule, f, delay, res)
+	cron.AddNamedFuncDelayed(p.Label, p.Schedule, f, delay, res)
 	go logResChan(successMsg, errorMsg, res)
-	if p.RunAtLoad {
+	if atLoad && p.RunAtLoad {
 		pLabel := p.Label
 		go func() {
 			jww.TRACE.Printf("%s: run_at_load true; run when loaded then resume schedule", pLabel)
@@ -175,8 +235,18 @@ func (p *Plugin) loadPlugin(cron *cron.MirachCron, f func()) {
 }
 
 func getBuiltinPlugins() map[string]BuiltinPlugin {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
 	if len(builtinPlugins) == 0 {
 		builtinPlugins = map[string]BuiltinPlugin{
+			"compinfo-disk": {
+				Plugin: Plugin{
+					RunAtLoad: true,
+					Schedule:  "@every 15m",
+					Type:      "compinfo",
+				},
+				StrFunc: compinfo.GetDiskString,
+			},
 			"compinfo-docker": {
 				Plugin: Plugin{
 					Schedule: "@hourly",
@@ -191,6 +261,21 @@ func getBuiltinPlugins() map[string]BuiltinPlugin {
 				},
 				StrFunc: compinfo.GetLoadString,
 			},
+			"compinfo-mem": {
+				Plugin: Plugin{
+					Schedule: "@every 5m",
+					Type:     "compinfo",
+				},
+				StrFunc: compinfo.GetMemString,
+			},
+			"compinfo-net": {
+				Plugin: Plugin{
+					RunAtLoad: true,
+					Schedule:  "@hourly",
+					Type:      "compinfo",
+				},
+				StrFunc: compinfo.GetNetString,
+			},
 			"compinfo-sys": {
 				Plugin: Plugin{
 					RunAtLoad: true,
@@ -199,6 +284,14 @@ func getBuiltinPlugins() map[string]BuiltinPlugin {
 				},
 				StrFunc: compinfo.GetSysString,
 			},
+			"langinfo": {
+				Plugin: Plugin{
+					LoadDelay: "10m",
+					Schedule:  "@daily",
+					Type:      "langinfo",
+				},
+				StrFunc: langinfoString,
+			},
 			"pkginfo": {
 				Plugin: Plugin{
 					LoadDelay: "2m",
@@ -206,7 +299,71 @@ func getBuiltinPlugins() map[string]BuiltinPlugin {
 					Schedule:  "@daily",
 					Type:      "pkginfo",
 				},
-				StrFunc: pkginfo.String,
+				StrFunc:  pkginfo.String,
+				SendFunc: sendPkginfo,
+			},
+			"portinfo": {
+				Plugin: Plugin{
+					LoadDelay: "5m",
+					RunAtLoad: true,
+					Schedule:  "@hourly",
+					Type:      "portinfo",
+				},
+				StrFunc: portinfo.String,
+			},
+			"procinfo": {
+				Plugin: Plugin{
+					LoadDelay: "5m",
+					Schedule:  "@hourly",
+					Type:      "procinfo",
+				},
+				StrFunc: procinfoString,
+			},
+			"sbom": {
+				Plugin: Plugin{
+					Disabled:  true,
+					LoadDelay: "5m",
+					Schedule:  "@weekly",
+					Type:      "sbom",
+				},
+				StrFunc: sbomString,
+			},
+			"systemdinfo": {
+				Plugin: Plugin{
+					LoadDelay: "2m",
+					RunAtLoad: true,
+					Schedule:  "@hourly",
+					Type:      "systemdinfo",
+				},
+				StrFunc: systemdinfo.String,
+			},
+			"systemdinfo-events": {
+				Plugin: Plugin{
+					LoadDelay: "2m",
+					RunAtLoad: true,
+					Schedule:  "@every 1m",
+					Type:      "systemdinfo_event",
+				},
+				StrFunc:  systemdinfo.FailedString,
+				SendFunc: sendSystemdEvents,
+			},
+			"userinfo": {
+				Plugin: Plugin{
+					LoadDelay: "5m",
+					RunAtLoad: true,
+					Schedule:  "@daily",
+					Type:      "userinfo",
+				},
+				StrFunc: userinfo.String,
+			},
+			"vulninfo": {
+				Plugin: Plugin{
+					LoadDelay: "5m",
+					RunAtLoad: true,
+					Schedule:  "@daily",
+					Type:      "vulninfo",
+				},
+				StrFunc: vulninfo.String,
 			},
 		}
 		if envinfo.Env.CloudProvider == "aws" {
@@ -232,7 +389,57 @@ func getBuiltinPlugins() map[string]BuiltinPlugin {
 	return builtinPlugins
 }
 
+// langinfoString returns the language ecosystem packages found under
+// plugins.builtin.langinfo.roots, less plugins.builtin.langinfo.exclude, within
+// plugins.builtin.langinfo.budget.
+func langinfoString() string {
+	l := &langinfo.LangInfoGroup{
+		Roots:   viper.GetStringSlice("plugins.builtin.langinfo.roots"),
+		Exclude: viper.GetStringSlice("plugins.builtin.langinfo.exclude"),
+	}
+	if s := viper.GetString("plugins.builtin.langinfo.budget"); s != "" {
+		budget, err := time.ParseDuration(s)
+		if err != nil {
+			util.CustomOut("invalid plugins.builtin.langinfo.budget: using default", err)
+		}
+		l.Budget = budget
+	}
+	l.GetInfo()
+	return l.String()
+}
+
+// procinfoString returns the running processes of users matching
+// plugins.builtin.procinfo.users and with names matching
+// plugins.builtin.procinfo.names, at most plugins.builtin.procinfo.max of them.
+// Command line arguments are redacted unless
+// plugins.builtin.procinfo.redact_args is false.
+func procinfoString() string {
+	g := &procinfo.ProcInfoGroup{
+		Users:      viper.GetStringSlice("plugins.builtin.procinfo.users"),
+		Names:      viper.GetStringSlice("plugins.builtin.procinfo.names"),
+		RedactArgs: true,
+		Max:        viper.GetInt("plugins.builtin.procinfo.max"),
+	}
+	if viper.IsSet("plugins.builtin.procinfo.redact_args") {
+		g.RedactArgs = viper.GetBool("plugins.builtin.procinfo.redact_args")
+	}
+	g.GetInfo()
+	return g.String()
+}
+
+// sbomString returns a software bill of materials of the installed packages in
+// plugins.builtin.sbom.format, cyclonedx by default.
+func sbomString() string {
+	format := viper.GetString("plugins.builtin.sbom.format")
+	if format == "" {
+		format = pkginfo.SBOMCycloneDX
+	}
+	return pkginfo.SBOMString(format)
+}
+
 func getCustomPlugins() map[string]CustomPlugin {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
 	if len(customPlugins) == 0 {
 		err := viper.UnmarshalKey("plugins.custom", &customPlugins)
 		if err != nil {
@@ -246,6 +453,26 @@ func getCustomPlugins() map[string]CustomPlugin {
 	return customPlugins
 }
 
+// runPlugin runs the builtin or custom plugin with the given label immediately.
+func runPlugin(asset *Asset, label string) error {
+	if p, ok := getBuiltinPlugins()[label]; ok {
+		if p.Disabled {
+			return fmt.Errorf("plugin %s is disabled", label)
+		}
+		return p.Exec(asset)
+	}
+	if p, ok := getCustomPlugins()[label]; ok {
+		if p.Disabled {
+			return fmt.Errorf("plugin %s is disabled", label)
+		}
+		if p.conflictsWithBuiltin() {
+			return fmt.Errorf("plugin %s conflicts with built-in", label)
+		}
+		return p.Exec(asset)
+	}
+	return fmt.Errorf("no plugin named %s", label)
+}
+
 // getPutURL will return a presigned url msg or error
 func getPutURL(asset *Asset) (getURLMsg, error) {
 	if err := asset.SubscribeURLTopic(); err != nil {
@@ -271,16 +498,15 @@ func handleOverrides(builtins map[string]BuiltinPlugin) {
 		if err != nil {
 			jww.ERROR.Println(err)
 		}
-		if override.Disabled {
-			builtin.Disabled = override.Disabled
-		}
 		if override.LoadDelay != "" {
 			builtin.LoadDelay = override.LoadDelay
 		}
 		for _, k := range meta.Keys {
-			if k == "run_at_load" {
+			switch strings.ToLower(k) {
+			case "disabled":
+				builtin.Disabled = override.Disabled
+			case "run_at_load":
 				builtin.RunAtLoad = override.RunAtLoad
-				break
 			}
 		}
 		if override.Schedule != "" {
@@ -291,55 +517,48 @@ func handleOverrides(builtins map[string]BuiltinPlugin) {
 }
 
 func handlePlugins(asset *Asset, cron *cron.MirachCron) {
+	schedulePlugins(asset, cron, true)
+}
+
+// schedulePlugins starts cron and loads the builtin and custom plugins on it.
+func schedulePlugins(asset *Asset, cron *cron.MirachCron, atLoad bool) {
 	cron.Start()
-	loadBuiltinPlugins(asset, cron)
-	loadCustomPlugins(asset, cron)
+	loadBuiltinPlugins(asset, cron, atLoad)
+	loadCustomPlugins(asset, cron, atLoad)
 }
 
-func loadBuiltinPlugins(asset *Asset, cron *cron.MirachCron) {
+func loadBuiltinPlugins(asset *Asset, cron *cron.MirachCron, atLoad bool) {
 	for _, p := range getBuiltinPlugins() {
-		p.loadPlugin(cron, p.Run(asset))
+		p.loadPlugin(cron, p.Run(asset), atLoad)
 	}
 }
 
-func loadCustomPlugins(asset *Asset, cron *cron.MirachCron) {
+func loadCustomPlugins(asset *Asset, cron *cron.MirachCron, atLoad bool) {
 	for _, c := range getCustomPlugins() {
-		// Loop over internal plugins to check name collisions.
-		ok := true
-		for _, b := range getBuiltinPlugins() {
-			if c.Label == b.Label || c.Type == b.Type {
-				err := fmt.Errorf("refusing to load plugin %v: conflicts with built-in", c.Label)
-				util.CustomOut(nil, err)
-				ok = false
-				break
-			}
-		}
-		if !ok {
+		if c.conflictsWithBuiltin() {
+			err := fmt.Errorf("refusing to load plugin %v: conflicts with built-in", c.Label)
+			util.CustomOut(nil, err)
 			continue
 		}
-		c.loadPlugin(cron, c.Run(asset))
+		c.loadPlugin(cron, c.Run(asset), atLoad)
 	}
 }
 
-// PutData Gets presigned url and put data to it. Return string of url it has
-// been put to
-func PutData(b []byte, asset *Asset) (string, error) {
-	client := &http.Client{}
-	presigned, err := getPutURL(asset)
-	if err != nil {
-		jww.ERROR.Println(err)
-		return "", err
-	}
-	req, err := http.NewRequest("PUT", presigned.URL, bytes.NewBuffer(b))
-	if err != nil {
-		jww.ERROR.Println(err)
-		return "", err
-	}
-	if _, err = client.Do(req); err != nil {
-		jww.ERROR.Println(err)
-		return "", err
+// conflictsWithBuiltin reports whether the custom plugin has the label or type
+// of a builtin plugin.
+func (p *CustomPlugin) conflictsWithBuiltin() bool {
+	for _, b := range getBuiltinPlugins() {
+		if p.Label == b.Label || p.Type == b.Type {
+			return true
+		}
 	}
-	return presigned.URL, nil
+	return false
+}
+
+// PutData puts data too large for a message using the asset's transport,
+// which for MQTT is to a presigned url. Return string of url it has been put to
+func PutData(b []byte, asset *Asset) (string, error) {
+	return asset.transport.Put(b)
 }
 
 // SendChunks splits a byte slice and return the number of chunks, ID of
@@ -354,7 +573,7 @@ func SendChunks(b []byte, asset *Asset) (int, string, error) {
 	}
 	for i, split := range splits {
 		path := fmt.Sprintf("mirach/chunk/%s-%d", id, i)
-		if err := PubWait(asset.client, path, split); err != nil {
+		if err := asset.transport.Publish(path, split); err != nil {
 			return 0, "", err
 		}
 		n++
@@ -362,8 +581,42 @@ func SendChunks(b []byte, asset *Asset) (int, string, error) {
 	return n, id, nil
 }
 
-// SendData sends data using one of a few methods to an MQTT broker.
+// SendData sends data using one of a few methods over the asset's transport.
+// If the data cannot be delivered, it is queued in the asset's outbox to be
+// sent once the client reconnects. While data is queued, new data is queued
+// behind it and the outbox flushed, so that data reaches the backend in the
+// order it was sent.
 func SendData(b []byte, t string, asset *Asset) error {
+	connected := asset.transport != nil && asset.transport.Connected()
+	if asset.outbox != nil && asset.outbox.Depth() > 0 {
+		if err := asset.outbox.Enqueue(t, b); err != nil {
+			return fmt.Errorf("failed to queue data behind the outbox: %s", err)
+		}
+		if connected {
+			asset.flushOutbox()
+		}
+		return nil
+	}
+	var err error
+	if connected {
+		if err = sendData(b, t, asset); err == nil {
+			return nil
+		}
+	} else {
+		err = errors.New("not connected")
+	}
+	if asset.outbox == nil {
+		return err
+	}
+	if qErr := asset.outbox.Enqueue(t, b); qErr != nil {
+		return fmt.Errorf("%s; failed to queue data: %s", err, qErr)
+	}
+	jww.ERROR.Printf("%s: send failed, data queued in outbox: %s", t, err)
+	return nil
+}
+
+// sendData sends data using one of a few methods over the asset's transport.
+func sendData(b []byte, t string, asset *Asset) error {
 	custID := viper.GetString("customer.id")
 	assetID := viper.GetString("asset.id")
 	var err error
@@ -371,9 +624,14 @@ func SendData(b []byte, t string, asset *Asset) error {
 	hash := hex.EncodeToString(h[:])
 	msg := mqttMsg{Type: t, Hash: hash}
 	var msgB []byte
+	sendPath := sendPathInline
 	switch {
 	case len(b) > MaxChunkedSize:
 		url, err := PutData(b, asset)
+		if err != nil {
+			return err
+		}
+		sendPath = sendPathPresigned
 		m := putHTTPMsg{msg, url}
 		msgB, err = json.Marshal(m)
 		if err != nil {
@@ -384,6 +642,7 @@ func SendData(b []byte, t string, asset *Asset) error {
 		if err != nil {
 			return err
 		}
+		sendPath = sendPathChunked
 		m := chunksMsg{msg, n, id}
 		msgB, err = json.Marshal(m)
 		if err != nil {
@@ -397,8 +656,9 @@ func SendData(b []byte, t string, asset *Asset) error {
 		}
 	}
 	path := fmt.Sprintf("mirach/data/%s/%s", custID, assetID)
-	if err := PubWait(asset.client, path, msgB); err != nil {
+	if err := asset.transport.Publish(path, msgB); err != nil {
 		return err
 	}
+	metrics.observeSent(sendPath, len(b))
 	return nil
 }
@@ -0,0 +1,103 @@
+// +build unit
+
+package mirachlib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cleardataeng/mirach/cron"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/theherk/viper"
+)
+
+func TestHandleOverrides(t *testing.T) {
+	assert := assert.New(t)
+	defer viper.Reset()
+	viper.Set("plugins.builtin.sbom.disabled", false)
+	viper.Set("plugins.builtin.sbom.schedule", "@daily")
+	viper.Set("plugins.builtin.pkginfo.disabled", true)
+	viper.Set("plugins.builtin.pkginfo.run_at_load", false)
+	builtins := map[string]BuiltinPlugin{
+		"sbom":    {Plugin: Plugin{Disabled: true, Schedule: "@weekly"}},
+		"pkginfo": {Plugin: Plugin{RunAtLoad: true, Schedule: "@daily"}},
+		"other":   {Plugin: Plugin{Disabled: true, Schedule: "@hourly"}},
+	}
+	handleOverrides(builtins)
+	assert.False(builtins["sbom"].Disabled, "disabled by default, enabled in config")
+	assert.Equal("@daily", builtins["sbom"].Schedule)
+	assert.True(builtins["pkginfo"].Disabled)
+	assert.False(builtins["pkginfo"].RunAtLoad)
+	assert.True(builtins["other"].Disabled, "default kept without override")
+}
+
+func TestLoadPluginAtLoad(t *testing.T) {
+	assert := assert.New(t)
+	c := cron.New()
+	c.Start()
+	defer c.Stop()
+	p := Plugin{Label: "test", Schedule: "@yearly", RunAtLoad: true}
+	ran := make(chan bool, 2)
+	p.loadPlugin(c, func() { ran <- true }, false)
+	select {
+	case <-ran:
+		assert.Fail("run at load on reload")
+	case <-time.After(50 * time.Millisecond):
+	}
+	p.Label = "test-load"
+	p.loadPlugin(c, func() { ran <- true }, true)
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		assert.Fail("not run at load")
+	}
+	// Stop, deferred, only once both adds have completed.
+	for _, label := range []string{"test", "test-load"} {
+		added := false
+		for deadline := time.Now().Add(time.Second); !added && time.Now().Before(deadline); {
+			_, added = c.Next(label)
+			time.Sleep(time.Millisecond)
+		}
+		assert.True(added, label+" not added")
+	}
+}
+
+func TestCronStopCancelsDelayedAdds(t *testing.T) {
+	assert := assert.New(t)
+	c := cron.New()
+	c.Start()
+	res := make(chan interface{}, 1)
+	c.AddFuncDelayed("@yearly", func() {}, time.Hour, res)
+	c.Stop()
+	select {
+	case r := <-res:
+		assert.Equal(cron.ErrStopped, r)
+	case <-time.After(time.Second):
+		assert.Fail("delayed add not cancelled")
+	}
+	c.Stop()
+}
+
+func TestSkipOverlapping(t *testing.T) {
+	assert := assert.New(t)
+	var runs int
+	release := make(chan struct{})
+	started := make(chan struct{})
+	f := skipOverlapping("test", func() {
+		runs++
+		if runs == 1 {
+			close(started)
+			<-release
+		}
+	})
+	done := make(chan struct{})
+	go func() { f(); close(done) }()
+	<-started
+	f()
+	assert.Equal(1, runs, "skipped while the previous run is in progress")
+	close(release)
+	<-done
+	f()
+	assert.Equal(2, runs, "run once the previous run finished")
+}
@@ -0,0 +1,242 @@
+package mirachlib
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cleardataeng/mirach/util"
+
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/theherk/viper"
+)
+
+// MaxCmdTTL is the furthest in the future a command may expire.
+// Commands expiring later are rejected so that remembered nonces can be
+// forgotten after this long.
+const MaxCmdTTL = time.Hour
+
+// SignedCmdMsg is the envelope in which commands are delivered.
+// Payload is a json encoded CmdMsg and Signature is its signature by the
+// trusted command key. Both are base64 encoded in json.
+type SignedCmdMsg struct {
+	Payload   []byte `json:"payload"`
+	Signature []byte `json:"signature"`
+}
+
+// cmdVerifier opens signed command envelopes and rejects replayed commands.
+// Nonces seen are kept in the file at path, if set, until they expire, so
+// that commands cannot be replayed across restarts.
+type cmdVerifier struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time // nonces seen and when they expire
+	now    func() time.Time
+	path   string
+	// refuseUntil is set when the nonces file could not be read: until then,
+	// any command might be a replay.
+	refuseUntil time.Time
+}
+
+func cmdNoncesPath() string {
+	return filepath.Join(sysConfDir, "commands", "nonces.json")
+}
+
+// newCmdVerifier returns a verifier that keeps nonces in the file at path, or
+// only in memory if path is empty.
+func newCmdVerifier(path string) *cmdVerifier {
+	v := &cmdVerifier{nonces: make(map[string]time.Time), now: time.Now, path: path}
+	if path == "" {
+		return v
+	}
+	b, err := util.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			v.refuseUntil = v.now().Add(MaxCmdTTL)
+			jww.ERROR.Printf("commands: reading nonces: %s; refusing commands until %s", err, v.refuseUntil.UTC())
+		}
+		return v
+	}
+	var nonces map[string]int64
+	if err := json.Unmarshal(b, &nonces); err != nil {
+		v.refuseUntil = v.now().Add(MaxCmdTTL)
+		jww.ERROR.Printf("commands: reading nonces: %s; refusing commands until %s", err, v.refuseUntil.UTC())
+		return v
+	}
+	for n, exp := range nonces {
+		v.nonces[n] = time.Unix(exp, 0)
+	}
+	return v
+}
+
+// save writes the nonces not yet expired to the verifier's file.
+func (v *cmdVerifier) save() error {
+	if v.path == "" {
+		return nil
+	}
+	nonces := make(map[string]int64, len(v.nonces))
+	for n, exp := range v.nonces {
+		nonces[n] = exp.Unix()
+	}
+	b, err := json.Marshal(nonces)
+	if err != nil {
+		return err
+	}
+	return util.ForceWrite(v.path, string(b))
+}
+
+// open verifies the envelope in b against key and returns the command in it.
+// If key is nil, b is accepted as an unsigned CmdMsg. Either way, the command
+// must be for the given customer and asset, and must not be expired or
+// replayed.
+func (v *cmdVerifier) open(b []byte, key crypto.PublicKey, custID, assetID string) (CmdMsg, error) {
+	var msg CmdMsg
+	if key == nil {
+		if err := json.Unmarshal(b, &msg); err != nil {
+			return msg, fmt.Errorf("malformed command: %s", err)
+		}
+	} else {
+		var env SignedCmdMsg
+		if err := json.Unmarshal(b, &env); err != nil {
+			return msg, fmt.Errorf("malformed command envelope: %s", err)
+		}
+		if err := verifySignature(key, env.Payload, env.Signature); err != nil {
+			// Decode what we can so the rejection can be correlated.
+			json.Unmarshal(env.Payload, &msg)
+			return msg, err
+		}
+		if err := json.Unmarshal(env.Payload, &msg); err != nil {
+			return msg, fmt.Errorf("malformed command: %s", err)
+		}
+	}
+	if err := checkTarget(msg, custID, assetID); err != nil {
+		return msg, err
+	}
+	return msg, v.checkReplay(msg)
+}
+
+// checkTarget rejects commands for another customer or asset. The topic a
+// command arrives on is not signed, so without this a command signed for one
+// asset could be delivered to any other.
+func checkTarget(msg CmdMsg, custID, assetID string) error {
+	if msg.CustomerID == "" || msg.AssetID == "" {
+		return errors.New("command has no customer_id or asset_id")
+	}
+	if msg.CustomerID != custID || msg.AssetID != assetID {
+		return fmt.Errorf("command is for asset %s of customer %s", msg.AssetID, msg.CustomerID)
+	}
+	return nil
+}
+
+// checkReplay rejects commands that are expired, expire too far in the future,
+// or carry a nonce that has already been used.
+func (v *cmdVerifier) checkReplay(msg CmdMsg) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := v.now()
+	for n, exp := range v.nonces {
+		if now.After(exp) {
+			delete(v.nonces, n)
+		}
+	}
+	if now.Before(v.refuseUntil) {
+		return fmt.Errorf("nonces of earlier commands unknown; refusing commands until %s", v.refuseUntil.UTC())
+	}
+	if msg.Nonce == "" {
+		return errors.New("command has no nonce")
+	}
+	exp := time.Unix(msg.Expires, 0)
+	switch {
+	case msg.Expires == 0:
+		return errors.New("command has no expiry")
+	case now.After(exp):
+		return fmt.Errorf("command expired at %s", exp.UTC())
+	case exp.Sub(now) > MaxCmdTTL:
+		return fmt.Errorf("command expiry more than %s in the future", MaxCmdTTL)
+	}
+	if _, seen := v.nonces[msg.Nonce]; seen {
+		return fmt.Errorf("command nonce %s already used", msg.Nonce)
+	}
+	v.nonces[msg.Nonce] = exp
+	if err := v.save(); err != nil {
+		// Refused, as it could be replayed after a restart.
+		return fmt.Errorf("saving command nonce: %s", err)
+	}
+	return nil
+}
+
+// verifySignature checks sig over payload using an Ed25519, ECDSA, or RSA key.
+// ECDSA and RSA signatures are over the SHA-256 digest of the payload.
+func verifySignature(key crypto.PublicKey, payload, sig []byte) error {
+	digest := sha256.Sum256(payload)
+	var ok bool
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(k, payload, sig)
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(k, digest[:], sig)
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil
+	default:
+		return fmt.Errorf("unsupported command key type %T", key)
+	}
+	if !ok {
+		return errors.New("invalid command signature")
+	}
+	return nil
+}
+
+// parseCmdKey parses a PEM encoded public key or X.509 certificate.
+func parseCmdKey(b []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM data in trusted command key")
+	}
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if now := time.Now(); now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+			return nil, errors.New("trusted command certificate is not currently valid")
+		}
+		return cert.PublicKey, nil
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q in trusted command key", block.Type)
+}
+
+// loadCmdKey returns the key trusted to sign commands. It is read from the
+// path in commands.trusted_key_path or commands/keys/trusted.pem in the
+// configuration directories. If no key is found and commands.allow_unsigned is
+// set, a nil key is returned and commands are accepted without signatures.
+func loadCmdKey() (crypto.PublicKey, error) {
+	path := viper.GetString("commands.trusted_key_path")
+	if path == "" {
+		var err error
+		path, err = util.FindInDirs(filepath.Join("commands", "keys", "trusted.pem"), confDirs)
+		if err != nil {
+			if viper.GetBool("commands.allow_unsigned") {
+				return nil, nil
+			}
+			return nil, errors.New("no trusted command key; refusing command")
+		}
+	}
+	b, err := util.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseCmdKey(b)
+}
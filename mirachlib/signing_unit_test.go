@@ -0,0 +1,127 @@
+// +build unit
+
+package mirachlib
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func signedCmd(t *testing.T, priv ed25519.PrivateKey, msg CmdMsg) []byte {
+	payload, _ := json.Marshal(msg)
+	b, err := json.Marshal(SignedCmdMsg{Payload: payload, Signature: ed25519.Sign(priv, payload)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestCmdVerifierOpen(t *testing.T) {
+	assert := assert.New(t)
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	now := time.Unix(1500000000, 0)
+	v := newCmdVerifier("")
+	v.now = func() time.Time { return now }
+	exp := now.Add(time.Minute).Unix()
+	good := signedCmd(t, priv, CmdMsg{ID: "1", Cmd: "status", CustomerID: "c", AssetID: "a", Nonce: "a", Expires: exp})
+	msg, err := v.open(good, pub, "c", "a")
+	assert.Nil(err)
+	assert.Equal("status", msg.Cmd)
+	_, err = v.open(good, pub, "c", "a")
+	assert.NotNil(err, "replayed nonce rejected")
+
+	_, other, _ := ed25519.GenerateKey(rand.Reader)
+	forged := signedCmd(t, other, CmdMsg{ID: "2", Cmd: "status", CustomerID: "c", AssetID: "a", Nonce: "b", Expires: exp})
+	msg, err = v.open(forged, pub, "c", "a")
+	assert.NotNil(err, "signature by untrusted key rejected")
+	assert.Equal("2", msg.ID, "rejected command still correlated")
+
+	expired := signedCmd(t, priv, CmdMsg{Cmd: "status", CustomerID: "c", AssetID: "a", Nonce: "c", Expires: now.Add(-time.Second).Unix()})
+	_, err = v.open(expired, pub, "c", "a")
+	assert.NotNil(err, "expired command rejected")
+	distant := signedCmd(t, priv, CmdMsg{Cmd: "status", CustomerID: "c", AssetID: "a", Nonce: "d", Expires: now.Add(2 * MaxCmdTTL).Unix()})
+	_, err = v.open(distant, pub, "c", "a")
+	assert.NotNil(err, "expiry beyond max ttl rejected")
+	noNonce := signedCmd(t, priv, CmdMsg{Cmd: "status", CustomerID: "c", AssetID: "a", Expires: exp})
+	_, err = v.open(noNonce, pub, "c", "a")
+	assert.NotNil(err, "command without nonce rejected")
+
+	_, err = v.open([]byte("{not json"), pub, "c", "a")
+	assert.NotNil(err, "malformed envelope rejected without panic")
+
+	otherAsset := signedCmd(t, priv, CmdMsg{Cmd: "status", CustomerID: "c", AssetID: "b", Nonce: "e", Expires: exp})
+	_, err = v.open(otherAsset, pub, "c", "a")
+	assert.NotNil(err, "command for another asset rejected")
+	_, err = v.open(otherAsset, pub, "c", "b")
+	assert.Nil(err)
+	noTarget := signedCmd(t, priv, CmdMsg{Cmd: "status", Nonce: "f", Expires: exp})
+	_, err = v.open(noTarget, pub, "c", "a")
+	assert.NotNil(err, "command without target rejected")
+}
+
+func TestCmdVerifierOpenUnsigned(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Unix(1500000000, 0)
+	v := newCmdVerifier("")
+	v.now = func() time.Time { return now }
+	exp := now.Add(time.Minute).Unix()
+	cmd := func(msg CmdMsg) []byte {
+		b, _ := json.Marshal(msg)
+		return b
+	}
+	good := cmd(CmdMsg{Cmd: "status", CustomerID: "c", AssetID: "a", Nonce: "a", Expires: exp})
+	msg, err := v.open(good, nil, "c", "a")
+	assert.Nil(err)
+	assert.Equal("status", msg.Cmd)
+	_, err = v.open(good, nil, "c", "a")
+	assert.NotNil(err, "replayed nonce rejected")
+	_, err = v.open(cmd(CmdMsg{Cmd: "status", CustomerID: "c", AssetID: "a", Nonce: "b"}), nil, "c", "a")
+	assert.NotNil(err, "command without expiry rejected")
+	_, err = v.open(cmd(CmdMsg{Cmd: "status", CustomerID: "c", AssetID: "b", Nonce: "c", Expires: exp}), nil, "c", "a")
+	assert.NotNil(err, "command for another asset rejected")
+}
+
+func TestCmdVerifierPersistsNonces(t *testing.T) {
+	assert := assert.New(t)
+	util.ResetTestFs()
+	util.SetFs(util.TestFs)
+	path := "/etc/mirach/commands/nonces.json"
+	now := time.Unix(1500000000, 0)
+	cmd, _ := json.Marshal(CmdMsg{Cmd: "status", CustomerID: "c", AssetID: "a", Nonce: "a", Expires: now.Add(time.Minute).Unix()})
+	v := newCmdVerifier(path)
+	v.now = func() time.Time { return now }
+	_, err := v.open(cmd, nil, "c", "a")
+	assert.Nil(err)
+
+	v = newCmdVerifier(path)
+	v.now = func() time.Time { return now }
+	_, err = v.open(cmd, nil, "c", "a")
+	assert.NotNil(err, "replay after a restart rejected")
+
+	assert.Nil(afero.WriteFile(util.TestFs, path, []byte("{not json"), 0644))
+	v = newCmdVerifier(path)
+	other, _ := json.Marshal(CmdMsg{Cmd: "status", CustomerID: "c", AssetID: "a", Nonce: "b", Expires: time.Now().Add(time.Minute).Unix()})
+	_, err = v.open(other, nil, "c", "a")
+	assert.NotNil(err, "commands refused while earlier nonces are unknown")
+}
+
+func TestParseCmdKey(t *testing.T) {
+	assert := assert.New(t)
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKIXPublicKey(pub)
+	key, err := parseCmdKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	assert.Nil(err)
+	assert.Equal(pub, key)
+	_, err = parseCmdKey([]byte("not pem"))
+	assert.NotNil(err)
+}
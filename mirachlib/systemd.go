@@ -0,0 +1,65 @@
+package mirachlib
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/cleardataeng/mirach/plugin/systemdinfo"
+)
+
+// systemdUnitFailed is the event sent when a systemd unit fails.
+const systemdUnitFailed = "unit_failed"
+
+// systemdEvent is a change in the state of a systemd unit, sent on its own
+// rather than waiting for the next systemdinfo inventory.
+type systemdEvent struct {
+	Event string           `json:"event"`
+	Unit  systemdinfo.Unit `json:"unit"`
+}
+
+// systemdFailures holds when each unit known to have failed failed, so that
+// each failure is sent once. A unit that recovers is forgotten, and is sent
+// again if it fails again.
+var systemdFailures = struct {
+	sync.Mutex
+	at map[string]time.Time
+}{at: map[string]time.Time{}}
+
+// sendSystemdEvents sends an event for each unit in b, a SystemdInfoGroup of
+// failed units, that has failed since the last time it was called. Units that
+// were already failed when mirach started are sent on the first call.
+func sendSystemdEvents(b []byte, t string, asset *Asset) error {
+	g := new(systemdinfo.SystemdInfoGroup)
+	if err := json.Unmarshal(b, g); err != nil {
+		return err
+	}
+	systemdFailures.Lock()
+	defer systemdFailures.Unlock()
+	failed := map[string]time.Time{}
+	var firstErr error
+	for _, u := range g.Units {
+		var at time.Time
+		if u.FailedAt != nil {
+			at = *u.FailedAt
+		}
+		if prev, ok := systemdFailures.at[u.Name]; ok && prev.Equal(at) {
+			failed[u.Name] = at
+			continue
+		}
+		d, err := json.Marshal(systemdEvent{Event: systemdUnitFailed, Unit: u})
+		if err == nil {
+			err = SendData(d, t, asset)
+		}
+		if err != nil {
+			// Left out of failed so that it is tried again on the next call.
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		failed[u.Name] = at
+	}
+	systemdFailures.at = failed
+	return firstErr
+}
@@ -0,0 +1,55 @@
+// +build unit
+
+package mirachlib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendSystemdEvents(t *testing.T) {
+	assert := assert.New(t)
+	systemdFailures.at = map[string]time.Time{}
+	var buf bytes.Buffer
+	asset := &Asset{transport: &dryRunWriter{w: &buf}}
+	sent := func() []string {
+		var units []string
+		s := bufio.NewScanner(&buf)
+		for s.Scan() {
+			var rec dryRunRecord
+			assert.NoError(json.Unmarshal(s.Bytes(), &rec))
+			var msg dataMsg
+			assert.NoError(json.Unmarshal(rec.Payload, &msg))
+			assert.Equal("systemdinfo_event", msg.Type)
+			var ev systemdEvent
+			assert.NoError(json.Unmarshal(msg.Data, &ev))
+			assert.Equal(systemdUnitFailed, ev.Event)
+			units = append(units, ev.Unit.Name)
+		}
+		buf.Reset()
+		return units
+	}
+	failed := func(units string) []byte {
+		return []byte(`{"units":[` + units + `]}`)
+	}
+	nginx := `{"name":"nginx.service","active_state":"failed","failed_at":"2023-06-02T13:14:15Z"}`
+	cron := `{"name":"cron.service","active_state":"failed","failed_at":"2023-06-02T13:20:00Z"}`
+
+	assert.NoError(sendSystemdEvents(failed(nginx), "systemdinfo_event", asset))
+	assert.Equal([]string{"nginx.service"}, sent())
+
+	assert.NoError(sendSystemdEvents(failed(nginx+","+cron), "systemdinfo_event", asset))
+	assert.Equal([]string{"cron.service"}, sent(), "known failures are not sent again")
+
+	assert.NoError(sendSystemdEvents(failed(cron), "systemdinfo_event", asset))
+	assert.Empty(sent())
+
+	nginx = `{"name":"nginx.service","active_state":"failed","failed_at":"2023-06-02T14:00:00Z"}`
+	assert.NoError(sendSystemdEvents(failed(nginx+","+cron), "systemdinfo_event", asset))
+	assert.Equal([]string{"nginx.service"}, sent(), "a unit failing again is sent")
+}
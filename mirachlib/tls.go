@@ -0,0 +1,108 @@
+package mirachlib
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/theherk/viper"
+)
+
+// insecureWarning is logged on each connection while tls.insecure_skip_verify
+// is set.
+const insecureWarning = "WARNING: tls.insecure_skip_verify is set; " +
+	"the identity of the broker will NOT be verified"
+
+// newTLSConfig returns the TLS configuration used to connect to the broker.
+// The broker's certificate is verified against the given CA. The following
+// configuration keys adjust verification:
+//
+//	tls.server_name           name expected in the broker's certificate
+//	tls.pins                  base64 SHA-256 digests of accepted public keys
+//	tls.insecure_skip_verify  disable verification of the broker entirely
+func newTLSConfig(ca, privKey, cert []byte) (*tls.Config, error) {
+	pair, err := tls.X509KeyPair(cert, privKey)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no certificates found in certificate authority")
+	}
+	conf := &tls.Config{
+		Certificates: []tls.Certificate{pair},
+		RootCAs:      pool,
+		ServerName:   viper.GetString("tls.server_name"),
+	}
+	if viper.GetBool("tls.insecure_skip_verify") {
+		conf.InsecureSkipVerify = true
+	}
+	if pins := viper.GetStringSlice("tls.pins"); len(pins) > 0 {
+		conf.VerifyPeerCertificate = verifyPins(pins, pool)
+	}
+	conf.BuildNameToCertificate()
+	return conf, nil
+}
+
+// spkiPin returns the base64 encoded SHA-256 digest of a certificate's
+// subject public key info.
+func spkiPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// verifyPins returns a function, suitable for tls.Config.VerifyPeerCertificate,
+// that succeeds only if a certificate of a verified chain has a public key
+// matching one of the given pins. Other certificates the peer presents are
+// ignored, as anyone can present a pinned CA's certificate. When verification
+// is skipped, leaving no verified chains, the peer's chain is first verified
+// against roots, though not against the broker's name.
+func verifyPins(pins []string, roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, chains [][]*x509.Certificate) error {
+		if len(chains) == 0 {
+			var err error
+			if chains, err = verifyChain(rawCerts, roots); err != nil {
+				return err
+			}
+		}
+		for _, chain := range chains {
+			for _, cert := range chain {
+				pin := spkiPin(cert)
+				for _, p := range pins {
+					if p == pin {
+						return nil
+					}
+				}
+			}
+		}
+		return fmt.Errorf("broker public key does not match any of %d configured pins", len(pins))
+	}
+}
+
+// verifyChain verifies the certificates presented by a peer, leaf first,
+// against roots and returns the verified chains.
+func verifyChain(rawCerts [][]byte, roots *x509.CertPool) ([][]*x509.Certificate, error) {
+	if len(rawCerts) == 0 {
+		return nil, errors.New("broker presented no certificate")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return nil, err
+		}
+		certs[i] = cert
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	return certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+}
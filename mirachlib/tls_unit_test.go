@@ -0,0 +1,112 @@
+// +build unit
+
+package mirachlib
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/theherk/viper"
+)
+
+// testCert returns a self-signed certificate and its private key, both DER encoded.
+func testCert(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "broker.test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der, keyDer
+}
+
+// testSignedCert returns a certificate for broker.test signed by the parent,
+// DER encoded, or self-signed if parent is nil.
+func testSignedCert(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "broker.test"},
+		DNSNames:     []string{"broker.test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestVerifyPins(t *testing.T) {
+	assert := assert.New(t)
+	caDer, caKeyDer := testCert(t)
+	ca, _ := x509.ParseCertificate(caDer)
+	ca.IsCA, ca.BasicConstraintsValid = true, true
+	caKey, _ := x509.ParseECPrivateKey(caKeyDer)
+	caDer, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ = x509.ParseCertificate(caDer)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	leafDer := testSignedCert(t, ca, caKey)
+	leaf, _ := x509.ParseCertificate(leafDer)
+
+	good := verifyPins([]string{"bm90IHRoZSBwaW4=", spkiPin(ca)}, roots)
+	assert.Nil(good([][]byte{leafDer}, [][]*x509.Certificate{{leaf, ca}}))
+	assert.Nil(good([][]byte{leafDer}, nil), "chain verified when verification is skipped")
+	bad := verifyPins([]string{"bm90IHRoZSBwaW4="}, roots)
+	assert.NotNil(bad([][]byte{leafDer}, [][]*x509.Certificate{{leaf, ca}}))
+
+	// An unrelated leaf presenting the pinned CA's certificate as an extra.
+	otherDer := testSignedCert(t, nil, nil)
+	other, _ := x509.ParseCertificate(otherDer)
+	assert.NotNil(good([][]byte{otherDer, caDer}, [][]*x509.Certificate{{other}}), "unverified certificates ignored")
+	assert.NotNil(good([][]byte{otherDer, caDer}, nil), "unrelated leaf rejected when verification is skipped")
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	assert := assert.New(t)
+	der, keyDer := testCert(t)
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	privKey := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	ca := cert
+	viper.Reset()
+	viper.Set("tls.server_name", "broker.test")
+	conf, err := newTLSConfig(ca, privKey, cert)
+	assert.Nil(err)
+	assert.False(conf.InsecureSkipVerify, "verification on by default")
+	assert.Equal("broker.test", conf.ServerName)
+	assert.Nil(conf.VerifyPeerCertificate, "no pins configured")
+	_, err = newTLSConfig([]byte("not a certificate"), privKey, cert)
+	assert.NotNil(err, "empty certificate pool rejected")
+}
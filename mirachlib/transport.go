@@ -0,0 +1,170 @@
+package mirachlib
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/google/uuid"
+	jww "github.com/spf13/jwalterweatherman"
+	"github.com/theherk/viper"
+)
+
+// Transport delivers an asset's messages.
+type Transport interface {
+	// Name describes the transport.
+	Name() string
+	// Connected reports whether messages can currently be delivered.
+	Connected() bool
+	// Publish delivers a message to the given topic.
+	Publish(topic string, b []byte) error
+	// Put stores data too large for a message and returns the url at which
+	// it was stored.
+	Put(b []byte) (string, error)
+}
+
+// Transport types that can be set in transport.type.
+const (
+	TransportMQTT  = "mqtt"
+	TransportHTTPS = "https"
+)
+
+// mqttTransport publishes to the broker with the asset's MQTT client and puts
+// data to presigned urls requested from the broker.
+type mqttTransport struct {
+	asset *Asset
+}
+
+func (t *mqttTransport) Name() string {
+	return TransportMQTT
+}
+
+func (t *mqttTransport) Connected() bool {
+	return t.asset.client != nil && t.asset.conn.State() == Connected
+}
+
+func (t *mqttTransport) Publish(topic string, b []byte) error {
+	return PubWait(t.asset.client, topic, b)
+}
+
+func (t *mqttTransport) Put(b []byte) (string, error) {
+	client := &http.Client{}
+	presigned, err := getPutURL(t.asset)
+	if err != nil {
+		jww.ERROR.Println(err)
+		return "", err
+	}
+	req, err := http.NewRequest("PUT", presigned.URL, bytes.NewBuffer(b))
+	if err != nil {
+		jww.ERROR.Println(err)
+		return "", err
+	}
+	if _, err = client.Do(req); err != nil {
+		jww.ERROR.Println(err)
+		return "", err
+	}
+	return presigned.URL, nil
+}
+
+// httpsTransport posts messages to a collector over HTTPS, authenticating
+// with the asset's client certificate. A message to a topic is posted to the
+// topic's path under the collector's url, and data is put to
+// mirach/put/<customer id>/<asset id>/<uuid> under it.
+type httpsTransport struct {
+	url     string
+	custID  string
+	assetID string
+	client  *http.Client
+}
+
+// newHTTPSTransport returns a transport posting to the collector at url.
+// If ca is empty, the collector's certificate is verified against the system's
+// certificate authorities.
+func newHTTPSTransport(url string, ca, privKey, cert []byte, custID, assetID string) (*httpsTransport, error) {
+	if !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("collector url must use https: %s", url)
+	}
+	pair, err := tls.X509KeyPair(cert, privKey)
+	if err != nil {
+		return nil, err
+	}
+	conf := &tls.Config{Certificates: []tls.Certificate{pair}}
+	if len(ca) > 0 {
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.New("no certificates found in collector certificate authority")
+		}
+	}
+	return &httpsTransport{
+		url:     strings.TrimRight(url, "/"),
+		custID:  custID,
+		assetID: assetID,
+		client: &http.Client{
+			Timeout:   time.Minute,
+			Transport: &http.Transport{TLSClientConfig: conf},
+		},
+	}, nil
+}
+
+func (t *httpsTransport) Name() string {
+	return TransportHTTPS
+}
+
+// Connected always reports true; failures are only known once a request is made.
+func (t *httpsTransport) Connected() bool {
+	return true
+}
+
+func (t *httpsTransport) Publish(topic string, b []byte) error {
+	return t.do("POST", t.url+"/"+topic, b)
+}
+
+func (t *httpsTransport) Put(b []byte) (string, error) {
+	url := fmt.Sprintf("%s/mirach/put/%s/%s/%s", t.url, t.custID, t.assetID, uuid.New())
+	return url, t.do("PUT", url, b)
+}
+
+func (t *httpsTransport) do(method, url string, b []byte) error {
+	req, err := http.NewRequest(method, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("collector responded to %s %s: %s", method, url, res.Status)
+	}
+	return nil
+}
+
+// newHTTPSTransportFromConfig returns a transport posting to the collector at
+// transport.https.url, verified against the certificate authority at
+// transport.https.ca_path if set.
+func (a *Asset) newHTTPSTransportFromConfig() (*httpsTransport, error) {
+	url := viper.GetString("transport.https.url")
+	if url == "" {
+		return nil, errors.New("transport.https.url is required for the https transport")
+	}
+	var ca []byte
+	if path := viper.GetString("transport.https.ca_path"); path != "" {
+		var err error
+		if ca, err = util.ReadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return newHTTPSTransport(url, ca, a.privKey, a.cert, a.cust.id, a.id)
+}
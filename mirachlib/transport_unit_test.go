@@ -0,0 +1,55 @@
+// +build unit
+
+package mirachlib
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPSTransport(t *testing.T) {
+	assert := assert.New(t)
+	type request struct {
+		method, path, body string
+		clientCerts        int
+	}
+	var got []request
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		got = append(got, request{r.Method, r.URL.Path, string(b), len(r.TLS.PeerCertificates)})
+		if strings.HasSuffix(r.URL.Path, "/fail") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	der, keyDer := testCert(t)
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	privKey := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+
+	_, err := newHTTPSTransport("http://collector.test", ca, privKey, cert, "cust", "asset")
+	assert.Error(err, "plain http refused")
+
+	tr, err := newHTTPSTransport(ts.URL+"/", ca, privKey, cert, "cust", "asset")
+	assert.NoError(err)
+	assert.True(tr.Connected())
+	assert.NoError(tr.Publish("mirach/data/cust/asset", []byte(`{"a":1}`)))
+	url, err := tr.Put([]byte("big"))
+	assert.NoError(err)
+	assert.True(strings.HasPrefix(url, ts.URL+"/mirach/put/cust/asset/"))
+	assert.Error(tr.Publish("fail", nil), "non-2xx responses are errors")
+	assert.Len(got, 3)
+	assert.Equal(request{"POST", "/mirach/data/cust/asset", `{"a":1}`, 1}, got[0])
+	assert.Equal("PUT", got[1].method)
+	assert.Equal("big", got[1].body)
+}
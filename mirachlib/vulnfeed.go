@@ -0,0 +1,79 @@
+package mirachlib
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cleardataeng/mirach/plugin/vulninfo"
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/spf13/afero"
+)
+
+// maxVulnFeedSize limits the size of a vulnerability feed delivered by command.
+const maxVulnFeedSize = 512 << 20
+
+// updateVulnFeedCmd downloads the vulnerability feed at the "url" argument,
+// which must use https, into the vulninfo directory of the system
+// configuration directory as the "name" argument, an OSV (.json) or OVAL
+// (.xml) file name. If the "sha256" argument is given, the feed must match it.
+func updateVulnFeedCmd(asset *Asset, msg CmdMsg) (string, error) {
+	url, name := msg.Args["url"], msg.Args["name"]
+	if url == "" || name == "" {
+		return "", errors.New("missing argument: url and name are required")
+	}
+	if !strings.HasPrefix(url, "https://") {
+		return "", fmt.Errorf("feed url must use https: %s", url)
+	}
+	if name != filepath.Base(name) || !(strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".xml")) {
+		return "", fmt.Errorf("invalid feed name: %s", name)
+	}
+	b, err := fetchVulnFeed(url)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	if want := msg.Args["sha256"]; want != "" && !strings.EqualFold(want, hex.EncodeToString(sum[:])) {
+		return "", fmt.Errorf("feed checksum mismatch: got %x", sum)
+	}
+	dir := filepath.Join(sysConfDir, vulninfo.FeedDir)
+	if err := util.Fs.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	if err := afero.WriteFile(util.Fs, path+".tmp", b, 0644); err != nil {
+		return "", err
+	}
+	if err := util.Fs.Rename(path+".tmp", path); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("wrote %d bytes to %s", len(b), path), nil
+}
+
+func fetchVulnFeed(url string) ([]byte, error) {
+	client := &http.Client{Timeout: 10 * time.Minute}
+	res, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching feed: %s", res.Status)
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(res.Body, maxVulnFeedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxVulnFeedSize {
+		return nil, fmt.Errorf("feed larger than %d bytes", maxVulnFeedSize)
+	}
+	return b, nil
+}
@@ -6,9 +6,12 @@ import (
 	"github.com/cleardataeng/mirach/plugin"
 
 	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/disk"
 	"github.com/shirou/gopsutil/docker"
 	"github.com/shirou/gopsutil/host"
 	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/mem"
+	"github.com/shirou/gopsutil/net"
 )
 
 // Exceptions is a list of strings containing error strings that are expected
@@ -37,10 +40,45 @@ type Sys struct {
 	CPUs []cpu.InfoStat `json:"cpus"`
 }
 
+// Mem contains information about memory and swap usage.
+type Mem struct {
+	Virtual *mem.VirtualMemoryStat `json:"virtual"`
+	Swap    *mem.SwapMemoryStat    `json:"swap"`
+}
+
+// Disk contains information about mounted filesystems, including their space
+// and inode usage, and the IO counters of block devices keyed by device name.
+type Disk struct {
+	Filesystems []Filesystem                   `json:"filesystems"`
+	IOCounters  map[string]disk.IOCountersStat `json:"io_counters"`
+}
+
+// Filesystem is a mounted partition and its usage. Usage is nil when it could
+// not be read, as for a stale network mount.
+type Filesystem struct {
+	disk.PartitionStat
+	Usage *disk.UsageStat `json:"usage"`
+}
+
+// Net contains information about network interfaces.
+type Net struct {
+	Interfaces []Interface `json:"interfaces"`
+}
+
+// Interface is a network interface, with its addresses, MAC address and MTU,
+// and its IO counters where known.
+type Interface struct {
+	net.InterfaceStat
+	Counters *net.IOCountersStat `json:"counters,omitempty"`
+}
+
 var (
-	d = new(Docker)
-	l = new(Load)
-	s = new(Sys)
+	d  = new(Docker)
+	l  = new(Load)
+	s  = new(Sys)
+	m  = new(Mem)
+	dk = new(Disk)
+	n  = new(Net)
 )
 
 // GetInfo retrieves information about Docker containers and populates the Docker
@@ -168,3 +206,149 @@ func GetSysString() string {
 	s.GetInfo()
 	return s.String()
 }
+
+// GetInfo retrieves information about memory and swap usage and populates the
+// Mem struct with this data.
+func (g *Mem) GetInfo() {
+	var err error
+	g.Virtual, err = mem.VirtualMemory()
+	if err != nil {
+		panic(err)
+	}
+	g.Swap, err = mem.SwapMemory()
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (g *Mem) String() string {
+	s, _ := json.Marshal(g)
+	return string(s)
+}
+
+// GetMemInfo will update memory information and return the object.
+// This is a helper function that shortens:
+//     m := new(compinfo.Mem)
+//     m.GetInfo()
+// to:
+//     m := compinfo.GetMemInfo()
+func GetMemInfo() *Mem {
+	m.GetInfo()
+	return m
+}
+
+// GetMemString will update memory information and return the string.
+// This is a helper function that shortens:
+//     m := new(compinfo.Mem)
+//     m.GetInfo()
+//     json := m.String()
+// to:
+//     json := compinfo.GetMemString()
+func GetMemString() string {
+	m.GetInfo()
+	return m.String()
+}
+
+// GetInfo retrieves information about the physical partitions mounted and
+// block device IO and populates the Disk struct with this data.
+func (g *Disk) GetInfo() {
+	parts, err := disk.Partitions(false)
+	if err != nil {
+		panic(err)
+	}
+	g.Filesystems = make([]Filesystem, 0, len(parts))
+	for _, p := range parts {
+		fs := Filesystem{PartitionStat: p}
+		if u, err := disk.Usage(p.Mountpoint); err == nil {
+			fs.Usage = u
+		}
+		g.Filesystems = append(g.Filesystems, fs)
+	}
+	g.IOCounters, err = disk.IOCounters()
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (g *Disk) String() string {
+	s, _ := json.Marshal(g)
+	return string(s)
+}
+
+// GetDiskInfo will update disk information and return the object.
+// This is a helper function that shortens:
+//     dk := new(compinfo.Disk)
+//     dk.GetInfo()
+// to:
+//     dk := compinfo.GetDiskInfo()
+func GetDiskInfo() *Disk {
+	dk.GetInfo()
+	return dk
+}
+
+// GetDiskString will update disk information and return the string.
+// This is a helper function that shortens:
+//     dk := new(compinfo.Disk)
+//     dk.GetInfo()
+//     json := dk.String()
+// to:
+//     json := compinfo.GetDiskString()
+func GetDiskString() string {
+	dk.GetInfo()
+	return dk.String()
+}
+
+// GetInfo retrieves information about network interfaces and populates the Net
+// struct with this data.
+func (g *Net) GetInfo() {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		panic(err)
+	}
+	counters, err := net.IOCounters(true)
+	if err != nil {
+		panic(err)
+	}
+	g.Interfaces = mergeInterfaces(ifaces, counters)
+}
+
+func (g *Net) String() string {
+	s, _ := json.Marshal(g)
+	return string(s)
+}
+
+// mergeInterfaces pairs each interface with its IO counters by name.
+func mergeInterfaces(ifaces []net.InterfaceStat, counters []net.IOCountersStat) []Interface {
+	byName := map[string]*net.IOCountersStat{}
+	for i := range counters {
+		byName[counters[i].Name] = &counters[i]
+	}
+	out := make([]Interface, 0, len(ifaces))
+	for _, iface := range ifaces {
+		out = append(out, Interface{InterfaceStat: iface, Counters: byName[iface.Name]})
+	}
+	return out
+}
+
+// GetNetInfo will update network information and return the object.
+// This is a helper function that shortens:
+//     n := new(compinfo.Net)
+//     n.GetInfo()
+// to:
+//     n := compinfo.GetNetInfo()
+func GetNetInfo() *Net {
+	n.GetInfo()
+	return n
+}
+
+// GetNetString will update network information and return the string.
+// This is a helper function that shortens:
+//     n := new(compinfo.Net)
+//     n.GetInfo()
+//     json := n.String()
+// to:
+//     json := compinfo.GetNetString()
+func GetNetString() string {
+	n.GetInfo()
+	return n.String()
+}
@@ -7,9 +7,11 @@ import (
 	"testing"
 
 	"github.com/shirou/gopsutil/cpu"
+	"github.com/shirou/gopsutil/disk"
 	"github.com/shirou/gopsutil/docker"
 	"github.com/shirou/gopsutil/host"
 	"github.com/shirou/gopsutil/load"
+	"github.com/shirou/gopsutil/net"
 )
 
 type MockInfoGroup struct {
@@ -154,3 +156,62 @@ func TestSysString(t *testing.T) {
 		t.Error("CPU data does not match")
 	}
 }
+
+func TestDiskString(t *testing.T) {
+	ogD := &Disk{
+		Filesystems: []Filesystem{
+			{
+				PartitionStat: disk.PartitionStat{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4", Opts: "rw,relatime"},
+				Usage:         &disk.UsageStat{Path: "/", Total: 100, Used: 40, InodesTotal: 10, InodesUsed: 9, InodesUsedPercent: 90},
+			},
+			{
+				PartitionStat: disk.PartitionStat{Device: "server:/export", Mountpoint: "/mnt", Fstype: "nfs"},
+			},
+		},
+		IOCounters: map[string]disk.IOCountersStat{
+			"sda": {Name: "sda", ReadCount: 7, WriteBytes: 4096},
+		},
+	}
+	newD := new(Disk)
+	if err := json.Unmarshal([]byte(ogD.String()), &newD); err != nil {
+		t.Error("not able to unmarshal into Disk")
+	}
+	if newD.Filesystems[0].Mountpoint != "/" || newD.Filesystems[0].Usage.InodesUsedPercent != 90 {
+		t.Error("filesystem data does not match")
+	}
+	if newD.Filesystems[1].Usage != nil {
+		t.Error("unreadable usage should be null")
+	}
+	if newD.IOCounters["sda"].WriteBytes != 4096 {
+		t.Error("IO counters do not match")
+	}
+}
+
+func TestMergeInterfaces(t *testing.T) {
+	ifaces := []net.InterfaceStat{
+		{Name: "lo", MTU: 65536, Addrs: []net.InterfaceAddr{{Addr: "127.0.0.1/8"}}},
+		{Name: "eth0", MTU: 9001, HardwareAddr: "02:42:ac:11:00:02"},
+		{Name: "tun0", MTU: 1500},
+	}
+	counters := []net.IOCountersStat{
+		{Name: "eth0", BytesRecv: 2048, BytesSent: 1024},
+		{Name: "lo", BytesRecv: 10, BytesSent: 10},
+	}
+	got := mergeInterfaces(ifaces, counters)
+	if len(got) != 3 {
+		t.Fatal("wrong number of interfaces")
+	}
+	if got[1].Name != "eth0" || got[1].Counters == nil || got[1].Counters.BytesRecv != 2048 {
+		t.Error("eth0 counters do not match")
+	}
+	if got[0].Counters.BytesSent != 10 || got[2].Counters != nil {
+		t.Error("counters paired with the wrong interface")
+	}
+	newN := new(Net)
+	if err := json.Unmarshal([]byte((&Net{Interfaces: got}).String()), &newN); err != nil {
+		t.Error("not able to unmarshal into Net")
+	}
+	if newN.Interfaces[1].HardwareAddr != "02:42:ac:11:00:02" || newN.Interfaces[1].Counters.BytesSent != 1024 {
+		t.Error("interface data does not match")
+	}
+}
@@ -1,6 +1,19 @@
 /*
 Package compinfo is a plugin that provides information about the asset.
 
+Its groups are:
+
+	docker  - Docker container IDs and their cgroup stats
+	load    - load averages and running and blocked process counts
+	sys     - host and CPU information
+	mem     - memory and swap usage
+	disk    - mounted physical partitions with their space and inode usage,
+	          and the IO counters of each block device
+	net     - network interfaces with their addresses, MAC address, MTU, and
+	          IO counters
+
+Each is sent by its own builtin plugin, compinfo-<group>, as compinfo data.
+
 Calling via the CLI
 
 To run this plugin from the command line interface:
@@ -8,6 +21,8 @@ To run this plugin from the command line interface:
 	mirach compinfo
 	# or
 	mirach compinfo --infogroup load
+	# or
+	mirach compinfo -i disk
 
 to get an individual group of information.
 
@@ -0,0 +1,39 @@
+/*
+Package langinfo is a plugin that provides information about the packages of
+language ecosystems installed outside of the operating system's package manager.
+
+It walks a list of roots and reports:
+
+	- Python distributions, from the METADATA of *.dist-info directories and the
+	  PKG-INFO of *.egg-info files and directories
+	- npm packages, from the package.json files within node_modules directories
+	- Ruby gems, from the *.gemspec files of specifications directories
+	- Go modules, from the build information embedded in Go executables,
+	  including the Go release they were built with as stdlib
+	- Maven artifacts, from the pom.properties of jar, war, and ear files, or
+	  else their manifest or file name
+
+Only the roots, which may be glob patterns, are read, less any excluded
+directories, and symbolic links are not followed. The walk is bounded by a time
+budget; when it runs out, the packages found so far are reported and truncated
+is set.
+
+Calling via the CLI
+
+To run this plugin from the command line interface:
+
+	mirach langinfo
+	# or
+	mirach langinfo --root /opt --root '/usr/lib/python3*' --budget 30s
+
+For full usage information run:
+
+	mirach langinfo --help
+
+Calling via the API
+
+To use this plugin via the API:
+
+	langinfo.String()
+*/
+package langinfo
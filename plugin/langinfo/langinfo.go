@@ -0,0 +1,258 @@
+package langinfo
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cleardataeng/mirach/plugin"
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/spf13/afero"
+)
+
+// Ecosystems of the packages found.
+const (
+	EcosystemPyPI  = "pypi"
+	EcosystemNPM   = "npm"
+	EcosystemGem   = "gem"
+	EcosystemGo    = "golang"
+	EcosystemMaven = "maven"
+)
+
+// DefaultBudget is how long the walk of the roots may take by default.
+const DefaultBudget = 2 * time.Minute
+
+const (
+	maxBinarySize   = 256 << 20 // larger executables are not read for Go build info
+	maxArchiveSize  = 256 << 20 // larger jars are not opened
+	nodeModulesPath = string(filepath.Separator) + "node_modules" + string(filepath.Separator)
+)
+
+// DefaultRoots are the directories walked when no roots are configured.
+var DefaultRoots = []string{
+	"/usr/lib/python3*",
+	"/usr/lib64/python3*",
+	"/usr/local/lib",
+	"/usr/local/bin",
+	"/usr/lib/node_modules",
+	"/usr/share/gems",
+	"/var/lib/gems",
+	"/opt",
+	"/srv",
+}
+
+// Exceptions is a list of strings containing error strings that are expected
+// on some systems and should not be reported as failures.
+var Exceptions = []string{
+	"no langinfo roots found",
+}
+
+// LangInfoGroup lists the packages of language ecosystems found under its
+// roots. Roots may be glob patterns; only paths under them are read, less
+// those under Exclude, and symbolic links are not followed. The walk stops
+// once Budget has elapsed, in which case Truncated is set.
+type LangInfoGroup struct {
+	Roots     []string      `json:"roots"`
+	Exclude   []string      `json:"exclude,omitempty"`
+	Budget    time.Duration `json:"-"`
+	Truncated bool          `json:"truncated"`
+	Packages  []Package     `json:"packages"`
+	Errors    []string      `json:"errors,omitempty"`
+}
+
+// Package is a package of a language ecosystem.
+// For Go binaries, the main module and each dependency is a package, and the
+// Go release the binary was built with is reported as the stdlib package.
+type Package struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Path      string `json:"path"` // where the package was found
+}
+
+var errBudget = errors.New("time budget exhausted")
+
+// GetInfo walks the roots, or DefaultRoots if none are set, and records the
+// packages found.
+func (l *LangInfoGroup) GetInfo() {
+	if len(l.Roots) == 0 {
+		l.Roots = DefaultRoots
+	}
+	if l.Budget <= 0 {
+		l.Budget = DefaultBudget
+	}
+	roots, err := expandRoots(l.Roots)
+	if err != nil {
+		panic(err)
+	}
+	if len(roots) == 0 {
+		panic(plugin.ExceptionOrError(errors.New("no langinfo roots found"), Exceptions))
+	}
+	deadline := time.Now().Add(l.Budget)
+	l.Packages = []Package{}
+	for _, root := range roots {
+		if err := l.walk(root, deadline); err == errBudget {
+			l.Truncated = true
+			break
+		} else if err != nil {
+			l.Errors = append(l.Errors, err.Error())
+		}
+	}
+	sortPackages(l.Packages)
+}
+
+// String marshal LangInfoGroup to string and return
+func (l *LangInfoGroup) String() string {
+	s, _ := json.Marshal(l)
+	return string(s)
+}
+
+// GetInfo will load up and return the LangInfoGroup for the default roots.
+func GetInfo() plugin.InfoGroup {
+	l := new(LangInfoGroup)
+	l.GetInfo()
+	return l
+}
+
+// String will load up and return the LangInfoGroup for the default roots as a
+// string.
+func String() string {
+	return GetInfo().String()
+}
+
+// expandRoots expands glob patterns among the roots, leaving out roots that do
+// not exist and those under another root.
+func expandRoots(patterns []string) ([]string, error) {
+	var roots []string
+	for _, p := range patterns {
+		matches, err := afero.Glob(util.Fs, filepath.Clean(p))
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, matches...)
+	}
+	sort.Strings(roots)
+	var out []string
+	for _, r := range roots {
+		if len(out) > 0 && under(r, out[len(out)-1]) {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// under reports whether path is dir or within it.
+func under(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+func (l *LangInfoGroup) excluded(path string) bool {
+	for _, e := range l.Exclude {
+		if under(path, filepath.Clean(e)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *LangInfoGroup) walk(root string, deadline time.Time) error {
+	return afero.Walk(util.Fs, root, func(path string, info os.FileInfo, err error) error {
+		if time.Now().After(deadline) {
+			return errBudget
+		}
+		if err != nil {
+			// Unreadable directories are skipped rather than ending the walk.
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if l.excluded(path) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		pkgs, skip, err := inspect(path, info)
+		if err != nil {
+			l.Errors = append(l.Errors, path+": "+err.Error())
+		}
+		l.Packages = append(l.Packages, pkgs...)
+		if skip {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+// inspect returns the packages described by the file or directory at path,
+// and whether the walk should skip the rest of the directory.
+func inspect(path string, info os.FileInfo) ([]Package, bool, error) {
+	name := info.Name()
+	if info.IsDir() {
+		switch {
+		case strings.HasSuffix(name, ".dist-info"):
+			pkg, err := readPythonMetadata(filepath.Join(path, "METADATA"))
+			return found(pkg, err), true, err
+		case strings.HasSuffix(name, ".egg-info"):
+			pkg, err := readPythonMetadata(filepath.Join(path, "PKG-INFO"))
+			return found(pkg, err), true, err
+		}
+		return nil, false, nil
+	}
+	if !info.Mode().IsRegular() {
+		return nil, false, nil
+	}
+	switch {
+	case strings.HasSuffix(name, ".egg-info"):
+		pkg, err := readPythonMetadata(path)
+		return found(pkg, err), false, err
+	case name == "package.json" && strings.Contains(path, nodeModulesPath):
+		pkg, err := readPackageJSON(path)
+		return found(pkg, err), false, err
+	case strings.HasSuffix(name, ".gemspec") && filepath.Base(filepath.Dir(path)) == "specifications":
+		pkg, err := readGemspec(path)
+		return found(pkg, err), false, err
+	case strings.HasSuffix(name, ".jar") || strings.HasSuffix(name, ".war") || strings.HasSuffix(name, ".ear"):
+		if info.Size() > maxArchiveSize {
+			return nil, false, nil
+		}
+		pkgs, err := readJar(path, info.Size())
+		return pkgs, false, err
+	case info.Mode().Perm()&0111 != 0 && info.Size() <= maxBinarySize:
+		pkgs, _ := readGoBinary(path)
+		return pkgs, false, nil
+	}
+	return nil, false, nil
+}
+
+// found returns pkg as a list, or nothing if it could not be read or has no
+// name, as is the case for package.json files that only set module options.
+func found(pkg Package, err error) []Package {
+	if err != nil || pkg.Name == "" {
+		return nil
+	}
+	return []Package{pkg}
+}
+
+func sortPackages(pkgs []Package) {
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		a, b := pkgs[i], pkgs[j]
+		if a.Ecosystem != b.Ecosystem {
+			return a.Ecosystem < b.Ecosystem
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		return a.Path < b.Path
+	})
+}
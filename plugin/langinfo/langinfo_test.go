@@ -0,0 +1,128 @@
+// +build unit
+
+package langinfo
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func testJar(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := z.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func writeTestTree(t *testing.T) {
+	util.ResetTestFs()
+	util.SetFs(util.TestFs)
+	files := map[string]string{
+		"/usr/lib/python3/dist-packages/requests-2.25.1.dist-info/METADATA":   "Metadata-Version: 2.1\nName: requests\nVersion: 2.25.1\n\nName: not a header\n",
+		"/usr/lib/python3/dist-packages/six-1.16.0.egg-info":                  "Metadata-Version: 1.1\nName: six\nVersion: 1.16.0\n",
+		"/usr/lib/python3/dist-packages/yaml.egg-info/PKG-INFO":               "Name: PyYAML\nVersion: 5.3.1\n",
+		"/usr/lib/node_modules/npm/package.json":                              `{"name": "npm", "version": "9.6.7"}`,
+		"/usr/lib/node_modules/npm/node_modules/semver/package.json":          `{"name": "semver", "version": "7.5.1"}`,
+		"/usr/lib/node_modules/npm/node_modules/semver/dist/package.json":     `{"type": "module"}`,
+		"/usr/lib/node_modules/npm/node_modules/semver/excluded/package.json": `{"name": "excluded", "version": "1.0.0"}`,
+		"/var/lib/gems/3.0.0/specifications/rake-13.0.6.gemspec":              "Gem::Specification.new do |s|\n  s.name = \"rake\".freeze\n  s.version = \"13.0.6\"\nend\n",
+		"/var/lib/gems/3.0.0/specifications/json-2.6.1.gemspec":               "# stub\n",
+		"/srv/app/package.json":                                               `{"name": "app", "version": "0.0.1"}`,
+	}
+	for path, content := range files {
+		if err := afero.WriteFile(util.TestFs, path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	jars := map[string][]byte{
+		"/opt/app/lib/shaded.jar": testJar(t, map[string]string{
+			"META-INF/MANIFEST.MF":                                   "Manifest-Version: 1.0\nImplementation-Title: shaded\n",
+			"META-INF/maven/com.google.guava/guava/pom.properties":   "#Generated\ngroupId=com.google.guava\nartifactId=guava\nversion=31.1-jre\n",
+			"META-INF/maven/org.apache.logging/log4j/pom.properties": "groupId=org.apache.logging.log4j\nartifactId=log4j-core\nversion=2.14.1\n",
+		}),
+		"/opt/app/lib/commons-io-2.11.0.jar": testJar(t, map[string]string{
+			"META-INF/MANIFEST.MF": "Manifest-Version: 1.0\nBundle-SymbolicName: org.apache.commons.c\n ommons-io;singleton:=true\nBundle-Version: 2.11.0\n",
+		}),
+		"/opt/app/lib/plain-1.2.3.jar": testJar(t, map[string]string{"a.class": ""}),
+	}
+	for path, b := range jars {
+		if err := afero.WriteFile(util.TestFs, path, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGetInfo(t *testing.T) {
+	assert := assert.New(t)
+	writeTestTree(t)
+	l := &LangInfoGroup{
+		Roots:   []string{"/usr/lib/python3*", "/usr/lib/node_modules", "/usr/lib/node_modules/npm", "/var/lib/gems", "/opt", "/missing"},
+		Exclude: []string{"/usr/lib/node_modules/npm/node_modules/semver/excluded"},
+	}
+	l.GetInfo()
+	assert.False(l.Truncated)
+	assert.Empty(l.Errors)
+	assert.Equal([]Package{
+		{EcosystemGem, "json", "2.6.1", "/var/lib/gems/3.0.0/specifications/json-2.6.1.gemspec"},
+		{EcosystemGem, "rake", "13.0.6", "/var/lib/gems/3.0.0/specifications/rake-13.0.6.gemspec"},
+		{EcosystemMaven, "com.google.guava:guava", "31.1-jre", "/opt/app/lib/shaded.jar"},
+		{EcosystemMaven, "org.apache.commons.commons-io", "2.11.0", "/opt/app/lib/commons-io-2.11.0.jar"},
+		{EcosystemMaven, "org.apache.logging.log4j:log4j-core", "2.14.1", "/opt/app/lib/shaded.jar"},
+		{EcosystemMaven, "plain", "1.2.3", "/opt/app/lib/plain-1.2.3.jar"},
+		{EcosystemNPM, "npm", "9.6.7", "/usr/lib/node_modules/npm"},
+		{EcosystemNPM, "semver", "7.5.1", "/usr/lib/node_modules/npm/node_modules/semver"},
+		{EcosystemPyPI, "PyYAML", "5.3.1", "/usr/lib/python3/dist-packages"},
+		{EcosystemPyPI, "requests", "2.25.1", "/usr/lib/python3/dist-packages"},
+		{EcosystemPyPI, "six", "1.16.0", "/usr/lib/python3/dist-packages"},
+	}, l.Packages, "/srv is not a root")
+}
+
+func TestGetInfoBudget(t *testing.T) {
+	assert := assert.New(t)
+	writeTestTree(t)
+	l := &LangInfoGroup{Roots: []string{"/"}, Budget: time.Nanosecond}
+	time.Sleep(time.Millisecond)
+	l.GetInfo()
+	assert.True(l.Truncated)
+}
+
+func TestExpandRoots(t *testing.T) {
+	assert := assert.New(t)
+	writeTestTree(t)
+	roots, err := expandRoots([]string{"/var/lib/gems/", "/usr/lib/node_modules/npm", "/usr/lib/node_modules", "/nope*"})
+	assert.NoError(err)
+	assert.Equal([]string{"/usr/lib/node_modules", "/var/lib/gems"}, roots)
+}
+
+func TestReadGoBinary(t *testing.T) {
+	assert := assert.New(t)
+	util.SetFs(util.OSFs)
+	exe, err := os.Executable()
+	assert.NoError(err)
+	pkgs, err := readGoBinary(exe)
+	assert.NoError(err)
+	if assert.NotEmpty(pkgs) {
+		assert.Equal(Package{EcosystemGo, "stdlib", strings.TrimPrefix(runtime.Version(), "go"), exe}, pkgs[0])
+	}
+	_, err = readGoBinary("langinfo.go")
+	assert.Error(err, "not a Go binary")
+}
@@ -0,0 +1,235 @@
+package langinfo
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"debug/buildinfo"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/cleardataeng/mirach/util"
+)
+
+// readPythonMetadata reads the name and version from the headers of a Python
+// distribution's METADATA or PKG-INFO file. The package's path is its
+// site-packages directory.
+func readPythonMetadata(file string) (Package, error) {
+	f, err := util.Fs.Open(file)
+	if err != nil {
+		return Package{}, err
+	}
+	defer f.Close()
+	headers := readHeaders(f)
+	dir := filepath.Dir(file)
+	if strings.HasSuffix(dir, ".dist-info") || strings.HasSuffix(dir, ".egg-info") {
+		dir = filepath.Dir(dir)
+	}
+	return Package{
+		Ecosystem: EcosystemPyPI,
+		Name:      headers["name"],
+		Version:   headers["version"],
+		Path:      dir,
+	}, nil
+}
+
+// readHeaders reads the email style headers at the start of r, up to the first
+// blank line. Header names are returned lower case; continuation lines, which
+// begin with a space, are joined to their header without it, as in jar
+// manifests.
+func readHeaders(r io.Reader) map[string]string {
+	headers := map[string]string{}
+	var last string
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		if line == "" {
+			break
+		}
+		if (line[0] == ' ' || line[0] == '\t') && last != "" {
+			headers[last] += strings.TrimLeft(line, " \t")
+			continue
+		}
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		last = strings.ToLower(strings.TrimSpace(line[:i]))
+		if _, ok := headers[last]; !ok {
+			headers[last] = strings.TrimSpace(line[i+1:])
+		}
+	}
+	return headers
+}
+
+// readPackageJSON reads the name and version from a package.json file. The
+// package's path is its directory.
+func readPackageJSON(file string) (Package, error) {
+	b, err := util.ReadFile(file)
+	if err != nil {
+		return Package{}, err
+	}
+	var p struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(b, &p); err != nil {
+		return Package{}, err
+	}
+	return Package{Ecosystem: EcosystemNPM, Name: p.Name, Version: p.Version, Path: filepath.Dir(file)}, nil
+}
+
+var (
+	gemspecName    = regexp.MustCompile(`(?m)^\s*s\.name\s*=\s*["']([^"']+)["']`)
+	gemspecVersion = regexp.MustCompile(`(?m)^\s*s\.version\s*=\s*["']([^"']+)["']`)
+)
+
+// readGemspec reads the name and version from an installed gem's
+// specification, falling back to its file name, name-version.gemspec.
+func readGemspec(file string) (Package, error) {
+	b, err := util.ReadFile(file)
+	if err != nil {
+		return Package{}, err
+	}
+	pkg := Package{Ecosystem: EcosystemGem, Path: file}
+	pkg.Name, pkg.Version = splitNameVersion(strings.TrimSuffix(filepath.Base(file), ".gemspec"))
+	if m := gemspecName.FindSubmatch(b); m != nil {
+		pkg.Name = string(m[1])
+	}
+	if m := gemspecVersion.FindSubmatch(b); m != nil {
+		pkg.Version = string(m[1])
+	}
+	return pkg, nil
+}
+
+// splitNameVersion splits a file name such as rake-13.0.6 or guava-31.1-jre at
+// the last dash followed by a digit.
+func splitNameVersion(s string) (string, string) {
+	for i := len(s) - 2; i > 0; i-- {
+		if s[i] == '-' && s[i+1] >= '0' && s[i+1] <= '9' {
+			return s[:i], s[i+1:]
+		}
+	}
+	return s, ""
+}
+
+// readJar reads the Maven coordinates of a jar from the pom.properties files
+// it contains; a shaded jar may contain several. Without them, the jar's
+// manifest, and then its file name, give its name and version.
+func readJar(file string, size int64) ([]Package, error) {
+	f, err := util.Fs.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	z, err := zip.NewReader(f, size)
+	if err != nil {
+		return nil, err
+	}
+	var pkgs []Package
+	var manifest map[string]string
+	for _, zf := range z.File {
+		switch {
+		case path.Base(zf.Name) == "pom.properties" && strings.HasPrefix(zf.Name, "META-INF/maven/"):
+			props, err := readZipFile(zf)
+			if err != nil {
+				return nil, err
+			}
+			p := parseProperties(props)
+			if p["artifactId"] == "" {
+				continue
+			}
+			pkgs = append(pkgs, Package{
+				Ecosystem: EcosystemMaven,
+				Name:      p["groupId"] + ":" + p["artifactId"],
+				Version:   p["version"],
+				Path:      file,
+			})
+		case zf.Name == "META-INF/MANIFEST.MF":
+			b, err := readZipFile(zf)
+			if err != nil {
+				return nil, err
+			}
+			manifest = readHeaders(bytes.NewReader(b))
+		}
+	}
+	if len(pkgs) > 0 {
+		return pkgs, nil
+	}
+	pkg := Package{Ecosystem: EcosystemMaven, Path: file}
+	pkg.Name, pkg.Version = splitNameVersion(strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)))
+	if name := manifest["implementation-title"]; name != "" {
+		pkg.Name = name
+	} else if name := manifest["bundle-symbolicname"]; name != "" {
+		pkg.Name = strings.TrimSpace(strings.SplitN(name, ";", 2)[0])
+	}
+	if v := manifest["implementation-version"]; v != "" {
+		pkg.Version = v
+	} else if v := manifest["bundle-version"]; v != "" {
+		pkg.Version = v
+	}
+	return []Package{pkg}, nil
+}
+
+func readZipFile(zf *zip.File) ([]byte, error) {
+	r, err := zf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(io.LimitReader(r, 1<<20))
+}
+
+// parseProperties parses the key=value lines of a Java properties file,
+// ignoring comments.
+func parseProperties(b []byte) map[string]string {
+	props := map[string]string{}
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		if i := strings.IndexAny(line, "=:"); i > 0 {
+			props[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+		}
+	}
+	return props
+}
+
+// readGoBinary reads the build information embedded in a Go binary: its main
+// module, the modules it depends on, replaced as they were built, and the Go
+// release it was built with as the stdlib package. Files that are not Go
+// binaries return an error.
+func readGoBinary(file string) ([]Package, error) {
+	f, err := util.Fs.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	bi, err := buildinfo.Read(f)
+	if err != nil {
+		return nil, err
+	}
+	pkgs := []Package{{
+		Ecosystem: EcosystemGo,
+		Name:      "stdlib",
+		Version:   strings.TrimPrefix(bi.GoVersion, "go"),
+		Path:      file,
+	}}
+	if bi.Main.Path != "" {
+		pkgs = append(pkgs, Package{Ecosystem: EcosystemGo, Name: bi.Main.Path, Version: bi.Main.Version, Path: file})
+	}
+	for _, d := range bi.Deps {
+		if d.Replace != nil {
+			d = d.Replace
+		}
+		pkgs = append(pkgs, Package{Ecosystem: EcosystemGo, Name: d.Path, Version: d.Version, Path: file})
+	}
+	return pkgs, nil
+}
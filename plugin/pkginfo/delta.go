@@ -0,0 +1,91 @@
+package pkginfo
+
+import (
+	"sort"
+
+	"github.com/cleardataeng/mirach/plugin/pkginfo/parsers"
+)
+
+// PkgDelta represents the changes in a PkgStatus since a baseline, which is
+// the snapshot or delta numbered BaseSeq.
+type PkgDelta struct {
+	OS       string
+	Seq      uint64                          `json:"seq"`
+	BaseSeq  uint64                          `json:"base_seq"`
+	Added    map[string]parsers.LinuxPackage `json:"added,omitempty"`
+	Removed  map[string]parsers.LinuxPackage `json:"removed,omitempty"`
+	Upgraded map[string]PkgChange            `json:"upgraded,omitempty"`
+	// Available holds, by group, the available packages that are new or
+	// whose version changed, and NoLongerAvailable those that are gone.
+	Available         map[string]map[string]parsers.LinuxPackage `json:"available,omitempty"`
+	NoLongerAvailable map[string][]string                        `json:"no_longer_available,omitempty"`
+	Advisories        map[string]parsers.Advisory                `json:"advisories,omitempty"` // new advisories
+	Sources           map[string]SourceStatus                    `json:"sources,omitempty"`
+}
+
+// PkgChange is an installed package whose version, usually by an upgrade, or
+// other details changed.
+type PkgChange struct {
+	From parsers.LinuxPackage `json:"from"`
+	To   parsers.LinuxPackage `json:"to"`
+}
+
+// Diff returns the changes from base to cur. Seq and BaseSeq are not set.
+// Sources that cur failed to collect are left out rather than reported as
+// removed, and their status is included.
+func Diff(base, cur *PkgStatus) *PkgDelta {
+	d := &PkgDelta{
+		OS:                cur.OS,
+		Added:             map[string]parsers.LinuxPackage{},
+		Removed:           map[string]parsers.LinuxPackage{},
+		Upgraded:          map[string]PkgChange{},
+		Available:         map[string]map[string]parsers.LinuxPackage{},
+		NoLongerAvailable: map[string][]string{},
+		Advisories:        map[string]parsers.Advisory{},
+		Sources:           cur.Sources,
+	}
+	was, is := base.Packages["installed"], cur.Packages["installed"]
+	if cur.Failed("installed") {
+		was, is = nil, nil
+	}
+	for name, pkg := range is {
+		old, ok := was[name]
+		switch {
+		case !ok:
+			d.Added[name] = pkg
+		case old != pkg:
+			d.Upgraded[name] = PkgChange{From: old, To: pkg}
+		}
+	}
+	for name, pkg := range was {
+		if _, ok := is[name]; !ok {
+			d.Removed[name] = pkg
+		}
+	}
+	for _, group := range []string{"available", "available_security"} {
+		if cur.Failed(group) {
+			continue
+		}
+		was, is := base.Packages[group], cur.Packages[group]
+		for name, pkg := range is {
+			if old, ok := was[name]; !ok || old != pkg {
+				if d.Available[group] == nil {
+					d.Available[group] = map[string]parsers.LinuxPackage{}
+				}
+				d.Available[group][name] = pkg
+			}
+		}
+		for name := range was {
+			if _, ok := is[name]; !ok {
+				d.NoLongerAvailable[group] = append(d.NoLongerAvailable[group], name)
+			}
+		}
+		sort.Strings(d.NoLongerAvailable[group])
+	}
+	for id, adv := range cur.Advisories {
+		if _, ok := base.Advisories[id]; !ok {
+			d.Advisories[id] = adv
+		}
+	}
+	return d
+}
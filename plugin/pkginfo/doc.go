@@ -5,11 +5,69 @@ available packages or KB articles (in the case of Windows).
 It will also provide information about if an update is security related where
 this information is provided.
 
+On Debian based systems, installed packages are read directly from the dpkg
+status database, /var/lib/dpkg/status, and include their architecture, source
+package and its version where it differs from the package's, dpkg status
+flags, and installed size in KiB. Available security updates are found by
+simulating an upgrade from only the security sources in sources.list and the
+*.list and deb822 *.sources files of sources.list.d. Sources are security
+pockets by their suite, such as focal-security, their host, such as
+security.ubuntu.com, or the label of their release file, such as
+Debian-Security. Sources files that cannot be read or parsed, and sources whose
+Signed-By key is given inline, are logged and skipped.
+
+On Red Hat based systems, installed packages are queried from rpm and include
+their epoch, release, architecture, vendor, source package, and install time;
+their version is the full [epoch:]version-release. Installed and available
+packages alike are keyed by name.arch, as yum names them; a package whose name
+and architecture are listed more than once, such as kernel, is reported under
+name-version-release.arch instead.
+
+Alpine (apk), SUSE (zypper), and Arch (pacman) systems are also supported.
+On Alpine, installed packages are read from /lib/apk/db/installed. On SUSE,
+installed packages come from rpm and available security updates are the
+packages that installing the needed security patches would upgrade, from a dry
+run of zypper patch, keyed as on Red Hat based systems. Neither
+apk nor pacman provides security information, so on Alpine and Arch available
+security packages are always empty.
+
+The security advisories behind pending updates are reported under advisories,
+keyed by advisory ID, with their severity, CVEs, and the fixed version of each
+package they update. On Red Hat based systems they come from yum updateinfo,
+such as RHSA and ALAS advisories, and on SUSE from zypper's security patches.
+On Debian based systems they are read from the changelogs of the pending
+security updates, fetched once per source package, at most four at a time and
+for no more than two minutes in all: each entry newer than the installed
+version is an advisory, keyed by the USN, DSA, or DLA ID mentioned in it, or
+else by the source package and version, with the entry's urgency as severity.
+Changelogs are fetched from the network, so air-gapped hosts report no Debian
+advisories. Changelogs that could not be fetched, as is common for packages of
+third-party repositories, are reported as the changelogs source, with the
+packages whose changelogs are missing, leaving the advisories source ok.
+
+The status of collecting each group and the advisories is reported under
+sources, with ok set when it succeeded, and the errors of each source joined
+into at most 1024 bytes. When a package manager command failed, the status has
+the command, its exit code, the start of its standard error, its duration in
+seconds, the number of attempts, and lock_contention when it failed because
+another process, such as an upgrade, held the package database lock. Commands
+that fail for lack of the lock are retried after 5, 15, and 30 seconds before
+giving up.
+
+The installed packages can also be exported as a software bill of materials in
+CycloneDX 1.5 or SPDX 2.3 json. Packages are identified by package urls of type
+deb, rpm, apk, or alpm, namespaced by the distribution, with the architecture,
+the distribution and release as distro, the source package as upstream, and,
+for rpm, a non-zero epoch as qualifiers. The operating system is described
+from compinfo's host information.
+
 Calling via the CLI
 
 To run this plugin from the command line interface:
 
 	mirach pkginfo
+	# or
+	mirach pkginfo --format cyclonedx
 
 For full usage information run:
 
@@ -20,6 +78,8 @@ Calling via the API
 To use this plugin via the API:
 
 	pkginfo.String()
+	// or
+	pkginfo.SBOMString(pkginfo.SBOMSPDX)
 
 There are several other ways to call via the API, but this is the most simple
 and will include all information collected.
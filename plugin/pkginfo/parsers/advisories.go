@@ -0,0 +1,325 @@
+package parsers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Advisory represents a security advisory behind pending updates.
+type Advisory struct {
+	id       string
+	Severity string            `json:"severity,omitempty"`
+	CVEs     []string          `json:"cves,omitempty"`
+	Packages map[string]string `json:"packages,omitempty"` // package name to fixed version
+}
+
+var (
+	cveRE      = regexp.MustCompile(`CVE-\d{4}-\d{4,}`)
+	debAdvisRE = regexp.MustCompile(`\b(USN|DSA|DLA)-\d+(-\d+)?\b`)
+)
+
+// GetYumAdvisories creates map of security advisories for pending updates from yum updateinfo, keyed by advisory ID such as RHSA-2022:1065 or ALAS-2022-1234.
+func GetYumAdvisories() (map[string]Advisory, error) {
+	list, err := yumUpdateinfo("list")
+	if err != nil {
+		return nil, err
+	}
+	info, err := yumUpdateinfo("info")
+	if err != nil {
+		return nil, err
+	}
+	advs := parseYumUpdateinfoList(list)
+	for id, cves := range parseYumUpdateinfoCVEs(info) {
+		if adv, ok := advs[id]; ok {
+			adv.CVEs = cves
+			advs[id] = adv
+		}
+	}
+	return advs, nil
+}
+
+func yumUpdateinfo(sub string) ([]byte, error) {
+	return pkgCmd{name: "yum", args: []string{"-q", "updateinfo", sub, "security"}}.run()
+}
+
+// parseYumUpdateinfoList parses the output of yum updateinfo list security,
+// lines such as "RHSA-2022:1065 Important/Sec. openssl-1:1.1.1k-6.el8_5.x86_64".
+func parseYumUpdateinfoList(b []byte) map[string]Advisory {
+	advs := map[string]Advisory{}
+	for _, line := range strings.Split(string(b), "\n") {
+		f := strings.Fields(line)
+		if len(f) != 3 {
+			continue
+		}
+		name, evr, ok := splitNEVRA(f[2])
+		if !ok {
+			continue
+		}
+		adv, ok := advs[f[0]]
+		if !ok {
+			adv = Advisory{id: f[0], Packages: map[string]string{}}
+			if i := strings.Index(f[1], "/"); i > 0 {
+				adv.Severity = strings.ToLower(f[1][:i])
+			}
+		}
+		adv.Packages[name] = evr
+		advs[f[0]] = adv
+	}
+	return advs
+}
+
+// parseYumUpdateinfoCVEs parses the output of yum updateinfo info security
+// and returns the CVEs of each advisory.
+func parseYumUpdateinfoCVEs(b []byte) map[string][]string {
+	cves := map[string][]string{}
+	var id, key string
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		i := strings.Index(s.Text(), ":")
+		if i < 0 {
+			continue
+		}
+		if k := strings.TrimSpace(s.Text()[:i]); k != "" {
+			key = k
+		}
+		v := s.Text()[i+1:]
+		switch key {
+		case "Update ID":
+			id = strings.TrimSpace(v)
+		case "CVEs":
+			if id != "" {
+				cves[id] = append(cves[id], cveRE.FindAllString(v, -1)...)
+			}
+		}
+	}
+	return cves
+}
+
+// splitNEVRA splits a name-[epoch:]version-release.arch string into the name
+// and [epoch:]version-release.
+func splitNEVRA(nevra string) (string, string, bool) {
+	i := strings.LastIndex(nevra, ".")
+	if i < 0 {
+		return "", "", false
+	}
+	nevr := nevra[:i]
+	name := trimVersionRelease(nevr)
+	if name == nevr {
+		return "", "", false
+	}
+	return name, nevr[len(name)+1:], true
+}
+
+// GetZypperAdvisories creates map of needed security patches from zypper, keyed by patch name, with the CVEs they address.
+func GetZypperAdvisories() (map[string]Advisory, error) {
+	out, err := zypper("list-patches", "--category", "security")
+	if err != nil {
+		return nil, err
+	}
+	return parseZypperAdvisories(out)
+}
+
+func parseZypperAdvisories(b []byte) (map[string]Advisory, error) {
+	var stream zypperStream
+	if err := xml.Unmarshal(b, &stream); err != nil {
+		return nil, err
+	}
+	advs := map[string]Advisory{}
+	for _, u := range stream.Updates {
+		if u.Kind != "patch" || u.Status != "" && u.Status != "needed" {
+			continue
+		}
+		adv := Advisory{id: u.Name, Severity: strings.ToLower(u.Severity)}
+		for _, issue := range u.Issues {
+			if issue.Type == "cve" {
+				adv.CVEs = append(adv.CVEs, issue.ID)
+			}
+		}
+		advs[u.Name] = adv
+	}
+	return advs, nil
+}
+
+// aptChangelogBudget bounds the time GetAptAdvisories spends fetching
+// changelogs, aptChangelogTimeout each fetch, and aptChangelogWorkers how many
+// are fetched at once. Changelogs are fetched over the network, so on hosts
+// that cannot reach it each fetch fails or times out.
+var (
+	aptChangelogBudget  = 2 * time.Minute
+	aptChangelogTimeout = 30 * time.Second
+	aptChangelogWorkers = 4
+)
+
+// aptChangelog fetches the changelog of the candidate version of a package.
+var aptChangelog = func(name string) ([]byte, error) {
+	return pkgCmd{name: "apt-get", args: []string{"changelog", "-qq", name}, timeout: aptChangelogTimeout}.run()
+}
+
+// GetAptAdvisories creates map of security advisories for the available security packages from their apt changelogs, given packages as returned by GetAptDpkgPkgs. The changelog of each source package is fetched once, and no fetch is started after aptChangelogBudget. Each changelog entry newer than the installed version is an advisory, keyed by the USN, DSA or DLA ID in the entry, or else by source package and version. Severity is the entry's urgency. Changelogs that could not be fetched, as is routine for packages of third-party repositories, are errors of the changelogs source naming the packages of their source package, leaving the advisories of the others.
+func GetAptAdvisories(pkgs map[string]map[string]LinuxPackage) (map[string]Advisory, []error) {
+	errors := []error{}
+	advs := map[string]Advisory{}
+	bins := map[string][]string{} // source package to binary packages
+	for name := range pkgs["available_security"] {
+		src := pkgs["installed"][name].Source
+		if src == "" {
+			src = name
+		}
+		bins[src] = append(bins[src], name)
+	}
+	srcs := make([]string, 0, len(bins))
+	for src := range bins {
+		sort.Strings(bins[src])
+		srcs = append(srcs, src)
+	}
+	sort.Strings(srcs)
+
+	type result struct {
+		out     []byte
+		err     error
+		fetched bool
+	}
+	results := make([]result, len(srcs))
+	deadline := time.Now().Add(aptChangelogBudget)
+	sem := make(chan struct{}, aptChangelogWorkers)
+	var wg sync.WaitGroup
+	for i, src := range srcs {
+		sem <- struct{}{}
+		if time.Now().After(deadline) {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(i int, name string) {
+			defer func() { <-sem; wg.Done() }()
+			// The changelog is that of the candidate version, the fixed version.
+			out, err := aptChangelog(name)
+			results[i] = result{out: out, err: err, fetched: true}
+		}(i, bins[src][0])
+	}
+	wg.Wait()
+
+	var skipped []string // binary packages of the source packages not fetched
+	skippedSrcs := 0
+	for i, src := range srcs {
+		r := results[i]
+		if !r.fetched {
+			skipped = append(skipped, bins[src]...)
+			skippedSrcs++
+			continue
+		}
+		if r.err != nil {
+			errors = append(errors, &SourceError{Source: "changelogs", Err: r.err, Packages: bins[src]})
+			continue
+		}
+		// The changelog is of the source package, so is compared with its version.
+		inst := pkgs["installed"][bins[src][0]]
+		installed := inst.Version
+		if inst.SourceVersion != "" {
+			installed = inst.SourceVersion
+		}
+		for _, adv := range parseDebChangelog(r.out, installed) {
+			if a, ok := advs[adv.id]; ok {
+				adv.CVEs = mergeStrings(a.CVEs, adv.CVEs)
+				adv.Packages = a.Packages
+			}
+			for _, name := range bins[src] {
+				// The newest entry of an advisory is its fixed version.
+				if _, ok := adv.Packages[name]; !ok {
+					adv.Packages[name] = adv.fixed
+				}
+			}
+			advs[adv.id] = adv.Advisory
+		}
+	}
+	if skippedSrcs > 0 {
+		err := fmt.Errorf("changelogs of %d source packages not fetched within %s", skippedSrcs, aptChangelogBudget)
+		errors = append(errors, &SourceError{Source: "changelogs", Err: err, Packages: skipped})
+	}
+	return advs, errors
+}
+
+// debAdvisory is an advisory of a changelog entry and the entry's version.
+type debAdvisory struct {
+	Advisory
+	fixed string
+}
+
+// parseDebChangelog returns an advisory for each changelog entry newer than
+// the installed version, newest first, keyed by the USN, DSA or DLA ID in the
+// entry, or else by source and version. Entries with neither an advisory ID nor
+// a CVE are left out, unless none has one, in which case the newest entry is
+// returned. The advisories' Packages are empty.
+func parseDebChangelog(b []byte, installed string) []debAdvisory {
+	var entries []debAdvisory
+	var source string
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := s.Text()
+		if line != "" && line[0] != ' ' && strings.Contains(line, "(") {
+			// An entry's header: source (version) distributions; urgency=...
+			f := strings.Fields(line)
+			if len(f) < 2 {
+				continue
+			}
+			v := strings.Trim(f[1], "()")
+			if installed != "" && CompareDebVersions(v, installed) <= 0 {
+				break
+			}
+			source = f[0]
+			e := debAdvisory{Advisory: Advisory{Packages: map[string]string{}}, fixed: v}
+			if i := strings.Index(line, "urgency="); i >= 0 {
+				e.Severity = strings.TrimSpace(line[i+len("urgency="):])
+			}
+			entries = append(entries, e)
+			continue
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		e := &entries[len(entries)-1]
+		e.CVEs = mergeStrings(e.CVEs, cveRE.FindAllString(line, -1))
+		if e.id == "" {
+			e.id = debAdvisRE.FindString(line)
+		}
+	}
+	var advs []debAdvisory
+	for _, e := range entries {
+		if e.id != "" || len(e.CVEs) > 0 {
+			advs = append(advs, e)
+		}
+	}
+	if len(advs) == 0 && len(entries) > 0 {
+		advs = entries[:1]
+	}
+	for i := range advs {
+		if advs[i].id == "" {
+			advs[i].id = source + "_" + advs[i].fixed
+		}
+	}
+	return advs
+}
+
+// mergeStrings appends the strings in b not already in a.
+func mergeStrings(a, b []string) []string {
+	for _, s := range b {
+		found := false
+		for _, t := range a {
+			if s == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			a = append(a, s)
+		}
+	}
+	return a
+}
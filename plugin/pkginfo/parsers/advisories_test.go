@@ -0,0 +1,166 @@
+// +build unit
+
+package parsers
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseYumUpdateinfo(t *testing.T) {
+	assert := assert.New(t)
+	list := "RHSA-2022:1065 Important/Sec. openssl-1:1.1.1k-6.el8_5.x86_64\n" +
+		"RHSA-2022:1065 Important/Sec. openssl-libs-1:1.1.1k-6.el8_5.x86_64\n" +
+		"ALAS-2022-1234 medium/Sec.    curl-7.79.1-2.amzn2.0.1.x86_64\n"
+	advs := parseYumUpdateinfoList([]byte(list))
+	assert.Len(advs, 2)
+	assert.Equal(Advisory{
+		id:       "RHSA-2022:1065",
+		Severity: "important",
+		Packages: map[string]string{"openssl": "1:1.1.1k-6.el8_5", "openssl-libs": "1:1.1.1k-6.el8_5"},
+	}, advs["RHSA-2022:1065"])
+	assert.Equal(map[string]string{"curl": "7.79.1-2.amzn2.0.1"}, advs["ALAS-2022-1234"].Packages)
+
+	info := `===============================================================================
+  Important: openssl security update
+===============================================================================
+  Update ID: RHSA-2022:1065
+       Type: security
+       Bugs: 2062202 - CVE-2022-0778 openssl: Infinite loop in BN_mod_sqrt()
+       CVEs: CVE-2022-0778
+           : CVE-2022-0779
+Description: OpenSSL is a toolkit that implements the Secure Sockets Layer.
+   Severity: Important
+
+===============================================================================
+  curl security update
+===============================================================================
+  Update ID : ALAS-2022-1234
+       CVEs : CVE-2022-22576
+`
+	cves := parseYumUpdateinfoCVEs([]byte(info))
+	assert.Equal([]string{"CVE-2022-0778", "CVE-2022-0779"}, cves["RHSA-2022:1065"])
+	assert.Equal([]string{"CVE-2022-22576"}, cves["ALAS-2022-1234"])
+}
+
+func TestParseZypperAdvisories(t *testing.T) {
+	assert := assert.New(t)
+	out := `<stream><update-status version="0.6"><update-list>
+<update kind="patch" name="SUSE-SLE-Module-Basesystem-15-SP4-2022-4148" edition="1" status="needed" category="security" severity="important">
+<issue-list><issue type="bugzilla" id="1205476"/><issue type="cve" id="CVE-2022-4304"/></issue-list>
+</update>
+<update kind="patch" name="SUSE-2022-3999" status="applied" category="security" severity="moderate"/>
+</update-list></update-status></stream>`
+	advs, err := parseZypperAdvisories([]byte(out))
+	assert.NoError(err)
+	assert.Len(advs, 1)
+	assert.Equal(Advisory{
+		id:       "SUSE-SLE-Module-Basesystem-15-SP4-2022-4148",
+		Severity: "important",
+		CVEs:     []string{"CVE-2022-4304"},
+	}, advs["SUSE-SLE-Module-Basesystem-15-SP4-2022-4148"])
+}
+
+func TestParseDebChangelog(t *testing.T) {
+	assert := assert.New(t)
+	changelog := `openssl (1.1.1f-1ubuntu2.17) focal-security; urgency=medium
+
+  * SECURITY UPDATE: Infinite loop in BN_mod_sqrt() (USN-5328-1)
+    - debian/patches/CVE-2022-0778.patch: fix loop.
+    - CVE-2022-0778
+
+ -- Marc Deslauriers <marc.deslauriers@ubuntu.com>  Tue, 15 Mar 2022 07:46:29 -0400
+
+openssl (1.1.1f-1ubuntu2.16) focal-security; urgency=medium
+
+  * SECURITY UPDATE: timing attack (CVE-2022-4304)
+
+ -- Marc Deslauriers <marc.deslauriers@ubuntu.com>  Tue, 08 Feb 2022 07:46:29 -0400
+
+openssl (1.1.1f-1ubuntu2.9) focal-security; urgency=low
+
+  * SECURITY UPDATE: older fix (CVE-2021-3711)
+`
+	advs := parseDebChangelog([]byte(changelog), "1.1.1f-1ubuntu2.16")
+	if assert.Len(advs, 1, "entries up to the installed version") {
+		assert.Equal("USN-5328-1", advs[0].id)
+		assert.Equal("1.1.1f-1ubuntu2.17", advs[0].fixed)
+		assert.Equal("medium", advs[0].Severity)
+		assert.Equal([]string{"CVE-2022-0778"}, advs[0].CVEs)
+	}
+	advs = parseDebChangelog([]byte(changelog), "1.1.1f-1ubuntu2.10")
+	if assert.Len(advs, 2, "installed version need not appear") {
+		assert.Equal([]string{"CVE-2022-0778"}, advs[0].CVEs, "one advisory per entry")
+		assert.Equal("openssl_1.1.1f-1ubuntu2.16", advs[1].id)
+		assert.Equal([]string{"CVE-2022-4304"}, advs[1].CVEs)
+	}
+	advs = parseDebChangelog([]byte("zlib (1:1.2.11.dfsg-2+deb11u2) bullseye-security; urgency=high\n\n  * Fix build\n"), "")
+	if assert.Len(advs, 1) {
+		assert.Equal("zlib_1:1.2.11.dfsg-2+deb11u2", advs[0].id, "source and version without an advisory ID")
+		assert.Equal("high", advs[0].Severity)
+	}
+}
+
+func TestGetAptAdvisories(t *testing.T) {
+	assert := assert.New(t)
+	defer func(f func(string) ([]byte, error), budget time.Duration) {
+		aptChangelog, aptChangelogBudget = f, budget
+	}(aptChangelog, aptChangelogBudget)
+	var mu sync.Mutex
+	var fetched []string
+	aptChangelog = func(name string) ([]byte, error) {
+		mu.Lock()
+		fetched = append(fetched, name)
+		mu.Unlock()
+		return []byte("openssl (1.1.1f-1ubuntu2.17) focal-security; urgency=medium\n\n  * Fix (USN-5328-1) CVE-2022-0778\n"), nil
+	}
+	pkgs := map[string]map[string]LinuxPackage{
+		"installed": {
+			"openssl":    {name: "openssl", Version: "1.1.1f-1ubuntu2.16", Source: "openssl"},
+			"libssl1.1":  {name: "libssl1.1", Version: "1.1.1f-1ubuntu2.16", Source: "openssl"},
+			"libssl-dev": {name: "libssl-dev", Version: "1.1.1f-1ubuntu2.16", Source: "openssl"},
+		},
+		"available_security": {
+			"openssl":   {name: "openssl", Version: "1.1.1f-1ubuntu2.17"},
+			"libssl1.1": {name: "libssl1.1", Version: "1.1.1f-1ubuntu2.17"},
+		},
+	}
+	advs, errs := GetAptAdvisories(pkgs)
+	assert.Empty(errs)
+	assert.Equal([]string{"libssl1.1"}, fetched, "one changelog per source package")
+	assert.Equal(map[string]string{
+		"libssl1.1": "1.1.1f-1ubuntu2.17",
+		"openssl":   "1.1.1f-1ubuntu2.17",
+	}, advs["USN-5328-1"].Packages)
+
+	// A binNMU's version is newer than its source's, which the changelog has.
+	pkgs["installed"]["libssl1.1"] = LinuxPackage{name: "libssl1.1", Version: "1.1.1f-1ubuntu2.17+b1", SourceVersion: "1.1.1f-1ubuntu2.16", Source: "openssl"}
+	advs, errs = GetAptAdvisories(pkgs)
+	assert.Empty(errs)
+	assert.Contains(advs, "USN-5328-1", "compared with the source version")
+
+	aptChangelogBudget = -time.Second
+	fetched = nil
+	advs, errs = GetAptAdvisories(pkgs)
+	assert.Empty(fetched)
+	assert.Empty(advs)
+	if assert.Len(errs, 1) {
+		assert.Equal("changelogs", errs[0].(*SourceError).Source)
+		assert.Contains(errs[0].Error(), "changelogs of 1 source packages not fetched")
+		assert.Equal([]string{"libssl1.1", "openssl"}, errs[0].(*SourceError).Packages)
+	}
+
+	aptChangelogBudget = time.Minute
+	aptChangelog = func(name string) ([]byte, error) {
+		return nil, errors.New("exit status 100")
+	}
+	advs, errs = GetAptAdvisories(pkgs)
+	assert.Empty(advs)
+	if assert.Len(errs, 1) {
+		assert.Equal([]string{"libssl1.1", "openssl"}, errs[0].(*SourceError).Packages, "packages whose changelog failed")
+	}
+}
@@ -0,0 +1,117 @@
+package parsers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cleardataeng/mirach/util"
+)
+
+// apkInstalledPath is the apk database of installed packages.
+const apkInstalledPath = "/lib/apk/db/installed"
+
+// GetApkPkgs creates map of available, installed and available security packages from apk as well as a list of errors that occurred generating that list. apk has no security metadata, so available security packages are always empty.
+func GetApkPkgs() (map[string]map[string]LinuxPackage, []error) {
+	errors := []error{}
+	out := make(map[string]map[string]LinuxPackage)
+	avail, err := getApkAvailablePackages()
+	errors = sourceErrors(errors, "available", err)
+	out["available"] = avail
+	out["available_security"] = map[string]LinuxPackage{}
+	installed, err := getApkInstalledPackages()
+	errors = sourceErrors(errors, "installed", err)
+	out["installed"] = installed
+	return out, errors
+}
+
+func getApkInstalledPackages() (map[string]LinuxPackage, error) {
+	f, err := util.Fs.Open(apkInstalledPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseApkInstalled(f)
+}
+
+// parseApkInstalled parses the apk installed database, in which each package
+// is a paragraph of lines of a one letter field, a colon, and a value.
+func parseApkInstalled(r io.Reader) (map[string]LinuxPackage, error) {
+	pkgs := map[string]LinuxPackage{}
+	var pkg LinuxPackage
+	add := func() {
+		if pkg.name != "" {
+			pkgs[pkg.name] = pkg
+		}
+		pkg = LinuxPackage{}
+	}
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" {
+			add()
+			continue
+		}
+		if len(line) < 2 || line[1] != ':' {
+			continue
+		}
+		v := line[2:]
+		switch line[0] {
+		case 'P':
+			pkg.name = v
+		case 'V':
+			pkg.Version = v
+		case 'A':
+			pkg.Architecture = v
+		case 'o':
+			pkg.Source = v
+		case 'I':
+			size, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: bad installed size %q", pkg.name, v)
+			}
+			pkg.InstalledSize = size / 1024
+		}
+	}
+	add()
+	return pkgs, s.Err()
+}
+
+func getApkAvailablePackages() (map[string]LinuxPackage, error) {
+	out, err := pkgCmd{name: "apk", args: []string{"version", "-l", "<"}}.run()
+	if err != nil {
+		return nil, err
+	}
+	return parseApkVersion(out), nil
+}
+
+// parseApkVersion parses the output of apk version -l '<', lines such as
+// "busybox-1.35.0-r17   < 1.35.0-r18", into the available versions.
+func parseApkVersion(b []byte) map[string]LinuxPackage {
+	pkgs := map[string]LinuxPackage{}
+	for _, line := range strings.Split(string(b), "\n") {
+		f := strings.Fields(line)
+		if len(f) != 3 || f[1] != "<" {
+			continue
+		}
+		name := trimVersionRelease(f[0])
+		pkgs[name] = LinuxPackage{name: name, Version: f[2]}
+	}
+	return pkgs
+}
+
+// trimVersionRelease returns the name from a name-version-release string.
+func trimVersionRelease(nvr string) string {
+	s := nvr
+	for i := 0; i < 2; i++ {
+		j := strings.LastIndex(s, "-")
+		if j < 1 {
+			return nvr
+		}
+		s = s[:j]
+	}
+	return s
+}
@@ -0,0 +1,42 @@
+// +build unit
+
+package parsers
+
+import (
+	"testing"
+
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetApkInstalledPackages(t *testing.T) {
+	assert := assert.New(t)
+	util.ResetTestFs()
+	util.SetFs(util.TestFs)
+	db, err := afero.ReadFile(util.OSFs, "../../../test_resources/pkginfo/apk_installed")
+	assert.NoError(err)
+	assert.NoError(afero.WriteFile(util.TestFs, apkInstalledPath, db, 0644))
+	pkgs, err := getApkInstalledPackages()
+	assert.NoError(err)
+	assert.Len(pkgs, 3)
+	assert.Equal(LinuxPackage{
+		name:          "libcrypto3",
+		Version:       "3.0.7-r0",
+		Architecture:  "x86_64",
+		Source:        "openssl",
+		InstalledSize: 4108,
+	}, pkgs["libcrypto3"])
+	assert.Equal("1.35.0-r17", pkgs["busybox"].Version, "last paragraph without blank line")
+}
+
+func TestParseApkVersion(t *testing.T) {
+	assert := assert.New(t)
+	out := "Installed:                                Available:\n" +
+		"busybox-1.35.0-r17                      < 1.35.0-r18\n" +
+		"ssl_client-1.35.0-r17                   < 1.35.0-r18\n"
+	pkgs := parseApkVersion([]byte(out))
+	assert.Len(pkgs, 2)
+	assert.Equal(LinuxPackage{name: "ssl_client", Version: "1.35.0-r18"}, pkgs["ssl_client"])
+}
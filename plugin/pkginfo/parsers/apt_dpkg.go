@@ -1,100 +1,103 @@
 package parsers
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
-	"os/exec"
+	"strings"
 )
 
-// GetAptDpkgPkgs creates map of available, installed and available security packages from aptitude and dpkg as well as a list of errors that occurred generating that list. grep returns exit status 1 when it gets no match, errors like that are fine
+// GetAptDpkgPkgs creates map of available, installed and available security packages from apt and dpkg as well as a list of errors that occurred generating that list.
 func GetAptDpkgPkgs() (map[string]map[string]LinuxPackage, []error) {
 	errors := []error{}
 	out := make(map[string]map[string]LinuxPackage)
 	avail, err := getAptAvailablePackages()
-	if err != nil {
-		errors = append(errors, err)
-	}
+	errors = sourceErrors(errors, "available", err)
 	out["available"] = avail
-	if err := getAptitudeSecurityList(); err != nil {
-		errors = append(errors, err)
-	}
-	defer cleanUpSecurityList()
 	availSec, err := getAptAvailableSecurityPackages()
-	if err != nil {
-		errors = append(errors, err)
-	}
+	errors = sourceErrors(errors, "available_security", err)
 	out["available_security"] = availSec
 	installed, err := getDpkgInstalledPackages()
-	if err != nil {
-		errors = append(errors, err)
-	}
+	errors = sourceErrors(errors, "installed", err)
 	out["installed"] = installed
 	return out, errors
 }
 
-func getDpkgInstalledPackages() (map[string]LinuxPackage, error) {
-	aptget := command("dpkg -l")
-	sed := exec.Command("sed", "1,/^+++/d")
-	awk := exec.Command("awk", "{{ print $2 , $3 }}")
-	stdout, _, err := pipeline(aptget, sed, awk)
-	if err != nil {
-		return nil, err
-	}
-	return parsePacakgesFromBytes(stdout, false)
-}
-
 func getAptAvailablePackages() (map[string]LinuxPackage, error) {
-	aptget := command("apt-get upgrade -qq --just-print")
-	grep := command("grep Inst")
-	awk := exec.Command("awk", "{{ print $2 , $3 }}")
-
-	stdout, _, err := pipeline(aptget, grep, awk)
+	out, err := aptGetUpgrade()
 	if err != nil {
 		return nil, err
 	}
-	return parsePacakgesFromBytes(stdout, false)
+	return parseAptInst(out, false), nil
 }
 
+// getAptAvailableSecurityPackages simulates an upgrade from only the security
+// sources, written to a private temporary sources file.
 func getAptAvailableSecurityPackages() (map[string]LinuxPackage, error) {
-	aptget := command("apt-get upgrade -oDir::Etc::Sourcelist=/tmp/security.list -oDir::Etc::Sourceparts='-' -oDir::Etc::Vendorlist='-' -oDir::Etc::Vendorparts='-' -qq --just-print")
-	grep := command("grep Inst")
-	awk := exec.Command("awk", "{{ print $2 , $3 }}")
-	stdout, _, err := pipeline(aptget, grep, awk)
+	sources, err := getAptSecuritySources()
 	if err != nil {
 		return nil, err
 	}
-	return parsePacakgesFromBytes(stdout, true)
-}
-
-func getAptitudeSecurityList() error {
-	cmd := command("grep security /etc/apt/sources.list")
-	outfile, err := os.Create("/tmp/security.list")
-	if err != nil {
-		return err
+	if len(sources) == 0 {
+		return map[string]LinuxPackage{}, nil
 	}
-	defer outfile.Close()
-	cmd.Stdout = outfile
-	err = cmd.Start()
+	// TempFile creates a new file, readable only by its owner, that cannot
+	// already exist or be a link.
+	f, err := ioutil.TempFile("", "mirach-security-*.list")
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	err = cmd.Wait()
+	defer os.Remove(f.Name())
+	for _, s := range sources {
+		fmt.Fprintln(f, s)
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	out, err := aptGetUpgrade(
+		"-oDir::Etc::Sourcelist="+f.Name(),
+		"-oDir::Etc::Sourceparts=-",
+		"-oDir::Etc::Vendorlist=-",
+		"-oDir::Etc::Vendorparts=-",
+	)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return parseAptInst(out, true), nil
 }
 
-func cleanUpSecurityList() error {
-	cmd := command("rm /tmp/security.list")
-	err := cmd.Start()
-	if err != nil {
-		return err
-	}
+// aptGetUpgrade simulates an upgrade with the given options.
+func aptGetUpgrade(opts ...string) ([]byte, error) {
+	return pkgCmd{
+		name: "apt-get",
+		args: append([]string{"upgrade", "-qq", "--just-print"}, opts...),
+		env:  []string{"LC_ALL=C"},
+	}.run()
+}
 
-	err = cmd.Wait()
-	if err != nil {
-		return err
+// parseAptInst parses the Inst lines of a simulated upgrade, such as
+// "Inst openssl [1.1.1f-1ubuntu2.16] (1.1.1f-1ubuntu2.17 Ubuntu:20.04/focal-security [amd64])",
+// into the versions to be installed.
+func parseAptInst(b []byte, security bool) map[string]LinuxPackage {
+	pkgs := map[string]LinuxPackage{}
+	for _, line := range strings.Split(string(b), "\n") {
+		if !strings.HasPrefix(line, "Inst ") {
+			continue
+		}
+		f := strings.Fields(line)
+		i := strings.Index(line, "(")
+		if len(f) < 3 || i < 0 {
+			continue
+		}
+		cand := strings.Fields(strings.Replace(line[i+1:], ")", " ", 1))
+		if len(cand) == 0 {
+			continue
+		}
+		pkgs[f[1]] = LinuxPackage{
+			name:     f[1],
+			Version:  cand[0],
+			Security: security,
+		}
 	}
-	return nil
+	return pkgs
 }
@@ -0,0 +1,247 @@
+package parsers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/spf13/afero"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// Locations of apt's sources and downloaded lists.
+const (
+	aptSourcesList  = "/etc/apt/sources.list"
+	aptSourcesParts = "/etc/apt/sources.list.d"
+	aptListsDir     = "/var/lib/apt/lists"
+)
+
+// aptSource is a single source of packages: one type, uri, and suite.
+type aptSource struct {
+	Type       string
+	URI        string
+	Suite      string
+	Components []string
+	Options    map[string]string
+}
+
+// String returns the source in one line format.
+func (s aptSource) String() string {
+	parts := []string{s.Type}
+	if len(s.Options) > 0 {
+		var opts []string
+		for k, v := range s.Options {
+			opts = append(opts, k+"="+v)
+		}
+		sort.Strings(opts)
+		parts = append(parts, "["+strings.Join(opts, " ")+"]")
+	}
+	parts = append(parts, s.URI, s.Suite)
+	parts = append(parts, s.Components...)
+	return strings.Join(parts, " ")
+}
+
+// getAptSources returns the sources in sources.list and in the *.list and
+// *.sources files of sources.list.d. Files that cannot be read or parsed are
+// logged and skipped, as apt itself would report them.
+func getAptSources() ([]aptSource, error) {
+	var sources []aptSource
+	files := []string{aptSourcesList}
+	for _, pattern := range []string{"*.list", "*.sources"} {
+		matches, err := afero.Glob(util.Fs, filepath.Join(aptSourcesParts, pattern))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	for _, path := range files {
+		f, err := util.Fs.Open(path)
+		if err != nil {
+			if path != aptSourcesList {
+				// sources.list may be missing on systems using only deb822 sources.
+				jww.ERROR.Printf("pkginfo: skipping apt sources %s: %s", path, err)
+			}
+			continue
+		}
+		var s []aptSource
+		if strings.HasSuffix(path, ".sources") {
+			s, err = parseAptDeb822Sources(f)
+		} else {
+			s, err = parseAptOneLineSources(f)
+		}
+		f.Close()
+		if err != nil {
+			jww.ERROR.Printf("pkginfo: skipping apt sources %s: %s", path, err)
+			continue
+		}
+		sources = append(sources, s...)
+	}
+	return sources, nil
+}
+
+// parseAptOneLineSources parses sources in one line format, such as
+// "deb [arch=amd64] http://archive.ubuntu.com/ubuntu focal-security main".
+func parseAptOneLineSources(r io.Reader) ([]aptSource, error) {
+	var sources []aptSource
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		src := aptSource{Options: map[string]string{}}
+		i := strings.IndexAny(line, " \t")
+		if i < 0 {
+			return nil, fmt.Errorf("malformed source: %q", line)
+		}
+		src.Type, line = line[:i], strings.TrimSpace(line[i:])
+		if strings.HasPrefix(line, "[") {
+			j := strings.Index(line, "]")
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated options: %q", line)
+			}
+			for _, opt := range strings.Fields(line[1:j]) {
+				if kv := strings.SplitN(opt, "=", 2); len(kv) == 2 {
+					src.Options[kv[0]] = kv[1]
+				}
+			}
+			line = line[j+1:]
+		}
+		f := strings.Fields(line)
+		if len(f) < 2 {
+			return nil, fmt.Errorf("malformed source: %q", s.Text())
+		}
+		src.URI, src.Suite, src.Components = f[0], f[1], f[2:]
+		sources = append(sources, src)
+	}
+	return sources, s.Err()
+}
+
+// aptOneLineOptions maps deb822 option names to those of the one line format
+// where they differ.
+var aptOneLineOptions = map[string]string{
+	"architectures": "arch",
+	"languages":     "lang",
+	"targets":       "target",
+}
+
+// parseAptDeb822Sources parses sources in deb822 format, in which a paragraph
+// may give several types, uris, and suites. Paragraphs with an option of more
+// than one line, such as a Signed-By key given inline, cannot be written in one
+// line format; as leaving the option out would change how the source is
+// trusted, they are logged and skipped.
+func parseAptDeb822Sources(r io.Reader) ([]aptSource, error) {
+	paras, err := parseDeb822(r)
+	if err != nil {
+		return nil, err
+	}
+	var sources []aptSource
+	for _, p := range paras {
+		if e := strings.ToLower(p["enabled"]); e == "no" || e == "false" {
+			continue
+		}
+		opts := map[string]string{}
+		multiline := ""
+		for k, v := range p {
+			switch k {
+			case "types", "uris", "suites", "components", "enabled":
+			default:
+				if strings.Contains(v, "\n") {
+					multiline = k
+					continue
+				}
+				if o, ok := aptOneLineOptions[k]; ok {
+					k = o
+				}
+				opts[k] = strings.Join(strings.Fields(v), ",")
+			}
+		}
+		if multiline != "" {
+			jww.ERROR.Printf("pkginfo: skipping apt source %s %s: %s has more than one line", p["uris"], p["suites"], multiline)
+			continue
+		}
+		for _, t := range strings.Fields(p["types"]) {
+			for _, uri := range strings.Fields(p["uris"]) {
+				for _, suite := range strings.Fields(p["suites"]) {
+					sources = append(sources, aptSource{
+						Type:       t,
+						URI:        uri,
+						Suite:      suite,
+						Components: strings.Fields(p["components"]),
+						Options:    opts,
+					})
+				}
+			}
+		}
+	}
+	return sources, nil
+}
+
+// isSecurity reports whether the source is a security pocket, by its suite,
+// such as focal-security or stretch/updates, by its host, or by the label of
+// its downloaded release file, such as Debian-Security.
+func (s aptSource) isSecurity() bool {
+	if strings.HasSuffix(s.Suite, "-security") || strings.HasSuffix(s.Suite, "/updates") {
+		return true
+	}
+	host := strings.TrimPrefix(s.URI[strings.Index(s.URI, ":")+1:], "//")
+	if strings.HasPrefix(host, "security.") {
+		return true
+	}
+	return strings.Contains(strings.ToLower(s.releaseField("Label")), "security")
+}
+
+// releaseField returns a field of the source's release file as downloaded by
+// apt, or an empty string if there is none.
+func (s aptSource) releaseField(field string) string {
+	base := filepath.Join(aptListsDir, aptListPrefix(s.URI)+"dists_"+strings.Replace(s.Suite, "/", "_", -1)+"_")
+	for _, name := range []string{"InRelease", "Release"} {
+		b, err := afero.ReadFile(util.Fs, base+name)
+		if err != nil {
+			continue
+		}
+		sc := bufio.NewScanner(bytes.NewReader(b))
+		for sc.Scan() {
+			if strings.HasPrefix(sc.Text(), field+":") {
+				return strings.TrimSpace(sc.Text()[len(field)+1:])
+			}
+		}
+	}
+	return ""
+}
+
+// aptListPrefix returns the prefix of apt's list file names for a uri:
+// the uri without its scheme, with slashes replaced by underscores.
+func aptListPrefix(uri string) string {
+	if i := strings.Index(uri, "://"); i >= 0 {
+		uri = uri[i+3:]
+	}
+	uri = strings.TrimSuffix(uri, "/")
+	return strings.Replace(uri, "/", "_", -1) + "_"
+}
+
+// getAptSecuritySources returns the enabled deb sources that are security pockets.
+func getAptSecuritySources() ([]aptSource, error) {
+	sources, err := getAptSources()
+	if err != nil {
+		return nil, err
+	}
+	var sec []aptSource
+	for _, s := range sources {
+		if s.Type == "deb" && s.isSecurity() {
+			sec = append(sec, s)
+		}
+	}
+	return sec, nil
+}
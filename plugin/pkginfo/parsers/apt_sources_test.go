@@ -0,0 +1,73 @@
+// +build unit
+
+package parsers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestTree copies the fixture tree at dir onto the root of the test filesystem.
+func writeTestTree(t *testing.T, dir string) {
+	util.ResetTestFs()
+	util.SetFs(util.TestFs)
+	err := afero.Walk(util.OSFs, dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, err := afero.ReadFile(util.OSFs, path)
+		if err != nil {
+			return err
+		}
+		return afero.WriteFile(util.TestFs, "/"+filepath.ToSlash(strings.TrimPrefix(path, dir)), b, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetAptSecuritySources(t *testing.T) {
+	assert := assert.New(t)
+	writeTestTree(t, "../../../test_resources/pkginfo/apt")
+	sources, err := getAptSources()
+	assert.NoError(err)
+	assert.Len(sources, 11, "commented and disabled sources left out, deb822 expanded, malformed files and inline keys skipped")
+	sec, err := getAptSecuritySources()
+	assert.NoError(err)
+	var lines []string
+	for _, s := range sec {
+		lines = append(lines, s.String())
+	}
+	assert.Equal([]string{
+		"deb [arch=amd64] http://archive.ubuntu.com/ubuntu/ focal-security main restricted universe",
+		"deb http://security.ubuntu.com/ubuntu focal main",
+		"deb [arch=amd64,i386 signed-by=/usr/share/keyrings/debian-archive-keyring.gpg] http://deb.debian.org/debian-security bookworm-security main contrib",
+		"deb http://mirror.example.com/debian/ stable main",
+	}, lines)
+}
+
+func TestParseAptOneLineSources(t *testing.T) {
+	assert := assert.New(t)
+	_, err := parseAptOneLineSources(strings.NewReader("deb [arch=amd64 http://example.com focal main\n"))
+	assert.Error(err)
+	_, err = parseAptOneLineSources(strings.NewReader("deb http://example.com\n"))
+	assert.Error(err)
+}
+
+func TestParseAptInst(t *testing.T) {
+	assert := assert.New(t)
+	out := "Inst openssl [1.1.1f-1ubuntu2.16] (1.1.1f-1ubuntu2.17 Ubuntu:20.04/focal-security [amd64])\n" +
+		"Inst libnew (2.0-1 Ubuntu:20.04/focal-updates [amd64])\n" +
+		"Conf openssl (1.1.1f-1ubuntu2.17 Ubuntu:20.04/focal-security [amd64])\n"
+	pkgs := parseAptInst([]byte(out), true)
+	assert.Len(pkgs, 2)
+	assert.Equal(LinuxPackage{name: "openssl", Version: "1.1.1f-1ubuntu2.17", Security: true}, pkgs["openssl"])
+	assert.Equal("2.0-1", pkgs["libnew"].Version)
+}
@@ -0,0 +1,145 @@
+package parsers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxStderr limits the standard error kept in a CmdError.
+const maxStderr = 1024
+
+// lockRetryDelays are the waits before each retry of a command that failed
+// because another process held the package database lock.
+var lockRetryDelays = []time.Duration{5 * time.Second, 15 * time.Second, 30 * time.Second}
+
+// lockRE matches the errors of package managers that could not get their lock,
+// such as apt and dpkg's "Could not get lock /var/lib/dpkg/lock-frontend", rpm's
+// "can't create transaction lock", yum's "Another app is currently holding the
+// yum lock", zypper's "System management is locked", and pacman's "unable to
+// lock database".
+var lockRE = regexp.MustCompile(`(?i)could not get lock|unable to (acquire|lock)|dpkg frontend( lock)? is locked|can't create transaction lock|holding the yum lock|system management is locked|unable to lock database|failed to lock the database`)
+
+// zypperExitLocked is zypper's exit status when the system is locked.
+const zypperExitLocked = 7
+
+// CmdError is the failure of a package manager command, with its exit status,
+// the start of its standard error, how long it ran, and whether it failed for
+// lack of the package database lock. Duration covers all attempts.
+type CmdError struct {
+	Command  string
+	ExitCode int // -1 if the command could not be run or did not exit
+	Stderr   string
+	Duration time.Duration
+	Attempts int
+	Locked   bool
+	Err      error
+}
+
+func (e *CmdError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("%s: %s", e.Command, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Command, e.Err, e.Stderr)
+}
+
+// SourceError is an error collecting a source of package information, such as
+// the installed packages or the advisories. Packages, if set, are the packages
+// whose information the error left missing, as when only some changelogs could
+// not be fetched.
+type SourceError struct {
+	Source   string
+	Err      error
+	Packages []string
+}
+
+func (e *SourceError) Error() string {
+	return e.Source + ": " + e.Err.Error()
+}
+
+// sourceErrors appends err, if any, to errs as an error of source.
+func sourceErrors(errs []error, source string, err error) []error {
+	if err == nil {
+		return errs
+	}
+	return append(errs, &SourceError{Source: source, Err: err})
+}
+
+// pkgCmd is a package manager command.
+type pkgCmd struct {
+	name string
+	args []string
+	env  []string // added to the environment
+	// ok reports whether a non-zero exit status is not a failure.
+	ok func(code int, stderr []byte) bool
+	// timeout, if not zero, limits how long each attempt may run.
+	timeout time.Duration
+}
+
+func (c pkgCmd) String() string {
+	return strings.Join(append([]string{c.name}, c.args...), " ")
+}
+
+// run runs the command and returns its output. A command that fails because
+// the package database is locked, as when an upgrade is running, is retried
+// after each of lockRetryDelays. Failures are returned as a *CmdError.
+func (c pkgCmd) run() ([]byte, error) {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		var stderr bytes.Buffer
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if c.timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		}
+		cmd := exec.CommandContext(ctx, c.name, c.args...)
+		if len(c.env) > 0 {
+			cmd.Env = append(os.Environ(), c.env...)
+		}
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", c.timeout)
+		}
+		cancel()
+		code := 0
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			code = exitErr.ExitCode()
+			if c.ok != nil && c.ok(code, stderr.Bytes()) {
+				err = nil
+			}
+		} else if err != nil {
+			code = -1
+		}
+		if err == nil {
+			return out, nil
+		}
+		locked := lockRE.Match(stderr.Bytes()) || c.name == "zypper" && code == zypperExitLocked
+		if locked && attempt <= len(lockRetryDelays) {
+			time.Sleep(lockRetryDelays[attempt-1])
+			continue
+		}
+		return nil, &CmdError{
+			Command:  c.String(),
+			ExitCode: code,
+			Stderr:   excerpt(stderr.String()),
+			Duration: time.Since(start),
+			Attempts: attempt,
+			Locked:   locked,
+			Err:      err,
+		}
+	}
+}
+
+// excerpt returns s trimmed and cut to maxStderr bytes.
+func excerpt(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= maxStderr {
+		return s
+	}
+	return s[:maxStderr] + "..."
+}
@@ -0,0 +1,91 @@
+// +build unit
+
+package parsers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPkgCmdRun(t *testing.T) {
+	assert := assert.New(t)
+	defer func(d []time.Duration) { lockRetryDelays = d }(lockRetryDelays)
+	lockRetryDelays = []time.Duration{0, 0}
+	dir, err := ioutil.TempDir("", "mirach-pkgcmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The lock is released after the first attempt.
+	marker := filepath.Join(dir, "released")
+	out, err := pkgCmd{name: "sh", args: []string{"-c",
+		`if [ -e ` + marker + ` ]; then echo ok; else touch ` + marker + `; echo "E: Could not get lock /var/lib/dpkg/lock-frontend" >&2; exit 100; fi`,
+	}}.run()
+	assert.NoError(err)
+	assert.Equal("ok\n", string(out))
+
+	// The lock is never released.
+	_, err = pkgCmd{name: "sh", args: []string{"-c", `echo "error: can't create transaction lock on /var/lib/rpm/.rpm.lock" >&2; exit 1`}}.run()
+	if ce, ok := err.(*CmdError); assert.True(ok) {
+		assert.True(ce.Locked)
+		assert.Equal(3, ce.Attempts)
+		assert.Equal(1, ce.ExitCode)
+		assert.Equal("error: can't create transaction lock on /var/lib/rpm/.rpm.lock", ce.Stderr)
+	}
+
+	// Other failures are not retried.
+	_, err = pkgCmd{name: "sh", args: []string{"-c", `exit 2`}}.run()
+	if ce, ok := err.(*CmdError); assert.True(ok) {
+		assert.False(ce.Locked)
+		assert.Equal(1, ce.Attempts)
+		assert.Equal(2, ce.ExitCode)
+		assert.Equal("sh -c exit 2: exit status 2", ce.Error())
+	}
+
+	_, err = pkgCmd{name: "sh", args: []string{"-c", `exit 100`}, ok: func(code int, stderr []byte) bool {
+		return code >= 100
+	}}.run()
+	assert.NoError(err, "accepted exit status")
+
+	_, err = pkgCmd{name: filepath.Join(dir, "missing")}.run()
+	if ce, ok := err.(*CmdError); assert.True(ok) {
+		assert.Equal(-1, ce.ExitCode)
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	assert := assert.New(t)
+	errs := sourceErrors(nil, "installed", nil)
+	assert.Empty(errs)
+	errs = sourceErrors(errs, "installed", &CmdError{Command: "rpm -qa", Err: os.ErrNotExist})
+	if assert.Len(errs, 1) {
+		assert.Equal("installed: rpm -qa: file does not exist", errs[0].Error())
+	}
+}
+
+func TestYumListUpdates(t *testing.T) {
+	assert := assert.New(t)
+	out := "Updated Packages\n" +
+		"openssl.x86_64    1:1.1.1k-9.el8_7    baseos\n" +
+		"kernel.x86_64     4.18.0-513.5.1.el8  baseos\n" +
+		"python3-a-very-long-package-name.noarch\n" +
+		"                  2.1-3.el8           appstream\n\n"
+	pkgs := parseYumListUpdates([]byte(out), true)
+	assert.Len(pkgs, 3)
+	assert.Equal(LinuxPackage{
+		name:         "openssl",
+		Version:      "1:1.1.1k-9.el8_7",
+		Security:     true,
+		Epoch:        "1",
+		Release:      "9.el8_7",
+		Architecture: "x86_64",
+	}, pkgs["openssl.x86_64"], "keyed as installed packages are")
+	assert.Equal("4.18.0-513.5.1.el8", pkgs["kernel.x86_64"].Version)
+	assert.Equal("2.1-3.el8", pkgs["python3-a-very-long-package-name.noarch"].Version, "wrapped line joined")
+}
@@ -0,0 +1,112 @@
+package parsers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cleardataeng/mirach/util"
+)
+
+// dpkgStatusPath is the dpkg database of installed packages.
+const dpkgStatusPath = "/var/lib/dpkg/status"
+
+func getDpkgInstalledPackages() (map[string]LinuxPackage, error) {
+	f, err := util.Fs.Open(dpkgStatusPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseDpkgStatus(f)
+}
+
+// parseDpkgStatus returns the packages present in a dpkg status database.
+// Packages that are not installed, or of which only configuration files
+// remain, are left out. A package installed for more than one architecture is
+// keyed by name:arch after its first entry.
+func parseDpkgStatus(r io.Reader) (map[string]LinuxPackage, error) {
+	paras, err := parseDeb822(r)
+	if err != nil {
+		return nil, err
+	}
+	pkgs := map[string]LinuxPackage{}
+	for _, p := range paras {
+		name := p["package"]
+		status := strings.Fields(p["status"])
+		if name == "" || len(status) != 3 {
+			continue
+		}
+		if status[2] == "not-installed" || status[2] == "config-files" {
+			continue
+		}
+		pkg := LinuxPackage{
+			name:         name,
+			Version:      p["version"],
+			Architecture: p["architecture"],
+			Source:       name,
+			Status:       p["status"],
+		}
+		// Source may give a version as well, e.g. "openssl (1.1.1n-0+deb11u3)",
+		// which differs from the package's for binNMUs and packages such as gcc
+		// that are versioned apart from their source.
+		if src := strings.Fields(p["source"]); len(src) > 0 {
+			pkg.Source = src[0]
+			if len(src) > 1 {
+				if v := strings.Trim(src[1], "()"); v != pkg.Version {
+					pkg.SourceVersion = v
+				}
+			}
+		}
+		if size := p["installed-size"]; size != "" {
+			if pkg.InstalledSize, err = strconv.ParseInt(size, 10, 64); err != nil {
+				return nil, fmt.Errorf("%s: bad Installed-Size %q", name, size)
+			}
+		}
+		key := name
+		if _, ok := pkgs[key]; ok {
+			key = name + ":" + pkg.Architecture
+		}
+		pkgs[key] = pkg
+	}
+	return pkgs, nil
+}
+
+// parseDeb822 parses the paragraphs of a Debian control file, such as the dpkg
+// status database. Field names are case insensitive and are returned lower
+// case. Continuation lines are joined to their field's value with newlines.
+func parseDeb822(r io.Reader) ([]map[string]string, error) {
+	var (
+		paras []map[string]string
+		para  map[string]string
+		field string
+	)
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		switch {
+		case strings.TrimSpace(line) == "":
+			para, field = nil, ""
+		case strings.HasPrefix(line, "#"):
+		case line[0] == ' ' || line[0] == '\t':
+			if para == nil || field == "" {
+				return nil, fmt.Errorf("continuation line without field: %q", line)
+			}
+			para[field] += "\n" + strings.TrimSpace(line)
+		default:
+			i := strings.Index(line, ":")
+			if i < 1 {
+				return nil, fmt.Errorf("malformed field: %q", line)
+			}
+			if para == nil {
+				para = map[string]string{}
+				paras = append(paras, para)
+			}
+			field = strings.ToLower(line[:i])
+			para[field] = strings.TrimSpace(line[i+1:])
+		}
+	}
+	return paras, s.Err()
+}
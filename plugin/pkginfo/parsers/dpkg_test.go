@@ -0,0 +1,52 @@
+// +build unit
+
+package parsers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDpkgInstalledPackages(t *testing.T) {
+	assert := assert.New(t)
+	util.ResetTestFs()
+	util.SetFs(util.TestFs)
+	status, err := afero.ReadFile(util.OSFs, "../../../test_resources/pkginfo/dpkg_status")
+	assert.NoError(err)
+	assert.NoError(afero.WriteFile(util.TestFs, dpkgStatusPath, status, 0644))
+	pkgs, err := getDpkgInstalledPackages()
+	assert.NoError(err)
+	assert.Len(pkgs, 5, "config-files only package left out")
+	assert.Equal(LinuxPackage{
+		name:          "libssl1.1",
+		Version:       "1.1.1n-0+deb11u4",
+		Architecture:  "amd64",
+		Source:        "openssl",
+		SourceVersion: "1.1.1n-0+deb11u3",
+		Status:        "install ok installed",
+		InstalledSize: 4127,
+	}, pkgs["libssl1.1"])
+	assert.Equal("1:8.4p1-5+deb11u1", pkgs["openssh-server"].Version, "epoch kept")
+	assert.Equal("amd64", pkgs["libc6"].Architecture)
+	assert.Equal("i386", pkgs["libc6:i386"].Architecture, "second architecture keyed by name:arch")
+	assert.Equal("base-files", pkgs["base-files"].Source, "source defaults to package")
+	assert.Equal("install ok unpacked", pkgs["base-files"].Status)
+	_, ok := pkgs["vim-tiny"]
+	assert.False(ok)
+}
+
+func TestParseDeb822(t *testing.T) {
+	assert := assert.New(t)
+	paras, err := parseDeb822(strings.NewReader("# comment\nA: 1\nLong: first\n second\n\n\nb: 2\n"))
+	assert.NoError(err)
+	assert.Equal([]map[string]string{{"a": "1", "long": "first\nsecond"}, {"b": "2"}}, paras)
+	_, err = parseDeb822(strings.NewReader(" orphan\n"))
+	assert.Error(err)
+	_, err = parseDeb822(strings.NewReader("no colon\n"))
+	assert.Error(err)
+}
@@ -0,0 +1,103 @@
+package parsers
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/spf13/afero"
+)
+
+// dpkgInfoDir holds the list of files each installed package owns, as
+// <package>[:<arch>].list.
+const dpkgInfoDir = "/var/lib/dpkg/info"
+
+// GetOwningPkgs returns the name of the installed package that owns each of
+// the given files, for the given platform family. Files no package owns are
+// left out. It is supported where dpkg or rpm is the package database.
+func GetOwningPkgs(family string, paths []string) (map[string]string, error) {
+	switch family {
+	case "debian":
+		return getDpkgOwners(paths)
+	case "rhel", "suse":
+		return getRpmOwners(paths)
+	}
+	return nil, fmt.Errorf("unsupported platform family: %s", family)
+}
+
+// getDpkgOwners reads the file lists of the installed packages. As on systems
+// with a merged /usr, files such as /usr/bin/bash may be listed as /bin/bash,
+// paths under /usr also match the same path without /usr.
+func getDpkgOwners(paths []string) (map[string]string, error) {
+	want := map[string][]string{}
+	for _, p := range paths {
+		want[p] = append(want[p], p)
+		if alias := strings.TrimPrefix(p, "/usr"); alias != p {
+			want[alias] = append(want[alias], p)
+		}
+	}
+	lists, err := afero.Glob(util.Fs, filepath.Join(dpkgInfoDir, "*.list"))
+	if err != nil {
+		return nil, err
+	}
+	owners := map[string]string{}
+	for _, list := range lists {
+		pkg := strings.TrimSuffix(filepath.Base(list), ".list")
+		if i := strings.Index(pkg, ":"); i >= 0 {
+			pkg = pkg[:i]
+		}
+		f, err := util.Fs.Open(list)
+		if err != nil {
+			return nil, err
+		}
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			for _, p := range want[s.Text()] {
+				if _, ok := owners[p]; !ok {
+					owners[p] = pkg
+				}
+			}
+		}
+		err = s.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return owners, nil
+}
+
+func getRpmOwners(paths []string) (map[string]string, error) {
+	owners := map[string]string{}
+	for _, p := range paths {
+		out, err := pkgCmd{
+			name: "rpm",
+			args: []string{"-qf", "--queryformat", `%{NAME}\n`, p},
+			// rpm -qf exits 1 when the file is not owned by any package.
+			ok: func(code int, stderr []byte) bool {
+				return code == 1
+			},
+		}.run()
+		if err != nil {
+			return nil, err
+		}
+		if name := rpmOwner(out); name != "" {
+			owners[p] = name
+		}
+	}
+	return owners, nil
+}
+
+// rpmOwner returns the first package named in the output of rpm -qf, or
+// nothing for messages such as "file /opt/x is not owned by any package".
+func rpmOwner(b []byte) string {
+	for _, line := range strings.Split(string(b), "\n") {
+		if line != "" && !strings.ContainsAny(line, " \t") {
+			return line
+		}
+	}
+	return ""
+}
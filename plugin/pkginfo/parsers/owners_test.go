@@ -0,0 +1,43 @@
+// +build unit
+
+package parsers
+
+import (
+	"testing"
+
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDpkgOwners(t *testing.T) {
+	assert := assert.New(t)
+	util.ResetTestFs()
+	util.SetFs(util.TestFs)
+	lists := map[string]string{
+		"openssh-server.list": "/.\n/usr\n/usr/sbin\n/usr/sbin/sshd\n",
+		"bash.list":           "/.\n/bin\n/bin/bash\n",
+		"libc6:amd64.list":    "/.\n/lib/x86_64-linux-gnu/libc.so.6\n",
+	}
+	for name, content := range lists {
+		assert.NoError(afero.WriteFile(util.TestFs, dpkgInfoDir+"/"+name, []byte(content), 0644))
+	}
+	assert.NoError(afero.WriteFile(util.TestFs, dpkgInfoDir+"/bash.md5sums", []byte("x  /usr/sbin/nginx\n"), 0644))
+	owners, err := GetOwningPkgs("debian", []string{"/usr/sbin/sshd", "/usr/bin/bash", "/lib/x86_64-linux-gnu/libc.so.6", "/usr/sbin/nginx"})
+	assert.NoError(err)
+	assert.Equal(map[string]string{
+		"/usr/sbin/sshd":                  "openssh-server",
+		"/usr/bin/bash":                   "bash",
+		"/lib/x86_64-linux-gnu/libc.so.6": "libc6",
+	}, owners)
+
+	_, err = GetOwningPkgs("alpine", nil)
+	assert.Error(err)
+}
+
+func TestRpmOwner(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("openssh-server", rpmOwner([]byte("openssh-server\n")))
+	assert.Equal("", rpmOwner([]byte("file /opt/app/bin/app is not owned by any package\n")))
+}
@@ -1,10 +1,22 @@
 package parsers
 
+import "fmt"
+
 //LinuxPackage represent pertinent features of linux package
+//Fields other than Version and Security are only known for some package managers.
 type LinuxPackage struct {
-	name     string
-	Version  string `json:"version"`
-	Security bool   `json:"security"`
+	name          string
+	Version       string `json:"version"` // full version, including epoch and release
+	Security      bool   `json:"security"`
+	Epoch         string `json:"epoch,omitempty"`
+	Release       string `json:"release,omitempty"`
+	Architecture  string `json:"architecture,omitempty"`
+	Source        string `json:"source,omitempty"`         // source package
+	SourceVersion string `json:"source_version,omitempty"` // source package's version, where it differs from Version
+	Status        string `json:"status,omitempty"`         // package manager's status flags
+	Vendor        string `json:"vendor,omitempty"`
+	InstalledSize int64  `json:"installed_size,omitempty"` // in KiB
+	InstallTime   int64  `json:"install_time,omitempty"`   // unix time
 }
 
 //KBArticle represent pertinent features of linux package
@@ -12,3 +24,25 @@ type KBArticle struct {
 	name     string
 	Security bool `json:"security"`
 }
+
+//Name returns the package's name, which may differ from the key it is listed
+//under when a package is installed more than once.
+func (p LinuxPackage) Name() string {
+	return p.name
+}
+
+//GetInstalledPkgs returns only the installed packages for the given platform
+//family, as pkginfo lists them, without checking for available updates.
+func GetInstalledPkgs(family string) (map[string]LinuxPackage, error) {
+	switch family {
+	case "debian":
+		return getDpkgInstalledPackages()
+	case "rhel", "suse":
+		return getRpmInstalledPackages()
+	case "alpine":
+		return getApkInstalledPackages()
+	case "arch":
+		return getPacmanInstalledPackages()
+	}
+	return nil, fmt.Errorf("unsupported platform family: %s", family)
+}
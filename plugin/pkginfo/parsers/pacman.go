@@ -0,0 +1,58 @@
+package parsers
+
+import "strings"
+
+// GetPacmanPkgs creates map of available, installed and available security packages from pacman as well as a list of errors that occurred generating that list. pacman has no security metadata, so available security packages are always empty.
+func GetPacmanPkgs() (map[string]map[string]LinuxPackage, []error) {
+	errors := []error{}
+	out := make(map[string]map[string]LinuxPackage)
+	avail, err := getPacmanAvailablePackages()
+	errors = sourceErrors(errors, "available", err)
+	out["available"] = avail
+	out["available_security"] = map[string]LinuxPackage{}
+	installed, err := getPacmanInstalledPackages()
+	errors = sourceErrors(errors, "installed", err)
+	out["installed"] = installed
+	return out, errors
+}
+
+func getPacmanInstalledPackages() (map[string]LinuxPackage, error) {
+	out, err := pacman("-Q")
+	if err != nil {
+		return nil, err
+	}
+	return parsePacakgesFromBytes(out, false)
+}
+
+func getPacmanAvailablePackages() (map[string]LinuxPackage, error) {
+	out, err := pacman("-Qu")
+	if err != nil {
+		return nil, err
+	}
+	return parsePacmanUpdates(out), nil
+}
+
+func pacman(args ...string) ([]byte, error) {
+	return pkgCmd{
+		name: "pacman",
+		args: args,
+		// pacman -Qu exits 1, without error output, when there are no updates.
+		ok: func(code int, stderr []byte) bool {
+			return code == 1 && len(stderr) == 0
+		},
+	}.run()
+}
+
+// parsePacmanUpdates parses the output of pacman -Qu, lines such as
+// "openssl 3.0.7-2 -> 3.0.7-4", into the available versions.
+func parsePacmanUpdates(b []byte) map[string]LinuxPackage {
+	pkgs := map[string]LinuxPackage{}
+	for _, line := range strings.Split(string(b), "\n") {
+		f := strings.Fields(line)
+		if len(f) < 4 || f[2] != "->" {
+			continue
+		}
+		pkgs[f[0]] = LinuxPackage{name: f[0], Version: f[3]}
+	}
+	return pkgs
+}
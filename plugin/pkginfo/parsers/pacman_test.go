@@ -0,0 +1,17 @@
+// +build unit
+
+package parsers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePacmanUpdates(t *testing.T) {
+	assert := assert.New(t)
+	pkgs := parsePacmanUpdates([]byte("openssl 3.0.7-2 -> 3.0.7-4\nlinux 6.0.9.arch1-1 -> 6.0.11.arch1-1 [ignored]\n"))
+	assert.Len(pkgs, 2)
+	assert.Equal(LinuxPackage{name: "openssl", Version: "3.0.7-4"}, pkgs["openssl"])
+	assert.Equal("6.0.11.arch1-1", pkgs["linux"].Version)
+}
@@ -0,0 +1,108 @@
+package parsers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// rpmQueryFormat has rpm print one line of tab separated fields per package.
+const rpmQueryFormat = `%{NAME}\t%{EPOCH}\t%{VERSION}\t%{RELEASE}\t%{ARCH}\t%{VENDOR}\t%{INSTALLTIME}\t%{SOURCERPM}\n`
+
+// rpmNone is printed by rpm for tags a package does not have.
+const rpmNone = "(none)"
+
+func getRpmInstalledPackages() (map[string]LinuxPackage, error) {
+	out, err := pkgCmd{name: "rpm", args: []string{"-qa", "--queryformat", rpmQueryFormat}}.run()
+	if err != nil {
+		return nil, err
+	}
+	return parseRpmQuery(out)
+}
+
+// parseRpmQuery parses the output of rpm -qa with rpmQueryFormat. Packages are
+// keyed by rpmKeys.
+func parseRpmQuery(b []byte) (map[string]LinuxPackage, error) {
+	var list []LinuxPackage
+	for _, line := range strings.Split(string(b), "\n") {
+		if line == "" {
+			continue
+		}
+		f := strings.Split(line, "\t")
+		if len(f) != 8 {
+			return nil, fmt.Errorf("malformed rpm query line: %q", line)
+		}
+		pkg := LinuxPackage{
+			name:         f[0],
+			Epoch:        rpmTag(f[1]),
+			Release:      rpmTag(f[3]),
+			Architecture: rpmTag(f[4]),
+			Vendor:       rpmTag(f[5]),
+			Source:       srpmName(rpmTag(f[7])),
+		}
+		pkg.Version = rpmEVR(pkg.Epoch, f[2], pkg.Release)
+		if t := rpmTag(f[6]); t != "" {
+			var err error
+			if pkg.InstallTime, err = strconv.ParseInt(t, 10, 64); err != nil {
+				return nil, fmt.Errorf("%s: bad install time %q", pkg.name, t)
+			}
+		}
+		list = append(list, pkg)
+	}
+	return rpmKeys(list), nil
+}
+
+// rpmKeys keys packages of an rpm based system as yum and zypper name them, by
+// name.arch, or by name alone for packages without an architecture such as
+// gpg-pubkey. Where the same name and architecture are listed more than once,
+// as for kernels and other packages installed side by side, each is keyed by
+// name-version-release.arch instead. Every group of packages is keyed this
+// way, so that a package has the same key in each.
+func rpmKeys(list []LinuxPackage) map[string]LinuxPackage {
+	count := map[string]int{}
+	for _, pkg := range list {
+		count[rpmNameArch(pkg.name, pkg.Architecture)]++
+	}
+	pkgs := map[string]LinuxPackage{}
+	for _, pkg := range list {
+		key := rpmNameArch(pkg.name, pkg.Architecture)
+		if count[key] > 1 {
+			vr := strings.TrimPrefix(pkg.Version, pkg.Epoch+":")
+			key = rpmNameArch(pkg.name+"-"+vr, pkg.Architecture)
+		}
+		pkgs[key] = pkg
+	}
+	return pkgs
+}
+
+func rpmNameArch(name, arch string) string {
+	if arch == "" {
+		return name
+	}
+	return name + "." + arch
+}
+
+func rpmTag(s string) string {
+	if s == rpmNone {
+		return ""
+	}
+	return s
+}
+
+// rpmEVR returns the [epoch:]version-release string of a package.
+func rpmEVR(epoch, version, release string) string {
+	evr := version
+	if epoch != "" {
+		evr = epoch + ":" + evr
+	}
+	if release != "" {
+		evr += "-" + release
+	}
+	return evr
+}
+
+// srpmName returns the name of the package built from a source rpm file name
+// such as openssl-1.1.1k-7.el8.src.rpm.
+func srpmName(srpm string) string {
+	return trimVersionRelease(strings.TrimSuffix(strings.TrimSuffix(srpm, ".rpm"), ".src"))
+}
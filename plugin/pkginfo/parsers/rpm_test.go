@@ -0,0 +1,38 @@
+// +build unit
+
+package parsers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRpmQuery(t *testing.T) {
+	assert := assert.New(t)
+	out := "openssl-libs\t1\t1.1.1k\t7.el8_6\tx86_64\tRed Hat, Inc.\t1660000000\topenssl-1.1.1k-7.el8_6.src.rpm\n" +
+		"openssl-libs\t1\t1.1.1k\t7.el8_6\ti686\tRed Hat, Inc.\t1660000001\topenssl-1.1.1k-7.el8_6.src.rpm\n" +
+		"kernel\t(none)\t4.18.0\t372.9.1.el8\tx86_64\tRed Hat, Inc.\t1650000000\tkernel-4.18.0-372.9.1.el8.src.rpm\n" +
+		"kernel\t(none)\t4.18.0\t425.3.1.el8\tx86_64\tRed Hat, Inc.\t1670000000\tkernel-4.18.0-425.3.1.el8.src.rpm\n" +
+		"gpg-pubkey\t(none)\tfd431d51\t4ae0493b\t(none)\t(none)\t1640000000\t(none)\n"
+	pkgs, err := parseRpmQuery([]byte(out))
+	assert.NoError(err)
+	assert.Len(pkgs, 5)
+	assert.Equal(LinuxPackage{
+		name:         "openssl-libs",
+		Version:      "1:1.1.1k-7.el8_6",
+		Epoch:        "1",
+		Release:      "7.el8_6",
+		Architecture: "x86_64",
+		Source:       "openssl",
+		Vendor:       "Red Hat, Inc.",
+		InstallTime:  1660000000,
+	}, pkgs["openssl-libs.x86_64"])
+	assert.Equal("i686", pkgs["openssl-libs.i686"].Architecture)
+	assert.Equal("372.9.1.el8", pkgs["kernel-4.18.0-372.9.1.el8.x86_64"].Release, "kernels keyed by version")
+	assert.Equal("425.3.1.el8", pkgs["kernel-4.18.0-425.3.1.el8.x86_64"].Release)
+	assert.NotContains(pkgs, "kernel.x86_64")
+	assert.Equal(LinuxPackage{name: "gpg-pubkey", Version: "fd431d51-4ae0493b", Release: "4ae0493b", InstallTime: 1640000000}, pkgs["gpg-pubkey"])
+	_, err = parseRpmQuery([]byte("too\tfew\n"))
+	assert.Error(err)
+}
@@ -0,0 +1,205 @@
+package parsers
+
+import "strings"
+
+// CompareDebVersions compares two Debian package versions of the form
+// [epoch:]upstream[-revision] as dpkg does. It returns -1, 0, or 1 if a is
+// older than, the same as, or newer than b.
+func CompareDebVersions(a, b string) int {
+	ae, au, ar := splitDebVersion(a)
+	be, bu, br := splitDebVersion(b)
+	if c := compareNumeric(ae, be); c != 0 {
+		return c
+	}
+	if c := compareDebPart(au, bu); c != 0 {
+		return c
+	}
+	return compareDebPart(ar, br)
+}
+
+func splitDebVersion(v string) (epoch, upstream, revision string) {
+	epoch = "0"
+	if i := strings.Index(v, ":"); i >= 0 {
+		epoch, v = v[:i], v[i+1:]
+	}
+	upstream = v
+	if i := strings.LastIndex(v, "-"); i >= 0 {
+		upstream, revision = v[:i], v[i+1:]
+	}
+	return epoch, upstream, revision
+}
+
+// compareDebPart compares an upstream version or revision, alternating
+// between non-digit parts, compared by debOrder, and numeric parts.
+func compareDebPart(a, b string) int {
+	for a != "" || b != "" {
+		var as, bs string
+		as, a = splitLeading(a, false)
+		bs, b = splitLeading(b, false)
+		for i := 0; i < len(as) || i < len(bs); i++ {
+			var ac, bc byte
+			if i < len(as) {
+				ac = as[i]
+			}
+			if i < len(bs) {
+				bc = bs[i]
+			}
+			if oa, ob := debOrder(ac), debOrder(bc); oa != ob {
+				return sign(oa - ob)
+			}
+		}
+		as, a = splitLeading(a, true)
+		bs, b = splitLeading(b, true)
+		if c := compareNumeric(as, bs); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// debOrder orders characters as dpkg does: ~ before the end of a part, the end
+// before letters, and letters before other characters. c is 0 at the end.
+func debOrder(c byte) int {
+	switch {
+	case c == '~':
+		return -1
+	case c == 0:
+		return 0
+	case c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+		return int(c)
+	}
+	return int(c) + 256
+}
+
+// splitLeading splits s after its leading digits, or leading non-digits.
+func splitLeading(s string, digits bool) (string, string) {
+	i := 0
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9') == digits {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// compareNumeric compares strings of digits by their value.
+func compareNumeric(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return sign(len(a) - len(b))
+	}
+	return strings.Compare(a, b)
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// CompareRPMVersions compares two rpm package versions of the form
+// [epoch:]version[-release] as rpm does. It returns -1, 0, or 1 if a is older
+// than, the same as, or newer than b. A release is only compared if both
+// versions have one.
+func CompareRPMVersions(a, b string) int {
+	ae, av, ar := splitRPMVersion(a)
+	be, bv, br := splitRPMVersion(b)
+	if c := compareNumeric(ae, be); c != 0 {
+		return c
+	}
+	if c := rpmvercmp(av, bv); c != 0 || ar == "" || br == "" {
+		return c
+	}
+	return rpmvercmp(ar, br)
+}
+
+func splitRPMVersion(v string) (epoch, version, release string) {
+	epoch = "0"
+	if i := strings.Index(v, ":"); i >= 0 {
+		epoch, v = v[:i], v[i+1:]
+	}
+	version = v
+	if i := strings.LastIndex(v, "-"); i >= 0 {
+		version, release = v[:i], v[i+1:]
+	}
+	return epoch, version, release
+}
+
+// rpmvercmp compares version strings as rpm's rpmvercmp does: segments of
+// digits or letters are compared in turn, separators are ignored, ~ sorts
+// before anything and ^ after the end.
+func rpmvercmp(a, b string) int {
+	separator := func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r == '~' || r == '^')
+	}
+outer:
+	for a != "" || b != "" {
+		a = strings.TrimLeftFunc(a, separator)
+		b = strings.TrimLeftFunc(b, separator)
+		for _, sep := range []byte{'~', '^'} {
+			as, bs := strings.HasPrefix(a, string(sep)), strings.HasPrefix(b, string(sep))
+			switch {
+			case as && bs:
+				a, b = a[1:], b[1:]
+				continue outer
+			case as:
+				if sep == '^' && b == "" {
+					return 1
+				}
+				return -1
+			case bs:
+				if sep == '^' && a == "" {
+					return -1
+				}
+				return 1
+			}
+		}
+		if a == "" || b == "" {
+			break
+		}
+		digits := a[0] >= '0' && a[0] <= '9'
+		var as, bs string
+		as, a = splitLeadingClass(a, digits)
+		bs, b = splitLeadingClass(b, digits)
+		if bs == "" {
+			// Numeric segments are newer than alphabetic ones.
+			if digits {
+				return 1
+			}
+			return -1
+		}
+		var c int
+		if digits {
+			c = compareNumeric(as, bs)
+		} else {
+			c = strings.Compare(as, bs)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	switch {
+	case a == "" && b == "":
+		return 0
+	case a == "":
+		return -1
+	}
+	return 1
+}
+
+// splitLeadingClass splits s after its leading digits, or leading letters.
+func splitLeadingClass(s string, digits bool) (string, string) {
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		isDigit := c >= '0' && c <= '9'
+		isLetter := c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+		if digits && !isDigit || !digits && !isLetter {
+			break
+		}
+		i++
+	}
+	return s[:i], s[i:]
+}
@@ -0,0 +1,65 @@
+// +build unit
+
+package parsers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareDebVersions(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"1:1.0", "2.0", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0", "1.0+deb11u1", -1},
+		{"1.0a", "1.0+", -1},
+		{"1.1.1n-0+deb11u3", "1.1.1n-0+deb11u4", -1},
+		{"1.1.1f-1ubuntu2.17", "1.1.1f-1ubuntu2.9", 1},
+		{"2.31-13+deb11u5", "2.31-13", 1},
+		{"0:1.0-1", "1.0-1", 0},
+		{"1.0-1", "1.0-01", 0},
+	} {
+		assert.Equal(c.want, CompareDebVersions(c.a, c.b), "%s vs %s", c.a, c.b)
+		assert.Equal(-c.want, CompareDebVersions(c.b, c.a), "%s vs %s", c.b, c.a)
+	}
+}
+
+func TestCompareRPMVersions(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.0.1", -1},
+		{"1.10", "1.9", 1},
+		{"1:1.0-1", "2.0-1", 1},
+		{"0:1.0-1", "1.0-1", 0},
+		{"1.0-1.el8", "1.0-1.el8_6", -1},
+		{"1.1.1k-7.el8_6", "1.1.1k-9.el8_7", -1},
+		{"2.0a", "2.0", 1},
+		{"2.0", "2.0a", -1},
+		{"2a", "2.0", -1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0^git1", "1.0", 1},
+		{"1.0^git1", "1.0.1", -1},
+		{"1~~a", "1~~a", 0},
+		{"1^^a", "1^^a", 0},
+		{"1~~a", "1~~b", -1},
+		{"1~~", "1~", -1},
+		{"1^^", "1^", 1},
+		{"1.0", "1.0-5", 0},
+		{"4.18.0-425.3.1.el8", "4.18.0-425.10.1.el8", -1},
+	} {
+		assert.Equal(c.want, CompareRPMVersions(c.a, c.b), "%s vs %s", c.a, c.b)
+		assert.Equal(-c.want, CompareRPMVersions(c.b, c.a), "%s vs %s", c.b, c.a)
+	}
+}
@@ -18,19 +18,13 @@ func GetWindowsKBs() (map[string]map[string]KBArticle, []error) {
 	errors := []error{}
 	out := make(map[string]map[string]KBArticle)
 	avail, err := getWindowsAvailableKBs()
-	if err != nil {
-		errors = append(errors, err)
-	}
+	errors = sourceErrors(errors, "available", err)
 	out["available"] = avail
 	availSec, err := getWindowsAvailableSecurityKBs()
-	if err != nil {
-		errors = append(errors, err)
-	}
+	errors = sourceErrors(errors, "available_security", err)
 	out["available_security"] = availSec
 	installed, err := getWindowsInstalledKBs()
-	if err != nil {
-		errors = append(errors, err)
-	}
+	errors = sourceErrors(errors, "installed", err)
 	out["installed"] = installed
 	return out, errors
 }
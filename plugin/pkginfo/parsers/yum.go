@@ -1,58 +1,70 @@
 package parsers
 
-import "os/exec"
+import "strings"
 
-// GetYumPkgs creates map of available, installed and available security packages from yum as well as a list of errors that occurred generating that list. grep returns exit status 1 when it gets no match, errors like that are fine
+// GetYumPkgs creates map of available and available security packages from yum and installed packages from rpm, as well as a list of errors that occurred generating that list.
 func GetYumPkgs() (map[string]map[string]LinuxPackage, []error) {
 	errors := []error{}
 	out := make(map[string]map[string]LinuxPackage)
 	avail, err := getYumAvailablePackages()
-	if err != nil {
-		errors = append(errors, err)
-	}
+	errors = sourceErrors(errors, "available", err)
 	out["available"] = avail
 	availSec, err := getYumAvailableSecurityPackages()
-	if err != nil {
-		errors = append(errors, err)
-	}
+	errors = sourceErrors(errors, "available_security", err)
 	out["available_security"] = availSec
-	installed, err := getYumInstalledPackages()
-	if err != nil {
-		errors = append(errors, err)
-	}
+	installed, err := getRpmInstalledPackages()
+	errors = sourceErrors(errors, "installed", err)
 	out["installed"] = installed
 	return out, errors
 }
 
-func getYumInstalledPackages() (map[string]LinuxPackage, error) {
-	yum := command("yum list installed -q")
-	grep := exec.Command("grep", "-v", "Installed Packages")
-	awk := exec.Command("awk", "{{ print $1 , $2 }}")
-	stdout, _, err := pipeline(yum, grep, awk)
+func getYumAvailablePackages() (map[string]LinuxPackage, error) {
+	out, err := pkgCmd{name: "yum", args: []string{"list", "updates", "-q"}}.run()
 	if err != nil {
 		return nil, err
 	}
-	return parsePacakgesFromBytes(stdout, false)
+	return parseYumListUpdates(out, false), nil
 }
 
-func getYumAvailablePackages() (map[string]LinuxPackage, error) {
-	yum := command("yum list updates -q")
-	grep := exec.Command("grep", "-v", "Updated Packages")
-	awk := exec.Command("awk", "{{ print $1 , $2 }}")
-	stdout, _, err := pipeline(yum, grep, awk)
+func getYumAvailableSecurityPackages() (map[string]LinuxPackage, error) {
+	out, err := pkgCmd{name: "yum", args: []string{"list", "updates", "-q", "--security"}}.run()
 	if err != nil {
 		return nil, err
 	}
-	return parsePacakgesFromBytes(stdout, false)
+	return parseYumListUpdates(out, true), nil
 }
 
-func getYumAvailableSecurityPackages() (map[string]LinuxPackage, error) {
-	yum := command("yum list updates -q --security")
-	grep := exec.Command("grep", "-v", "Updated Packages")
-	awk := exec.Command("awk", "{{ print $1 , $2 }}")
-	stdout, _, err := pipeline(yum, grep, awk)
-	if err != nil {
-		return nil, err
+// parseYumListUpdates parses the updates listed by yum list updates, lines of
+// name.arch, [epoch:]version-release, and repository, leaving out its "Updated
+// Packages" heading. yum wraps a line whose name is too long after the name.
+// Packages are keyed as installed packages are, by rpmKeys.
+func parseYumListUpdates(b []byte, security bool) map[string]LinuxPackage {
+	var list []LinuxPackage
+	var wrapped string
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.Contains(line, "Updated Packages") {
+			continue
+		}
+		f := strings.Fields(wrapped + " " + line)
+		if len(f) == 1 {
+			wrapped = f[0]
+			continue
+		}
+		wrapped = ""
+		if len(f) < 2 {
+			continue
+		}
+		pkg := LinuxPackage{name: f[0], Version: f[1], Security: security}
+		if i := strings.LastIndex(f[0], "."); i > 0 {
+			pkg.name, pkg.Architecture = f[0][:i], f[0][i+1:]
+		}
+		if i := strings.Index(f[1], ":"); i > 0 {
+			pkg.Epoch = f[1][:i]
+		}
+		if i := strings.LastIndex(f[1], "-"); i > 0 {
+			pkg.Release = f[1][i+1:]
+		}
+		list = append(list, pkg)
 	}
-	return parsePacakgesFromBytes(stdout, true)
+	return rpmKeys(list)
 }
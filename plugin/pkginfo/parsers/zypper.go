@@ -0,0 +1,139 @@
+package parsers
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// zypperUpdate is an update in zypper's xml output: a package or a patch.
+type zypperUpdate struct {
+	Kind     string        `xml:"kind,attr"`
+	Name     string        `xml:"name,attr"`
+	Edition  string        `xml:"edition,attr"`
+	Arch     string        `xml:"arch,attr"`
+	Category string        `xml:"category,attr"`
+	Severity string        `xml:"severity,attr"`
+	Status   string        `xml:"status,attr"`
+	Issues   []zypperIssue `xml:"issue-list>issue"`
+}
+
+// zypperIssue is an issue, such as a CVE, addressed by a patch.
+type zypperIssue struct {
+	Type string `xml:"type,attr"`
+	ID   string `xml:"id,attr"`
+}
+
+type zypperStream struct {
+	Updates []zypperUpdate `xml:"update-status>update-list>update"`
+	// The install summary of a dry run, listing what would be upgraded and
+	// installed.
+	Upgrades []zypperSolvable `xml:"install-summary>to-upgrade>solvable"`
+	Installs []zypperSolvable `xml:"install-summary>to-install>solvable"`
+}
+
+// zypperSolvable is a package or patch in zypper's install summary.
+type zypperSolvable struct {
+	Type    string `xml:"type,attr"`
+	Name    string `xml:"name,attr"`
+	Edition string `xml:"edition,attr"`
+	Arch    string `xml:"arch,attr"`
+}
+
+// GetZypperPkgs creates map of available and available security packages from zypper and installed packages from rpm, as well as a list of errors that occurred generating that list. Available security packages are the packages that installing the needed security patches would upgrade or install.
+func GetZypperPkgs() (map[string]map[string]LinuxPackage, []error) {
+	errors := []error{}
+	out := make(map[string]map[string]LinuxPackage)
+	avail, err := getZypperAvailablePackages()
+	errors = sourceErrors(errors, "available", err)
+	out["available"] = avail
+	availSec, err := getZypperSecurityPackages()
+	errors = sourceErrors(errors, "available_security", err)
+	out["available_security"] = availSec
+	installed, err := getRpmInstalledPackages()
+	errors = sourceErrors(errors, "installed", err)
+	out["installed"] = installed
+	return out, errors
+}
+
+func getZypperAvailablePackages() (map[string]LinuxPackage, error) {
+	out, err := zypper("list-updates", "--type", "package")
+	if err != nil {
+		return nil, err
+	}
+	return parseZypperUpdates(out, false)
+}
+
+// getZypperSecurityPackages returns the packages that installing the needed
+// security patches would change, from a dry run of zypper patch. Where patches
+// to zypper itself are needed, zypper patch installs only those first, so
+// only their packages are listed until they are installed.
+func getZypperSecurityPackages() (map[string]LinuxPackage, error) {
+	out, err := zypper("patch", "--dry-run", "--category", "security")
+	if err != nil {
+		return nil, err
+	}
+	return parseZypperSummary(out)
+}
+
+// zypper runs a zypper command non-interactively with xml output.
+func zypper(args ...string) ([]byte, error) {
+	return pkgCmd{
+		name: "zypper",
+		args: append([]string{"--non-interactive", "--xmlout"}, args...),
+		// Exit statuses 100 to 103 are informational: updates are needed,
+		// security updates are needed, a reboot is needed, or zypper must be
+		// restarted after updating itself. Higher statuses report problems,
+		// such as repositories that could not be refreshed, and are failures.
+		ok: func(code int, stderr []byte) bool {
+			return code >= 100 && code <= 103
+		},
+	}.run()
+}
+
+// parseZypperUpdates parses the package updates in zypper's xml output,
+// keyed by rpmKeys as installed packages are.
+func parseZypperUpdates(b []byte, security bool) (map[string]LinuxPackage, error) {
+	var stream zypperStream
+	if err := xml.Unmarshal(b, &stream); err != nil {
+		return nil, err
+	}
+	var list []LinuxPackage
+	for _, u := range stream.Updates {
+		if u.Kind != "package" {
+			continue
+		}
+		list = append(list, zypperPackage(u.Name, u.Edition, u.Arch, security || u.Category == "security"))
+	}
+	return rpmKeys(list), nil
+}
+
+// parseZypperSummary parses the packages to be upgraded or installed in the
+// install summary of a zypper dry run, as security updates, keyed by rpmKeys.
+// The patches to be installed are left out.
+func parseZypperSummary(b []byte) (map[string]LinuxPackage, error) {
+	var stream zypperStream
+	if err := xml.Unmarshal(b, &stream); err != nil {
+		return nil, err
+	}
+	var list []LinuxPackage
+	for _, s := range append(stream.Upgrades, stream.Installs...) {
+		if s.Type != "package" {
+			continue
+		}
+		list = append(list, zypperPackage(s.Name, s.Edition, s.Arch, true))
+	}
+	return rpmKeys(list), nil
+}
+
+// zypperPackage returns a package of the given [epoch:]version-release
+// edition.
+func zypperPackage(name, edition, arch string, security bool) LinuxPackage {
+	pkg := LinuxPackage{name: name, Version: edition, Architecture: arch, Security: security}
+	if i := strings.Index(edition, ":"); i > 0 {
+		pkg.Epoch = edition[:i]
+	}
+	if i := strings.LastIndex(edition, "-"); i > 0 {
+		pkg.Release = edition[i+1:]
+	}
+	return pkg
+}
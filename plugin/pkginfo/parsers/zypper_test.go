@@ -0,0 +1,62 @@
+// +build unit
+
+package parsers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseZypperUpdates(t *testing.T) {
+	assert := assert.New(t)
+	out := `<?xml version='1.0'?>
+<stream>
+<message type="info">Loading repository data...</message>
+<update-status version="0.6">
+<update-list>
+<update kind="package" name="libopenssl1_1" edition="1.1.1l-150400.7.16.1" arch="x86_64"/>
+<update kind="patch" name="SUSE-SLE-Module-Basesystem-15-SP4-2022-4148" edition="1" arch="noarch" status="needed" category="security" severity="important"><summary>Security update for openssl-1_1</summary></update>
+</update-list>
+</update-status>
+</stream>`
+	pkgs, err := parseZypperUpdates([]byte(out), false)
+	assert.NoError(err)
+	assert.Len(pkgs, 1, "patches left out")
+	assert.Equal(LinuxPackage{
+		name:         "libopenssl1_1",
+		Version:      "1.1.1l-150400.7.16.1",
+		Release:      "150400.7.16.1",
+		Architecture: "x86_64",
+	}, pkgs["libopenssl1_1.x86_64"], "keyed as installed packages are")
+	_, err = parseZypperUpdates([]byte("not xml <"), false)
+	assert.Error(err)
+}
+
+func TestParseZypperSummary(t *testing.T) {
+	assert := assert.New(t)
+	out := `<?xml version='1.0'?>
+<stream>
+<message type="info">Loading repository data...</message>
+<install-summary download-size="1826304" space-usage-diff="4326" packages-to-change="3">
+<to-upgrade>
+<solvable type="package" name="libopenssl1_1" edition="1.1.1l-150400.7.28.1" arch="x86_64" edition-old="1.1.1l-150400.7.25.1" arch-old="x86_64"/>
+<solvable type="package" name="openssl-1_1" edition="1.1.1l-150400.7.28.1" arch="x86_64" edition-old="1.1.1l-150400.7.25.1" arch-old="x86_64"/>
+</to-upgrade>
+<to-install>
+<solvable type="patch" name="SUSE-SLE-Module-Basesystem-15-SP4-2023-1234" edition="1" arch="noarch"/>
+</to-install>
+</install-summary>
+</stream>`
+	pkgs, err := parseZypperSummary([]byte(out))
+	assert.NoError(err)
+	assert.Len(pkgs, 2)
+	assert.Equal(LinuxPackage{
+		name:         "openssl-1_1",
+		Version:      "1.1.1l-150400.7.28.1",
+		Security:     true,
+		Release:      "150400.7.28.1",
+		Architecture: "x86_64",
+	}, pkgs["openssl-1_1.x86_64"])
+	assert.NotContains(pkgs, "SUSE-SLE-Module-Basesystem-15-SP4-2023-1234")
+}
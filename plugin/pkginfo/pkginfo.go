@@ -2,6 +2,7 @@ package pkginfo
 
 import (
 	"encoding/json"
+	"unicode/utf8"
 
 	"github.com/cleardataeng/mirach/plugin"
 	"github.com/cleardataeng/mirach/plugin/pkginfo/parsers"
@@ -9,27 +10,144 @@ import (
 	"github.com/shirou/gopsutil/host"
 )
 
-// PkgStatus represents the OS and map of list of LinuxPackage.
+// PkgStatus represents the OS and map of list of LinuxPackage, and the
+// security advisories behind pending updates keyed by advisory ID.
+// Sources holds the status of collecting each group and the advisories.
+// Seq is set when reporting deltas, to number the full snapshots and deltas.
 type PkgStatus struct {
-	OS       string
-	Packages map[string]map[string]parsers.LinuxPackage `json:"pkg_info"`
+	OS         string
+	Packages   map[string]map[string]parsers.LinuxPackage `json:"pkg_info"`
+	Advisories map[string]parsers.Advisory                `json:"advisories,omitempty"`
+	Sources    map[string]SourceStatus                    `json:"sources,omitempty"`
+	Seq        uint64                                     `json:"seq,omitempty"`
 }
 
 // KBStatus represents the OS and map of list of KBArticle.
 type KBStatus struct {
 	Articles map[string]map[string]parsers.KBArticle `json:"pkg_info"`
+	Sources  map[string]SourceStatus                 `json:"sources,omitempty"`
+}
+
+// SourceStatus is the outcome of collecting a source of package information,
+// such as the installed packages. When a package manager command failed, it
+// has the command, its exit code, the start of its standard error, how long
+// it ran over how many attempts, and whether it failed because another
+// process held the package database lock. Later errors of the same source
+// are appended to Error, up to maxSourceError bytes. Packages lists the
+// packages whose information is missing when the source failed for only some,
+// as the changelogs do.
+type SourceStatus struct {
+	OK             bool     `json:"ok"`
+	Error          string   `json:"error,omitempty"`
+	Command        string   `json:"command,omitempty"`
+	ExitCode       int      `json:"exit_code,omitempty"`
+	Stderr         string   `json:"stderr,omitempty"`
+	Duration       float64  `json:"duration_seconds,omitempty"`
+	Attempts       int      `json:"attempts,omitempty"`
+	LockContention bool     `json:"lock_contention,omitempty"`
+	Packages       []string `json:"packages,omitempty"`
+}
+
+// maxSourceError limits the length of a SourceStatus's Error.
+const maxSourceError = 1024
+
+// sourceStatus returns the status of each source given the errors collecting
+// them. Errors not attributed to a source are reported under pkginfo.
+func sourceStatus(sources []string, errs []error) map[string]SourceStatus {
+	status := map[string]SourceStatus{}
+	for _, s := range sources {
+		status[s] = SourceStatus{OK: true}
+	}
+	for _, err := range errs {
+		source := "pkginfo"
+		var pkgs []string
+		if se, ok := err.(*parsers.SourceError); ok {
+			source, err, pkgs = se.Source, se.Err, se.Packages
+		}
+		if s, ok := status[source]; ok && !s.OK {
+			s.Error = capError(s.Error + "; " + err.Error())
+			s.Packages = append(s.Packages, pkgs...)
+			status[source] = s
+			continue
+		}
+		s := SourceStatus{Error: capError(err.Error()), Packages: pkgs}
+		if ce, ok := err.(*parsers.CmdError); ok {
+			s.Command = ce.Command
+			s.ExitCode = ce.ExitCode
+			s.Stderr = ce.Stderr
+			s.Duration = ce.Duration.Seconds()
+			s.Attempts = ce.Attempts
+			s.LockContention = ce.Locked
+		}
+		status[source] = s
+	}
+	return status
+}
+
+// capError cuts an error to at most maxSourceError bytes, at the start of a
+// character.
+func capError(s string) string {
+	if len(s) <= maxSourceError {
+		return s
+	}
+	i := maxSourceError
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return s[:i] + "..."
+}
+
+// Failed reports whether collecting source failed, in which case its packages
+// or advisories are missing or incomplete.
+func (p *PkgStatus) Failed(source string) bool {
+	s, ok := p.Sources[source]
+	return ok && !s.OK
 }
 
 //GetInfo fill in the package status object with info.
 func (p *PkgStatus) GetInfo() {
+	var (
+		errs    []error
+		sources = []string{"available", "available_security", "installed"}
+	)
 	switch p.OS {
 	case "debian":
-		packages, _ := parsers.GetAptDpkgPkgs()
+		packages, pkgErrs := parsers.GetAptDpkgPkgs()
 		p.Packages = packages
+		advisories, advErrs := parsers.GetAptAdvisories(packages)
+		p.Advisories = advisories
+		errs = append(pkgErrs, advErrs...)
+		sources = append(sources, "advisories", "changelogs")
 	case "rhel":
-		packages, _ := parsers.GetYumPkgs()
+		packages, pkgErrs := parsers.GetYumPkgs()
 		p.Packages = packages
+		advisories, err := parsers.GetYumAdvisories()
+		p.Advisories = advisories
+		errs = advisoryErrors(pkgErrs, err)
+		sources = append(sources, "advisories")
+	case "alpine":
+		p.Packages, errs = parsers.GetApkPkgs()
+	case "suse":
+		packages, pkgErrs := parsers.GetZypperPkgs()
+		p.Packages = packages
+		advisories, err := parsers.GetZypperAdvisories()
+		p.Advisories = advisories
+		errs = advisoryErrors(pkgErrs, err)
+		sources = append(sources, "advisories")
+	case "arch":
+		p.Packages, errs = parsers.GetPacmanPkgs()
+	default:
+		return
 	}
+	p.Sources = sourceStatus(sources, errs)
+}
+
+// advisoryErrors appends err, if any, to errs as an error of the advisories.
+func advisoryErrors(errs []error, err error) []error {
+	if err == nil {
+		return errs
+	}
+	return append(errs, &parsers.SourceError{Source: "advisories", Err: err})
 }
 
 //String returns the filled in data from PkgStatus as str.
@@ -38,16 +156,21 @@ func (p *PkgStatus) String() string {
 	return string(s)
 }
 
-//GetInfoGroup returns the filled in data for given group.
+//GetInfoGroup returns the filled in data for given group, or the advisories.
 func (p *PkgStatus) GetInfoGroup(infoGroup string) string {
+	if infoGroup == "advisories" {
+		s, _ := json.MarshalIndent(p.Advisories, "", "  ")
+		return string(s)
+	}
 	s, _ := json.MarshalIndent(p.Packages[infoGroup], "", "  ")
 	return string(s)
 }
 
 //GetInfo fill in the kb status object with info.
 func (k *KBStatus) GetInfo() {
-	articles, _ := parsers.GetWindowsKBs()
+	articles, errs := parsers.GetWindowsKBs()
 	k.Articles = articles
+	k.Sources = sourceStatus([]string{"available", "available_security", "installed"}, errs)
 }
 
 //GetInfoGroup returns the filled in data for given group.
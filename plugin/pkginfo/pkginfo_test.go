@@ -3,11 +3,23 @@
 package pkginfo
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/cleardataeng/mirach/plugin/pkginfo/parsers"
+	"github.com/cleardataeng/mirach/util"
 
 	"encoding/json"
+
+	"github.com/shirou/gopsutil/host"
+	"github.com/spf13/afero"
 )
 
 type MockInfoGroup struct {
@@ -107,3 +119,199 @@ func TestPkgStatusGetString(t *testing.T) {
 	}
 
 }
+
+func TestDiff(t *testing.T) {
+	base := &PkgStatus{OS: "debian", Packages: map[string]map[string]parsers.LinuxPackage{
+		"installed": {
+			"ssh": {Version: "1.0"},
+			"vim": {Version: "8.0"},
+		},
+		"available": {
+			"ssh": {Version: "1.1"},
+		},
+	}}
+	cur := &PkgStatus{OS: "debian", Packages: map[string]map[string]parsers.LinuxPackage{
+		"installed": {
+			"ssh":  {Version: "1.1"},
+			"curl": {Version: "7.0"},
+		},
+		"available_security": {
+			"curl": {Version: "7.1", Security: true},
+		},
+	}}
+	d := Diff(base, cur)
+	if _, ok := d.Added["curl"]; !ok || len(d.Added) != 1 {
+		t.Error("added pkgs don't match")
+	}
+	if _, ok := d.Removed["vim"]; !ok || len(d.Removed) != 1 {
+		t.Error("removed pkgs don't match")
+	}
+	if c := d.Upgraded["ssh"]; c.From.Version != "1.0" || c.To.Version != "1.1" || len(d.Upgraded) != 1 {
+		t.Error("upgraded pkgs don't match")
+	}
+	if d.Available["available_security"]["curl"].Version != "7.1" || len(d.Available) != 1 {
+		t.Error("newly available pkgs don't match")
+	}
+	if len(d.NoLongerAvailable["available"]) != 1 || d.NoLongerAvailable["available"][0] != "ssh" {
+		t.Error("no longer available pkgs don't match")
+	}
+
+	cur.Packages["available"] = nil
+	cur.Sources = map[string]SourceStatus{"available": {Error: "apt-get upgrade: exit status 100"}}
+	d = Diff(base, cur)
+	if len(d.NoLongerAvailable) != 0 {
+		t.Error("failed source reported as no longer available")
+	}
+	if d.Sources["available"].OK || d.Sources["available"].Error == "" {
+		t.Error("failed source status missing")
+	}
+}
+
+func TestSourceStatus(t *testing.T) {
+	errs := []error{
+		&parsers.SourceError{Source: "available", Err: &parsers.CmdError{
+			Command:  "apt-get upgrade -qq --just-print",
+			ExitCode: 100,
+			Stderr:   "E: Could not get lock /var/lib/dpkg/lock-frontend",
+			Duration: 50 * time.Second,
+			Attempts: 4,
+			Locked:   true,
+			Err:      errors.New("exit status 100"),
+		}},
+		&parsers.SourceError{Source: "advisories", Err: errors.New("apt-get changelog a: exit status 1")},
+		&parsers.SourceError{Source: "advisories", Err: errors.New("apt-get changelog b: exit status 1")},
+		errors.New("unattributed"),
+	}
+	status := sourceStatus([]string{"available", "installed", "advisories"}, errs)
+	if s := status["installed"]; !s.OK || s.Error != "" {
+		t.Error("installed should be ok")
+	}
+	s := status["available"]
+	if s.OK || s.Command != "apt-get upgrade -qq --just-print" || s.ExitCode != 100 || !s.LockContention || s.Attempts != 4 || s.Duration != 50 {
+		t.Errorf("available status doesn't match: %+v", s)
+	}
+	if s := status["advisories"]; s.OK || s.Error != "apt-get changelog a: exit status 1; apt-get changelog b: exit status 1" {
+		t.Errorf("advisories status doesn't match: %+v", s)
+	}
+	if s := status["pkginfo"]; s.OK || s.Error != "unattributed" {
+		t.Error("unattributed error missing")
+	}
+
+	errs = nil
+	for i := 0; i < 100; i++ {
+		errs = append(errs, &parsers.SourceError{Source: "changelogs", Err: errors.New("apt-get changelog libfoo: exit status 100"), Packages: []string{fmt.Sprint("libfoo", i)}})
+	}
+	status = sourceStatus([]string{"changelogs"}, errs)
+	if s := status["changelogs"]; len(s.Error) != maxSourceError+len("...") {
+		t.Errorf("changelogs error not capped: %d bytes", len(s.Error))
+	}
+	if s := status["changelogs"]; len(s.Packages) != 100 || s.Packages[99] != "libfoo99" {
+		t.Errorf("changelogs packages don't match: %v", s.Packages)
+	}
+
+	status = sourceStatus([]string{"changelogs"}, []error{errors.New("x" + strings.Repeat("é", maxSourceError))})
+	if s := status["pkginfo"].Error; !utf8.ValidString(s) || len(s) != maxSourceError-1+len("...") {
+		t.Errorf("error not capped at a character: %q", s[len(s)-5:])
+	}
+}
+
+func testSBOM(t *testing.T, format string) *SBOM {
+	util.ResetTestFs()
+	util.SetFs(util.TestFs)
+	status, err := afero.ReadFile(util.OSFs, "../../test_resources/pkginfo/dpkg_status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	afero.WriteFile(util.TestFs, "/var/lib/dpkg/status", status, 0644)
+	pkgs, err := parsers.GetInstalledPkgs("debian")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &SBOM{
+		Format:   format,
+		Host:     &host.InfoStat{Hostname: "web1", Platform: "debian", PlatformFamily: "debian", PlatformVersion: "11.7"},
+		Packages: pkgs,
+		Created:  time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC),
+		Serial:   "3e671687-395b-41f5-a30f-a58921a69b79",
+	}
+}
+
+func TestSBOMCycloneDX(t *testing.T) {
+	var bom cdxBOM
+	if err := json.Unmarshal([]byte(testSBOM(t, SBOMCycloneDX).String()), &bom); err != nil {
+		t.Fatal(err)
+	}
+	if bom.BOMFormat != "CycloneDX" || bom.SerialNumber != "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79" {
+		t.Error("bom header doesn't match")
+	}
+	if bom.Metadata.Component.Type != "operating-system" || bom.Metadata.Component.Version != "11.7" {
+		t.Error("bom metadata component doesn't match")
+	}
+	purls := []string{
+		"pkg:deb/debian/base-files@11.1%2Bdeb11u7?arch=amd64&distro=debian-11.7",
+		"pkg:deb/debian/libc6@2.31-13%2Bdeb11u5?arch=amd64&distro=debian-11.7&upstream=glibc",
+		"pkg:deb/debian/libc6@2.31-13%2Bdeb11u5?arch=i386&distro=debian-11.7&upstream=glibc",
+		"pkg:deb/debian/libssl1.1@1.1.1n-0%2Bdeb11u4?arch=amd64&distro=debian-11.7&upstream=openssl",
+		"pkg:deb/debian/openssh-server@1%3A8.4p1-5%2Bdeb11u1?arch=amd64&distro=debian-11.7&upstream=openssh",
+	}
+	if len(bom.Components) != len(purls) {
+		t.Fatalf("got %d components, want %d", len(bom.Components), len(purls))
+	}
+	for i, c := range bom.Components {
+		if c.PURL != purls[i] || c.BOMRef != purls[i] {
+			t.Errorf("component %d purl %s, want %s", i, c.PURL, purls[i])
+		}
+	}
+	if bom.Components[4].Name != "openssh-server" || bom.Components[4].Version != "1:8.4p1-5+deb11u1" {
+		t.Error("component doesn't match")
+	}
+}
+
+func TestSBOMSPDX(t *testing.T) {
+	var doc spdxDocument
+	if err := json.Unmarshal([]byte(testSBOM(t, SBOMSPDX).String()), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.SPDXVersion != "SPDX-2.3" || doc.CreationInfo.Created != "2023-06-01T12:00:00Z" {
+		t.Error("document header doesn't match")
+	}
+	if len(doc.Packages) != 6 || len(doc.Relationships) != 6 {
+		t.Fatalf("got %d packages and %d relationships, want 6 of each", len(doc.Packages), len(doc.Relationships))
+	}
+	if doc.Packages[0].PrimaryPackagePurpose != "OPERATING-SYSTEM" {
+		t.Error("operating system package doesn't match")
+	}
+	if p := doc.Packages[3]; p.SPDXID != "SPDXRef-Package-libc6-i386" || p.ExternalRefs[0].ReferenceType != "purl" {
+		t.Error("package doesn't match")
+	}
+	if r := doc.Relationships[5]; r.RelationshipType != "CONTAINS" || r.RelatedSPDXElement != "SPDXRef-Package-openssh-server" {
+		t.Error("relationship doesn't match")
+	}
+}
+
+func TestPurlRPM(t *testing.T) {
+	// Package names are only set by the parsers, so rpm is faked to list one.
+	dir, err := ioutil.TempDir("", "mirach-rpm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rpm := "#!/bin/sh\nprintf 'openssl\\t1\\t1.1.1k\\t7.el8\\tx86_64\\tCentOS\\t1654041600\\topenssl-1.1.1k-7.el8.src.rpm\\n'\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "rpm"), []byte(rpm), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	pkgs, err := parsers.GetInstalledPkgs("rhel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := pkgs["openssl.x86_64"]
+	if !ok {
+		t.Fatalf("openssl.x86_64 not listed: %v", pkgs)
+	}
+	s := &SBOM{Host: &host.InfoStat{Platform: "centos", PlatformFamily: "rhel", PlatformVersion: "8"}}
+	if purl := s.purl(p); purl != "pkg:rpm/centos/openssl@1.1.1k-7.el8?arch=x86_64&distro=centos-8&epoch=1" {
+		t.Errorf("unexpected purl %s", purl)
+	}
+}
@@ -0,0 +1,321 @@
+package pkginfo
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cleardataeng/mirach/plugin/compinfo"
+	"github.com/cleardataeng/mirach/plugin/pkginfo/parsers"
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/google/uuid"
+	"github.com/shirou/gopsutil/host"
+)
+
+// SBOM formats.
+const (
+	SBOMCycloneDX = "cyclonedx"
+	SBOMSPDX      = "spdx"
+)
+
+// SBOM is a software bill of materials of the installed packages, rendered as
+// CycloneDX 1.5 or SPDX 2.3 json.
+type SBOM struct {
+	Format   string
+	Host     *host.InfoStat
+	Packages map[string]parsers.LinuxPackage
+	Created  time.Time
+	Serial   string // uuid identifying this document
+}
+
+// GetInfo fills in the host, from compinfo, and its installed packages.
+func (s *SBOM) GetInfo() {
+	if s.Format != SBOMCycloneDX && s.Format != SBOMSPDX {
+		panic(fmt.Errorf("unknown sbom format: %s", s.Format))
+	}
+	s.Host = compinfo.GetSysInfo().Host
+	pkgs, err := parsers.GetInstalledPkgs(s.Host.PlatformFamily)
+	if err != nil {
+		panic(err)
+	}
+	s.Packages = pkgs
+	s.Created = time.Now().UTC()
+	s.Serial = uuid.New().String()
+}
+
+// String returns the SBOM as json in its format.
+func (s *SBOM) String() string {
+	var v interface{}
+	if s.Format == SBOMSPDX {
+		v = s.spdx()
+	} else {
+		v = s.cycloneDX()
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// SBOMString will load up and return an SBOM of the installed packages in
+// the given format.
+func SBOMString(format string) string {
+	s := &SBOM{Format: format}
+	s.GetInfo()
+	return s.String()
+}
+
+// keys returns the keys of the packages in order.
+func (s *SBOM) keys() []string {
+	keys := make([]string, 0, len(s.Packages))
+	for k := range s.Packages {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// distro returns the distribution and release, e.g. debian-11.7.
+func (s *SBOM) distro() string {
+	if s.Host.PlatformVersion == "" {
+		return s.Host.Platform
+	}
+	return s.Host.Platform + "-" + s.Host.PlatformVersion
+}
+
+// purl returns the package url of an installed package, such as
+// pkg:deb/debian/openssl@1.1.1n-0%2Bdeb11u4?arch=amd64&distro=debian-11.7.
+// Versions of rpm packages leave out the epoch, which is a qualifier instead.
+func (s *SBOM) purl(p parsers.LinuxPackage) string {
+	var typ string
+	version := p.Version
+	qualifiers := map[string]string{
+		"arch":   p.Architecture,
+		"distro": s.distro(),
+	}
+	switch s.Host.PlatformFamily {
+	case "debian":
+		typ = "deb"
+	case "rhel", "suse":
+		typ = "rpm"
+		if p.Epoch != "" {
+			version = strings.TrimPrefix(version, p.Epoch+":")
+			if p.Epoch != "0" {
+				qualifiers["epoch"] = p.Epoch
+			}
+		}
+	case "alpine":
+		typ = "apk"
+	case "arch":
+		typ = "alpm"
+	default:
+		typ = "generic"
+	}
+	if p.Source != "" && p.Source != p.Name() {
+		qualifiers["upstream"] = p.Source
+	}
+	purl := fmt.Sprintf("pkg:%s/%s/%s@%s", typ, purlEscape(s.Host.Platform), purlEscape(p.Name()), purlEscape(version))
+	var qs []string
+	for k, v := range qualifiers {
+		if v != "" {
+			qs = append(qs, k+"="+purlEscape(v))
+		}
+	}
+	if len(qs) > 0 {
+		sort.Strings(qs)
+		purl += "?" + strings.Join(qs, "&")
+	}
+	return purl
+}
+
+// purlEscape percent-encodes all but unreserved characters.
+func purlEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+type cdxBOM struct {
+	BOMFormat    string         `json:"bomFormat"`
+	SpecVersion  string         `json:"specVersion"`
+	SerialNumber string         `json:"serialNumber"`
+	Version      int            `json:"version"`
+	Metadata     cdxMetadata    `json:"metadata"`
+	Components   []cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     []cdxTool    `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTool struct {
+	Vendor  string `json:"vendor"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type cdxComponent struct {
+	Type       string        `json:"type"`
+	BOMRef     string        `json:"bom-ref"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version,omitempty"`
+	Publisher  string        `json:"publisher,omitempty"`
+	PURL       string        `json:"purl,omitempty"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func (s *SBOM) cycloneDX() cdxBOM {
+	bom := cdxBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + s.Serial,
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: s.Created.Format(time.RFC3339),
+			Tools:     []cdxTool{{Vendor: "ClearDATA", Name: "mirach", Version: util.Version}},
+			Component: cdxComponent{
+				Type:    "operating-system",
+				BOMRef:  "os:" + s.distro(),
+				Name:    s.Host.Platform,
+				Version: s.Host.PlatformVersion,
+				Properties: []cdxProperty{
+					{Name: "mirach:hostname", Value: s.Host.Hostname},
+					{Name: "mirach:kernel_version", Value: s.Host.KernelVersion},
+				},
+			},
+		},
+		Components: []cdxComponent{},
+	}
+	for _, k := range s.keys() {
+		p := s.Packages[k]
+		purl := s.purl(p)
+		bom.Components = append(bom.Components, cdxComponent{
+			Type:      "library",
+			BOMRef:    purl,
+			Name:      p.Name(),
+			Version:   p.Version,
+			Publisher: p.Vendor,
+			PURL:      purl,
+		})
+	}
+	return bom
+}
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name                  string            `json:"name"`
+	SPDXID                string            `json:"SPDXID"`
+	VersionInfo           string            `json:"versionInfo,omitempty"`
+	Supplier              string            `json:"supplier,omitempty"`
+	DownloadLocation      string            `json:"downloadLocation"`
+	FilesAnalyzed         bool              `json:"filesAnalyzed"`
+	LicenseConcluded      string            `json:"licenseConcluded"`
+	LicenseDeclared       string            `json:"licenseDeclared"`
+	CopyrightText         string            `json:"copyrightText"`
+	PrimaryPackagePurpose string            `json:"primaryPackagePurpose,omitempty"`
+	ExternalRefs          []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+const spdxNoAssertion = "NOASSERTION"
+
+var spdxIDInvalid = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+func (s *SBOM) spdx() spdxDocument {
+	const osID = "SPDXRef-OperatingSystem"
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              "mirach-" + s.Host.Hostname,
+		DocumentNamespace: fmt.Sprintf("https://spdx.org/spdxdocs/mirach-%s-%s", purlEscape(s.Host.Hostname), s.Serial),
+		CreationInfo: spdxCreationInfo{
+			Created:  s.Created.Format(time.RFC3339),
+			Creators: []string{"Organization: ClearDATA", "Tool: mirach-" + util.Version},
+		},
+		Packages: []spdxPackage{{
+			Name:                  s.Host.Platform,
+			SPDXID:                osID,
+			VersionInfo:           s.Host.PlatformVersion,
+			DownloadLocation:      spdxNoAssertion,
+			LicenseConcluded:      spdxNoAssertion,
+			LicenseDeclared:       spdxNoAssertion,
+			CopyrightText:         spdxNoAssertion,
+			PrimaryPackagePurpose: "OPERATING-SYSTEM",
+		}},
+		Relationships: []spdxRelationship{{"SPDXRef-DOCUMENT", "DESCRIBES", osID}},
+	}
+	seen := map[string]bool{}
+	for _, k := range s.keys() {
+		p := s.Packages[k]
+		id := "SPDXRef-Package-" + spdxIDInvalid.ReplaceAllString(k, "-")
+		for n := 2; seen[id]; n++ {
+			id = fmt.Sprintf("SPDXRef-Package-%s-%d", spdxIDInvalid.ReplaceAllString(k, "-"), n)
+		}
+		seen[id] = true
+		supplier := spdxNoAssertion
+		if p.Vendor != "" {
+			supplier = "Organization: " + p.Vendor
+		}
+		doc.Packages = append(doc.Packages, spdxPackage{
+			Name:             p.Name(),
+			SPDXID:           id,
+			VersionInfo:      p.Version,
+			Supplier:         supplier,
+			DownloadLocation: spdxNoAssertion,
+			LicenseConcluded: spdxNoAssertion,
+			LicenseDeclared:  spdxNoAssertion,
+			CopyrightText:    spdxNoAssertion,
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  s.purl(p),
+			}},
+		})
+		doc.Relationships = append(doc.Relationships, spdxRelationship{osID, "CONTAINS", id})
+	}
+	return doc
+}
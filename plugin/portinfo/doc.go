@@ -0,0 +1,29 @@
+/*
+Package portinfo is a plugin that provides information about the network
+exposure of the asset.
+
+It reports every listening TCP socket and unconnected UDP socket with its local
+address, port, and protocol, and the pid, name, and executable of the process
+that owns it. Where dpkg or rpm is the package database, the installed package
+that owns the executable is included. Processes of other users may only be
+known when run as root; their sockets are reported with pid 0.
+
+The active firewall rules are listed by each of nft list ruleset,
+iptables-save, and ip6tables-save that is installed, with comments and packet
+and byte counters left out so that the listing only changes with the rules.
+A tool that has not finished within 30 seconds is killed and reported as an
+error of its rule set.
+
+Calling via the CLI
+
+To run this plugin from the command line interface:
+
+	mirach portinfo
+
+Calling via the API
+
+To use this plugin via the API:
+
+	portinfo.String()
+*/
+package portinfo
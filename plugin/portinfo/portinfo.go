@@ -0,0 +1,218 @@
+package portinfo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cleardataeng/mirach/plugin"
+	"github.com/cleardataeng/mirach/plugin/pkginfo/parsers"
+	"github.com/cleardataeng/mirach/plugin/procinfo"
+
+	"github.com/shirou/gopsutil/host"
+	"github.com/shirou/gopsutil/process"
+)
+
+// PortInfoGroup lists the listening sockets, with the process and package
+// behind each, and the active firewall rules.
+type PortInfoGroup struct {
+	Listeners []Listener `json:"listeners"`
+	Firewall  []RuleSet  `json:"firewall"`
+}
+
+// Listener is a listening TCP or UDP socket. The process is unknown, with pid
+// 0, when it cannot be read, as for other users' processes when not run as
+// root. Package is the installed package that owns the executable, where dpkg
+// or rpm can tell.
+type Listener struct {
+	Protocol string `json:"protocol"` // tcp, tcp6, udp, or udp6
+	Address  string `json:"address"`
+	Port     uint32 `json:"port"`
+	PID      int32  `json:"pid"`
+	Process  string `json:"process,omitempty"`
+	Exe      string `json:"exe,omitempty"`
+	Package  string `json:"package,omitempty"`
+}
+
+// RuleSet is the listing of firewall rules by one tool, without comments or
+// packet and byte counters so that it only changes with the rules.
+type RuleSet struct {
+	Command string   `json:"command"`
+	Rules   []string `json:"rules"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// firewallCommands list the active firewall rules. Those not installed are
+// skipped.
+var firewallCommands = [][]string{
+	{"nft", "list", "ruleset"},
+	{"iptables-save"},
+	{"ip6tables-save"},
+}
+
+// ruleTimeout limits how long each of firewallCommands may run, so that one
+// hung on a wedged netlink socket does not block the plugin.
+var ruleTimeout = 30 * time.Second
+
+var (
+	chainCountersRE = regexp.MustCompile(`\s\[\d+:\d+\]$`)
+	nftCountersRE   = regexp.MustCompile(`counter packets \d+ bytes \d+`)
+)
+
+// lookupProcess returns the name and executable of a process.
+var lookupProcess = func(pid int32) (string, string) {
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return "", ""
+	}
+	name, _ := p.Name()
+	exe, _ := p.Exe()
+	return name, exe
+}
+
+// GetInfo lists the listening sockets and firewall rules.
+func (g *PortInfoGroup) GetInfo() {
+	socks, err := procinfo.ListeningSockets()
+	if err != nil {
+		panic(err)
+	}
+	g.Listeners = listeners(socks)
+	g.findPackages()
+	g.Firewall = []RuleSet{}
+	for _, c := range firewallCommands {
+		if _, err := exec.LookPath(c[0]); err != nil {
+			continue
+		}
+		g.Firewall = append(g.Firewall, listRules(c[0], c[1:]...))
+	}
+}
+
+// String marshal PortInfoGroup to string and return
+func (g *PortInfoGroup) String() string {
+	s, _ := json.Marshal(g)
+	return string(s)
+}
+
+// GetInfo will load up and return the PortInfoGroup.
+func GetInfo() plugin.InfoGroup {
+	g := new(PortInfoGroup)
+	g.GetInfo()
+	return g
+}
+
+// String will load up and return the PortInfoGroup as a string.
+func String() string {
+	return GetInfo().String()
+}
+
+// listeners returns the listening sockets, given by pid, with their process,
+// ordered by port.
+func listeners(socks map[int32][]procinfo.Socket) []Listener {
+	out := []Listener{}
+	for pid, ss := range socks {
+		var name, exe string
+		if pid != 0 {
+			name, exe = lookupProcess(pid)
+		}
+		for _, s := range ss {
+			out = append(out, Listener{
+				Protocol: s.Protocol,
+				Address:  s.Address,
+				Port:     s.Port,
+				PID:      pid,
+				Process:  name,
+				Exe:      exe,
+			})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		if a.Address != b.Address {
+			return a.Address < b.Address
+		}
+		return a.PID < b.PID
+	})
+	return out
+}
+
+// findPackages fills in the package owning each listener's executable. It is
+// left empty where the package database cannot tell.
+func (g *PortInfoGroup) findPackages() {
+	var exes []string
+	seen := map[string]bool{}
+	for _, l := range g.Listeners {
+		if l.Exe != "" && !seen[l.Exe] {
+			seen[l.Exe] = true
+			exes = append(exes, l.Exe)
+		}
+	}
+	if len(exes) == 0 {
+		return
+	}
+	info, err := host.Info()
+	if err != nil {
+		return
+	}
+	owners, err := parsers.GetOwningPkgs(info.PlatformFamily, exes)
+	if err != nil {
+		return
+	}
+	for i, l := range g.Listeners {
+		g.Listeners[i].Package = owners[l.Exe]
+	}
+}
+
+// listRules runs a firewall tool and returns its rules, killing it after
+// ruleTimeout. The tools only read the rules, so take no lock to wait on.
+func listRules(name string, args ...string) RuleSet {
+	rs := RuleSet{Command: strings.Join(append([]string{name}, args...), " "), Rules: []string{}}
+	var stderr bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), ruleTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", ruleTimeout)
+	}
+	if err != nil {
+		rs.Error = err.Error()
+		if s := strings.TrimSpace(stderr.String()); s != "" {
+			rs.Error += ": " + s
+		}
+		return rs
+	}
+	rs.Rules = cleanRules(out)
+	return rs
+}
+
+// cleanRules splits a rule listing into lines, leaving out blank lines and
+// comments, such as the timestamps of iptables-save, and the packet and byte
+// counters of chains and nftables counter statements.
+func cleanRules(b []byte) []string {
+	rules := []string{}
+	for _, line := range strings.Split(string(b), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		line = strings.TrimRight(line, " \t")
+		if strings.HasPrefix(line, ":") {
+			line = chainCountersRE.ReplaceAllString(line, "")
+		}
+		rules = append(rules, nftCountersRE.ReplaceAllString(line, "counter"))
+	}
+	return rules
+}
@@ -0,0 +1,92 @@
+// +build unit
+
+package portinfo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cleardataeng/mirach/plugin/procinfo"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListeners(t *testing.T) {
+	assert := assert.New(t)
+	defer func(f func(int32) (string, string)) { lookupProcess = f }(lookupProcess)
+	lookupProcess = func(pid int32) (string, string) {
+		switch pid {
+		case 700:
+			return "sshd", "/usr/sbin/sshd"
+		case 400:
+			return "systemd-resolve", "/usr/lib/systemd/systemd-resolved"
+		}
+		t.Errorf("unexpected lookup of pid %d", pid)
+		return "", ""
+	}
+	got := listeners(map[int32][]procinfo.Socket{
+		700: {{Protocol: "tcp", Address: "0.0.0.0", Port: 22}, {Protocol: "tcp6", Address: "::", Port: 22}},
+		400: {{Protocol: "udp", Address: "127.0.0.53", Port: 53}},
+		0:   {{Protocol: "tcp", Address: "0.0.0.0", Port: 5432}},
+	})
+	assert.Equal([]Listener{
+		{Protocol: "tcp", Address: "0.0.0.0", Port: 22, PID: 700, Process: "sshd", Exe: "/usr/sbin/sshd"},
+		{Protocol: "tcp6", Address: "::", Port: 22, PID: 700, Process: "sshd", Exe: "/usr/sbin/sshd"},
+		{Protocol: "udp", Address: "127.0.0.53", Port: 53, PID: 400, Process: "systemd-resolve", Exe: "/usr/lib/systemd/systemd-resolved"},
+		{Protocol: "tcp", Address: "0.0.0.0", Port: 5432},
+	}, got)
+}
+
+func TestCleanRules(t *testing.T) {
+	assert := assert.New(t)
+	iptables := `# Generated by iptables-save v1.8.7 on Thu Jun  1 12:00:00 2023
+*filter
+:INPUT DROP [1203:88412]
+:FORWARD DROP [0:0]
+:OUTPUT ACCEPT [5321:611233]
+-A INPUT -i lo -j ACCEPT
+-A INPUT -p tcp -m tcp --dport 22 -j ACCEPT
+COMMIT
+# Completed on Thu Jun  1 12:00:00 2023
+`
+	assert.Equal([]string{
+		"*filter",
+		":INPUT DROP",
+		":FORWARD DROP",
+		":OUTPUT ACCEPT",
+		"-A INPUT -i lo -j ACCEPT",
+		"-A INPUT -p tcp -m tcp --dport 22 -j ACCEPT",
+		"COMMIT",
+	}, cleanRules([]byte(iptables)))
+
+	nft := `table inet filter {
+	chain input {
+		type filter hook input priority filter; policy drop;
+		tcp dport 22 counter packets 42 bytes 2520 accept
+	}
+}
+`
+	assert.Equal([]string{
+		"table inet filter {",
+		"\tchain input {",
+		"\t\ttype filter hook input priority filter; policy drop;",
+		"\t\ttcp dport 22 counter accept",
+		"\t}",
+		"}",
+	}, cleanRules([]byte(nft)))
+}
+
+func TestListRules(t *testing.T) {
+	assert := assert.New(t)
+	rs := listRules("sh", "-c", "echo '-A INPUT -j ACCEPT'")
+	assert.Equal(RuleSet{Command: "sh -c echo '-A INPUT -j ACCEPT'", Rules: []string{"-A INPUT -j ACCEPT"}}, rs)
+	rs = listRules("sh", "-c", "echo 'Operation not permitted (you must be root)' >&2; exit 1")
+	assert.Empty(rs.Rules)
+	assert.Equal("exit status 1: Operation not permitted (you must be root)", rs.Error)
+
+	defer func(d time.Duration) { ruleTimeout = d }(ruleTimeout)
+	ruleTimeout = 100 * time.Millisecond
+	rs = listRules("sleep", "10")
+	assert.Empty(rs.Rules)
+	assert.Equal("timed out after 100ms", rs.Error)
+}
@@ -0,0 +1,35 @@
+/*
+Package procinfo is a plugin that provides information about the processes
+running on the asset.
+
+Each process is listed with its pid, parent pid, user, name, executable,
+command line, start time, average CPU use since it started, resident set size,
+and the TCP and UDP sockets it listens on. Processes can be filtered by user and
+name, each a list of glob patterns, and command lines can be redacted to only
+the executable, or the name when the executable is unknown, as arguments may
+hold secrets. At most a maximum number of
+processes, in order of pid, are listed; when there are more, truncated is set
+and total is how many matched.
+
+Details of other users' processes, such as their executable and sockets, may
+only be known when run as root.
+
+Calling via the CLI
+
+To run this plugin from the command line interface:
+
+	mirach procinfo
+	# or
+	mirach procinfo --user 'www-*' --name nginx --redact --max 100
+
+For full usage information run:
+
+	mirach procinfo --help
+
+Calling via the API
+
+To use this plugin via the API:
+
+	procinfo.String()
+*/
+package procinfo
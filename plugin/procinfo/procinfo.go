@@ -0,0 +1,214 @@
+package procinfo
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/cleardataeng/mirach/plugin"
+
+	"github.com/shirou/gopsutil/net"
+	"github.com/shirou/gopsutil/process"
+)
+
+// DefaultMax is how many processes are listed by default.
+const DefaultMax = 1000
+
+// Socket types, as reported by gopsutil.
+const (
+	sockStream = 1
+	sockDgram  = 2
+)
+
+// ProcInfoGroup lists the running processes. When Users or Names are set, only
+// processes whose user or name matches one of their glob patterns are listed.
+// With RedactArgs, command lines are replaced by the executable, or the name
+// when the executable is unknown, since processes such as sshd and postgres
+// rewrite their arguments into a single string. At most Max
+// processes, in order of pid, are listed; when there are more, Truncated is set
+// and Total is how many matched.
+type ProcInfoGroup struct {
+	Users      []string  `json:"users,omitempty"`
+	Names      []string  `json:"names,omitempty"`
+	RedactArgs bool      `json:"redact_args"`
+	Max        int       `json:"max"`
+	Total      int       `json:"total"`
+	Truncated  bool      `json:"truncated"`
+	Processes  []Process `json:"processes"`
+}
+
+// Process is a running process. CPUPercent is its average use of a CPU since
+// it started, and RSS its resident set size in bytes.
+type Process struct {
+	PID        int32     `json:"pid"`
+	PPID       int32     `json:"ppid"`
+	User       string    `json:"user"`
+	Name       string    `json:"name"`
+	Exe        string    `json:"exe,omitempty"`
+	Cmdline    []string  `json:"cmdline"`
+	Started    time.Time `json:"started"`
+	CPUPercent float64   `json:"cpu_percent"`
+	RSS        uint64    `json:"rss"`
+	Listening  []Socket  `json:"listening,omitempty"`
+}
+
+// Socket is a listening socket: a TCP socket in the LISTEN state or an
+// unconnected UDP socket.
+type Socket struct {
+	Protocol string `json:"protocol"` // tcp, tcp6, udp, or udp6
+	Address  string `json:"address"`
+	Port     uint32 `json:"port"`
+}
+
+// GetInfo lists the running processes and their listening sockets, filtered
+// and capped as configured.
+func (g *ProcInfoGroup) GetInfo() {
+	if g.Max <= 0 {
+		g.Max = DefaultMax
+	}
+	procs, err := process.Processes()
+	if err != nil {
+		panic(err)
+	}
+	listening, err := ListeningSockets()
+	if err != nil {
+		panic(err)
+	}
+	var out []Process
+	for _, p := range procs {
+		proc, ok := inspect(p)
+		if !ok {
+			continue
+		}
+		proc.Listening = listening[proc.PID]
+		out = append(out, proc)
+	}
+	g.apply(out)
+}
+
+// String marshal ProcInfoGroup to string and return
+func (g *ProcInfoGroup) String() string {
+	s, _ := json.Marshal(g)
+	return string(s)
+}
+
+// GetInfo will load up and return the ProcInfoGroup with the defaults.
+func GetInfo() plugin.InfoGroup {
+	g := new(ProcInfoGroup)
+	g.GetInfo()
+	return g
+}
+
+// String will load up and return the ProcInfoGroup with the defaults as a
+// string.
+func String() string {
+	return GetInfo().String()
+}
+
+// inspect returns the details of p, or false if it exited in the meantime.
+// Details that cannot be read, such as the executable of another user's
+// process when not run as root, are left empty.
+func inspect(p *process.Process) (Process, bool) {
+	name, err := p.Name()
+	if err != nil {
+		return Process{}, false
+	}
+	proc := Process{PID: p.Pid, Name: name}
+	proc.PPID, _ = p.Ppid()
+	proc.User, _ = p.Username()
+	proc.Exe, _ = p.Exe()
+	proc.Cmdline, _ = p.CmdlineSlice()
+	if ms, err := p.CreateTime(); err == nil {
+		proc.Started = time.Unix(0, ms*int64(time.Millisecond)).UTC()
+	}
+	proc.CPUPercent, _ = p.CPUPercent()
+	if mem, err := p.MemoryInfo(); err == nil {
+		proc.RSS = mem.RSS
+	}
+	return proc, true
+}
+
+// apply filters, redacts, orders, and caps the processes into g.
+func (g *ProcInfoGroup) apply(procs []Process) {
+	g.Processes = []Process{}
+	for _, p := range procs {
+		if !matchAny(g.Users, p.User) || !matchAny(g.Names, p.Name) {
+			continue
+		}
+		if g.RedactArgs && len(p.Cmdline) > 0 {
+			if p.Exe != "" {
+				p.Cmdline = []string{p.Exe}
+			} else {
+				p.Cmdline = []string{p.Name}
+			}
+		}
+		g.Processes = append(g.Processes, p)
+	}
+	sort.Slice(g.Processes, func(i, j int) bool {
+		return g.Processes[i].PID < g.Processes[j].PID
+	})
+	g.Total = len(g.Processes)
+	g.Truncated = g.Max > 0 && g.Total > g.Max
+	if g.Truncated {
+		g.Processes = g.Processes[:g.Max]
+	}
+}
+
+// matchAny reports whether s matches one of the glob patterns, or true if
+// there are none.
+func matchAny(patterns []string, s string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ListeningSockets returns the listening sockets of each process, keyed by
+// pid. Sockets whose process is unknown, as for other users' processes when
+// not run as root, are keyed by pid 0.
+func ListeningSockets() (map[int32][]Socket, error) {
+	conns, err := net.Connections("inet")
+	if err != nil {
+		return nil, err
+	}
+	return listeningSockets(conns), nil
+}
+
+func listeningSockets(conns []net.ConnectionStat) map[int32][]Socket {
+	out := map[int32][]Socket{}
+	for _, c := range conns {
+		var proto string
+		switch {
+		case c.Type == sockStream && c.Status == "LISTEN":
+			proto = "tcp"
+		case c.Type == sockDgram && c.Raddr.Port == 0:
+			proto = "udp"
+		default:
+			continue
+		}
+		if c.Family == syscall.AF_INET6 {
+			proto += "6"
+		}
+		out[c.Pid] = append(out[c.Pid], Socket{Protocol: proto, Address: c.Laddr.IP, Port: c.Laddr.Port})
+	}
+	for pid := range out {
+		socks := out[pid]
+		sort.Slice(socks, func(i, j int) bool {
+			if socks[i].Port != socks[j].Port {
+				return socks[i].Port < socks[j].Port
+			}
+			if socks[i].Protocol != socks[j].Protocol {
+				return socks[i].Protocol < socks[j].Protocol
+			}
+			return socks[i].Address < socks[j].Address
+		})
+	}
+	return out
+}
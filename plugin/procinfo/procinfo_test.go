@@ -0,0 +1,93 @@
+// +build unit
+
+package procinfo
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/shirou/gopsutil/net"
+	"github.com/shirou/gopsutil/process"
+	"github.com/stretchr/testify/assert"
+)
+
+func testProcs() []Process {
+	return []Process{
+		{PID: 812, User: "www-data", Name: "nginx", Cmdline: []string{"nginx: worker process"}},
+		{PID: 931, User: "postgres", Name: "postgres", Exe: "/usr/lib/postgresql/14/bin/postgres", Cmdline: []string{"postgres: app app_db 10.0.0.5(51234) idle"}},
+		{PID: 1, User: "root", Name: "systemd", Cmdline: []string{"/sbin/init", "splash"}},
+		{PID: 811, User: "root", Name: "nginx", Exe: "/usr/sbin/nginx", Cmdline: []string{"/usr/sbin/nginx", "-g", "daemon on;"}},
+		{PID: 930, User: "postgres", Name: "postgres", Exe: "/usr/lib/postgresql/14/bin/postgres", Cmdline: []string{"/usr/lib/postgresql/14/bin/postgres", "-D", "/var/lib/postgresql/14/main"}},
+	}
+}
+
+func TestApply(t *testing.T) {
+	assert := assert.New(t)
+	g := &ProcInfoGroup{}
+	g.apply(testProcs())
+	assert.Equal(5, g.Total)
+	assert.False(g.Truncated)
+	assert.Equal([]int32{1, 811, 812, 930, 931}, pids(g.Processes))
+	assert.Equal([]string{"/usr/sbin/nginx", "-g", "daemon on;"}, g.Processes[1].Cmdline)
+
+	g = &ProcInfoGroup{Names: []string{"nginx"}, RedactArgs: true}
+	g.apply(testProcs())
+	assert.Equal([]int32{811, 812}, pids(g.Processes))
+	assert.Equal([]string{"/usr/sbin/nginx"}, g.Processes[0].Cmdline)
+	assert.Equal([]string{"nginx"}, g.Processes[1].Cmdline, "name when the executable is unknown")
+
+	g = &ProcInfoGroup{Names: []string{"postgres"}, RedactArgs: true}
+	g.apply(testProcs())
+	for _, p := range g.Processes {
+		assert.Equal([]string{"/usr/lib/postgresql/14/bin/postgres"}, p.Cmdline, "arguments rewritten into argv[0] redacted")
+	}
+
+	g = &ProcInfoGroup{Users: []string{"www-*", "post*"}}
+	g.apply(testProcs())
+	assert.Equal([]int32{812, 930, 931}, pids(g.Processes))
+
+	g = &ProcInfoGroup{Max: 2}
+	g.apply(testProcs())
+	assert.True(g.Truncated)
+	assert.Equal(5, g.Total)
+	assert.Equal([]int32{1, 811}, pids(g.Processes))
+}
+
+func pids(procs []Process) []int32 {
+	var out []int32
+	for _, p := range procs {
+		out = append(out, p.PID)
+	}
+	return out
+}
+
+func TestListeningSockets(t *testing.T) {
+	assert := assert.New(t)
+	conns := []net.ConnectionStat{
+		{Family: syscall.AF_INET6, Type: sockStream, Laddr: net.Addr{IP: "::", Port: 22}, Status: "LISTEN", Pid: 700},
+		{Family: syscall.AF_INET, Type: sockStream, Laddr: net.Addr{IP: "0.0.0.0", Port: 22}, Status: "LISTEN", Pid: 700},
+		{Family: syscall.AF_INET, Type: sockStream, Laddr: net.Addr{IP: "10.0.0.5", Port: 22}, Raddr: net.Addr{IP: "10.0.0.9", Port: 51234}, Status: "ESTABLISHED", Pid: 701},
+		{Family: syscall.AF_INET, Type: sockDgram, Laddr: net.Addr{IP: "127.0.0.53", Port: 53}, Pid: 400},
+		{Family: syscall.AF_INET, Type: sockDgram, Laddr: net.Addr{IP: "10.0.0.5", Port: 40000}, Raddr: net.Addr{IP: "10.0.0.2", Port: 123}, Pid: 401},
+		{Family: syscall.AF_INET, Type: sockStream, Laddr: net.Addr{IP: "0.0.0.0", Port: 5432}, Status: "LISTEN"},
+	}
+	assert.Equal(map[int32][]Socket{
+		700: {{"tcp", "0.0.0.0", 22}, {"tcp6", "::", 22}},
+		400: {{"udp", "127.0.0.53", 53}},
+		0:   {{"tcp", "0.0.0.0", 5432}},
+	}, listeningSockets(conns))
+}
+
+func TestInspect(t *testing.T) {
+	assert := assert.New(t)
+	p, err := process.NewProcess(int32(os.Getpid()))
+	assert.NoError(err)
+	proc, ok := inspect(p)
+	assert.True(ok)
+	assert.Equal(int32(os.Getpid()), proc.PID)
+	assert.Equal(int32(os.Getppid()), proc.PPID)
+	assert.NotEmpty(proc.Cmdline)
+	assert.False(proc.Started.IsZero())
+	assert.NotZero(proc.RSS)
+}
@@ -0,0 +1,44 @@
+/*
+Package systemdinfo is a plugin that provides information about the systemd
+service, timer, and socket units of the asset.
+
+For each unit, loaded or only installed, it reports:
+
+	- the load, active, and sub state, such as loaded, active, and running
+	- the unit file state, such as enabled, disabled, static, or masked
+	- the main PID of a running service
+	- how many times systemd has restarted a service automatically
+	- the result and exit status of the last run, when it was not a success
+	- when the unit last changed state, became active, and became inactive,
+	  and, for a failed unit, when it failed
+
+Units are read with systemctl show, with the time zone set to UTC so that
+timestamps can be parsed, and reported once each by name, aliases such as
+sshd.service being reported as the unit they name. Where systemd is not the
+init system, the plugin reports nothing. The same properties could be read
+from systemd's D-Bus API, but that would need a D-Bus client library, so
+systemctl is used instead. Each run of systemctl is killed after 30 seconds,
+so that a wedged systemd does not hang the plugin.
+
+Failed units are found by polling: FailedString lists them each time it is
+called, and mirach's systemdinfo-events plugin calls it once a minute, so a
+failure is reported up to a minute after it happens.
+
+Calling via the CLI
+
+To run this plugin from the command line interface:
+
+	mirach systemdinfo
+
+To list only the failed units:
+
+	mirach systemdinfo --failed
+
+Calling via the API
+
+To use this plugin via the API:
+
+	systemdinfo.String()
+	systemdinfo.FailedString()
+*/
+package systemdinfo
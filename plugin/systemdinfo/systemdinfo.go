@@ -0,0 +1,301 @@
+package systemdinfo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cleardataeng/mirach/plugin"
+	"github.com/cleardataeng/mirach/util"
+)
+
+// UnitTypes are the types of units reported.
+var UnitTypes = []string{"service", "timer", "socket"}
+
+// Exceptions is a list of strings containing error strings that are expected
+// on some systems and should not be reported as failures.
+var Exceptions = []string{
+	"systemd is not running on this system",
+}
+
+// bootedPath exists when systemd is the init system, as sd_booted checks.
+const bootedPath = "/run/systemd/system"
+
+// systemctlTimeout limits how long each run of systemctl may take, as it waits
+// on systemd, which may be wedged.
+var systemctlTimeout = 30 * time.Second
+
+// showBatch is how many units are shown by each run of systemctl show.
+const showBatch = 100
+
+// timestampLayout is how systemctl show prints timestamps when TZ is UTC.
+const timestampLayout = "Mon 2006-01-02 15:04:05 MST"
+
+// showProperties are the unit properties read by systemctl show.
+var showProperties = []string{
+	"Id",
+	"Description",
+	"LoadState",
+	"ActiveState",
+	"SubState",
+	"UnitFileState",
+	"MainPID",
+	"NRestarts",
+	"Result",
+	"ExecMainStatus",
+	"StateChangeTimestamp",
+	"ActiveEnterTimestamp",
+	"InactiveEnterTimestamp",
+}
+
+// SystemdInfoGroup lists the service, timer, and socket units of systemd.
+type SystemdInfoGroup struct {
+	Units []Unit `json:"units"`
+}
+
+// Unit is the state of a systemd unit. Restarts is the number of automatic
+// restarts of a service, and FailedAt when a unit in the failed state failed.
+// Timestamps that systemd has not recorded are left out.
+type Unit struct {
+	Name          string     `json:"name"`
+	Type          string     `json:"type"`
+	Description   string     `json:"description,omitempty"`
+	LoadState     string     `json:"load_state"`
+	ActiveState   string     `json:"active_state"`
+	SubState      string     `json:"sub_state"`
+	UnitFileState string     `json:"unit_file_state,omitempty"` // enabled, disabled, static, masked, ...
+	MainPID       int        `json:"main_pid,omitempty"`
+	Restarts      int        `json:"restarts,omitempty"`
+	Result        string     `json:"result,omitempty"`
+	ExitStatus    int        `json:"exit_status,omitempty"`
+	StateChanged  *time.Time `json:"state_changed,omitempty"`
+	ActiveSince   *time.Time `json:"active_since,omitempty"`
+	InactiveSince *time.Time `json:"inactive_since,omitempty"`
+	FailedAt      *time.Time `json:"failed_at,omitempty"`
+}
+
+// Failed reports whether the unit is in the failed state.
+func (u Unit) Failed() bool {
+	return u.ActiveState == "failed"
+}
+
+// GetInfo lists every service, timer, and socket unit, loaded or not.
+func (g *SystemdInfoGroup) GetInfo() {
+	checkBooted()
+	types := "--type=" + strings.Join(UnitTypes, ",")
+	loaded, err := systemctl("list-units", "--all", types, "--no-legend", "--plain", "--no-pager")
+	if err != nil {
+		panic(err)
+	}
+	files, err := systemctl("list-unit-files", types, "--no-legend", "--no-pager")
+	if err != nil {
+		panic(err)
+	}
+	g.Units, err = showUnits(unitNames(loaded, files))
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GetFailed lists only the units that are in the failed state.
+func (g *SystemdInfoGroup) GetFailed() {
+	checkBooted()
+	types := "--type=" + strings.Join(UnitTypes, ",")
+	failed, err := systemctl("list-units", "--all", "--state=failed", types, "--no-legend", "--plain", "--no-pager")
+	if err != nil {
+		panic(err)
+	}
+	g.Units, err = showUnits(unitNames(failed))
+	if err != nil {
+		panic(err)
+	}
+}
+
+// String marshal SystemdInfoGroup to string and return
+func (g *SystemdInfoGroup) String() string {
+	s, _ := json.Marshal(g)
+	return string(s)
+}
+
+// GetInfo will load up and return the SystemdInfoGroup.
+func GetInfo() plugin.InfoGroup {
+	g := new(SystemdInfoGroup)
+	g.GetInfo()
+	return g
+}
+
+// String will load up and return the SystemdInfoGroup as a string.
+func String() string {
+	return GetInfo().String()
+}
+
+// FailedString will load up and return a SystemdInfoGroup of only the failed
+// units as a string.
+func FailedString() string {
+	g := new(SystemdInfoGroup)
+	g.GetFailed()
+	return g.String()
+}
+
+func checkBooted() {
+	if ok, _ := util.Exists(bootedPath); !ok {
+		panic(plugin.ExceptionOrError(errors.New("systemd is not running on this system"), Exceptions))
+	}
+}
+
+// systemctl runs systemctl with args, killing it after systemctlTimeout.
+func systemctl(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), systemctlTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "systemctl", args...)
+	// Timestamps are printed in the local time zone; UTC makes them parseable.
+	cmd.Env = append(os.Environ(), "LC_ALL=C", "TZ=UTC")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("systemctl %s: timed out after %s", args[0], systemctlTimeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("systemctl %s: %s: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// unitNames returns the sorted unit names, the first field of each line, of
+// the listings of systemctl list-units and list-unit-files. Template units
+// such as getty@.service are left out, as they can only be shown by instance.
+func unitNames(listings ...[]byte) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, b := range listings {
+		for _, line := range strings.Split(string(b), "\n") {
+			f := strings.Fields(line)
+			// Without --plain, failed units are marked with a leading dot.
+			if len(f) > 0 && (f[0] == "●" || f[0] == "*") {
+				f = f[1:]
+			}
+			if len(f) == 0 || strings.HasSuffix(f[0], "@."+unitType(f[0])) || seen[f[0]] {
+				continue
+			}
+			seen[f[0]] = true
+			names = append(names, f[0])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// showUnits runs systemctl show on the units, in batches.
+func showUnits(names []string) ([]Unit, error) {
+	var units []Unit
+	for len(names) > 0 {
+		n := showBatch
+		if n > len(names) {
+			n = len(names)
+		}
+		args := append([]string{"show", "--no-pager", "--property=" + strings.Join(showProperties, ",")}, names[:n]...)
+		out, err := systemctl(args...)
+		if err != nil {
+			return nil, err
+		}
+		units = append(units, parseShow(out)...)
+		names = names[n:]
+	}
+	return dedupeUnits(units), nil
+}
+
+// dedupeUnits drops the units shown more than once and orders the rest by
+// name. list-unit-files lists aliases, such as sshd.service for ssh.service,
+// which systemctl show resolves to the unit they name.
+func dedupeUnits(units []Unit) []Unit {
+	seen := map[string]bool{}
+	out := []Unit{}
+	for _, u := range units {
+		if seen[u.Name] {
+			continue
+		}
+		seen[u.Name] = true
+		out = append(out, u)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// parseShow parses the output of systemctl show, blocks of property=value
+// lines separated by blank lines, one block per unit. Units systemd knows
+// nothing of are left out.
+func parseShow(b []byte) []Unit {
+	var units []Unit
+	props := map[string]string{}
+	flush := func() {
+		if props["Id"] != "" && props["LoadState"] != "not-found" {
+			units = append(units, newUnit(props))
+		}
+		props = map[string]string{}
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		if i := strings.Index(line, "="); i > 0 {
+			props[line[:i]] = line[i+1:]
+		}
+	}
+	flush()
+	return units
+}
+
+func newUnit(props map[string]string) Unit {
+	u := Unit{
+		Name:          props["Id"],
+		Type:          unitType(props["Id"]),
+		Description:   props["Description"],
+		LoadState:     props["LoadState"],
+		ActiveState:   props["ActiveState"],
+		SubState:      props["SubState"],
+		UnitFileState: props["UnitFileState"],
+		Result:        props["Result"],
+		StateChanged:  parseTimestamp(props["StateChangeTimestamp"]),
+		ActiveSince:   parseTimestamp(props["ActiveEnterTimestamp"]),
+		InactiveSince: parseTimestamp(props["InactiveEnterTimestamp"]),
+	}
+	u.MainPID, _ = strconv.Atoi(props["MainPID"])
+	u.Restarts, _ = strconv.Atoi(props["NRestarts"])
+	u.ExitStatus, _ = strconv.Atoi(props["ExecMainStatus"])
+	if u.Result == "success" {
+		u.Result = ""
+	}
+	if u.Failed() {
+		u.FailedAt = u.StateChanged
+	}
+	return u
+}
+
+// unitType returns the type of a unit from its name, e.g. service.
+func unitType(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[i+1:]
+	}
+	return ""
+}
+
+// parseTimestamp parses a timestamp of systemctl show, or returns nil if it is
+// unset, which is printed as nothing or n/a.
+func parseTimestamp(s string) *time.Time {
+	t, err := time.Parse(timestampLayout, s)
+	if err != nil || t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
@@ -0,0 +1,153 @@
+// +build unit
+
+package systemdinfo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitNames(t *testing.T) {
+	assert := assert.New(t)
+	units := []byte(`cron.service                 loaded    active   running Regular background program processing daemon
+nginx.service                loaded    failed   failed  A high performance web server
+● php-fpm.service            loaded    failed   failed  The PHP FastCGI Process Manager
+getty@tty1.service           loaded    active   running Getty on tty1
+apt-daily.timer              loaded    active   waiting Daily apt download activities
+`)
+	files := []byte(`cron.service                 enabled         enabled
+getty@.service               enabled         enabled
+nginx.service                enabled         enabled
+rescue.service               static          -
+ssh.socket                   disabled        enabled
+apt-daily.timer              enabled         enabled
+`)
+	assert.Equal([]string{
+		"apt-daily.timer",
+		"cron.service",
+		"getty@tty1.service",
+		"nginx.service",
+		"php-fpm.service",
+		"rescue.service",
+		"ssh.socket",
+	}, unitNames(units, files))
+	assert.Empty(unitNames([]byte("\n")))
+}
+
+func TestParseShow(t *testing.T) {
+	assert := assert.New(t)
+	out := []byte(`MainPID=812
+ExecMainStatus=0
+Result=success
+NRestarts=0
+Id=cron.service
+Description=Regular background program processing daemon
+LoadState=loaded
+ActiveState=active
+SubState=running
+UnitFileState=enabled
+StateChangeTimestamp=Thu 2023-06-01 08:00:05 UTC
+ActiveEnterTimestamp=Thu 2023-06-01 08:00:05 UTC
+InactiveEnterTimestamp=
+
+MainPID=0
+ExecMainStatus=1
+Result=exit-code
+NRestarts=5
+Id=nginx.service
+Description=A high performance web server
+LoadState=loaded
+ActiveState=failed
+SubState=failed
+UnitFileState=enabled
+StateChangeTimestamp=Fri 2023-06-02 13:14:15 UTC
+ActiveEnterTimestamp=Thu 2023-06-01 08:00:06 UTC
+InactiveEnterTimestamp=Fri 2023-06-02 13:14:15 UTC
+
+Result=success
+Id=apt-daily.timer
+Description=Daily apt download activities
+LoadState=loaded
+ActiveState=active
+SubState=waiting
+UnitFileState=enabled
+StateChangeTimestamp=n/a
+ActiveEnterTimestamp=n/a
+InactiveEnterTimestamp=n/a
+
+MainPID=0
+Id=gone.service
+LoadState=not-found
+ActiveState=inactive
+SubState=dead
+`)
+	units := parseShow(out)
+	if !assert.Len(units, 3) {
+		return
+	}
+	started := time.Date(2023, 6, 1, 8, 0, 5, 0, time.UTC)
+	assert.Equal(Unit{
+		Name:          "cron.service",
+		Type:          "service",
+		Description:   "Regular background program processing daemon",
+		LoadState:     "loaded",
+		ActiveState:   "active",
+		SubState:      "running",
+		UnitFileState: "enabled",
+		MainPID:       812,
+		StateChanged:  &started,
+		ActiveSince:   &started,
+	}, units[0])
+
+	nginx := units[1]
+	assert.True(nginx.Failed())
+	assert.Equal(5, nginx.Restarts)
+	assert.Equal("exit-code", nginx.Result)
+	assert.Equal(1, nginx.ExitStatus)
+	if assert.NotNil(nginx.FailedAt) {
+		assert.Equal(time.Date(2023, 6, 2, 13, 14, 15, 0, time.UTC), *nginx.FailedAt)
+	}
+
+	timer := units[2]
+	assert.Equal("timer", timer.Type)
+	assert.False(timer.Failed())
+	assert.Nil(timer.StateChanged)
+	assert.Nil(timer.FailedAt)
+}
+
+func TestDedupeUnits(t *testing.T) {
+	assert := assert.New(t)
+	// ssh.service is shown for itself and for its alias, sshd.service, and
+	// dbus.service for dbus-org.freedesktop.login1.service, listed first.
+	units := parseShow([]byte("Id=dbus.service\nLoadState=loaded\n\nId=cron.service\nLoadState=loaded\n\nId=dbus.service\nLoadState=loaded\n\n" +
+		"Id=ssh.service\nLoadState=loaded\n\nId=ssh.service\nLoadState=loaded\n"))
+	var names []string
+	for _, u := range dedupeUnits(units) {
+		names = append(names, u.Name)
+	}
+	assert.Equal([]string{"cron.service", "dbus.service", "ssh.service"}, names)
+	assert.Equal([]Unit{}, dedupeUnits(nil))
+}
+
+func TestSystemctlTimeout(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "systemdinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "systemctl"), []byte("#!/bin/sh\nexec sleep 10\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	defer func(d time.Duration) { systemctlTimeout = d }(systemctlTimeout)
+	systemctlTimeout = 100 * time.Millisecond
+	_, err = systemctl("list-units")
+	assert.EqualError(err, "systemctl list-units: timed out after 100ms")
+}
@@ -0,0 +1,32 @@
+/*
+Package userinfo is a plugin that provides information about the local users
+and groups of the asset and their privileges, for access reviews.
+
+It reports:
+
+	- users from /etc/passwd, with their primary and supplementary groups
+	- groups from /etc/group and their members
+	- the status and aging of each user's password from /etc/shadow: whether
+	  it is set, locked, disabled, or empty, when it was last changed, and
+	  when it expires; password hashes are never reported
+	- the entries of /etc/sudoers and the files it includes, such as those
+	  of /etc/sudoers.d
+	- the SHA256 fingerprint, type, comment, and options of each key in a
+	  user's ~/.ssh/authorized_keys
+
+/etc/shadow and the sudoers files can only be read as root. Files that cannot be
+read, other than missing ones, are listed under errors.
+
+Calling via the CLI
+
+To run this plugin from the command line interface:
+
+	mirach userinfo
+
+Calling via the API
+
+To use this plugin via the API:
+
+	userinfo.String()
+*/
+package userinfo
@@ -0,0 +1,60 @@
+package userinfo
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+)
+
+// keyTypePrefixes begin the types of SSH public keys, such as ssh-ed25519,
+// ecdsa-sha2-nistp256, and sk-ssh-ed25519@openssh.com.
+var keyTypePrefixes = []string{"ssh-", "ecdsa-", "sk-"}
+
+// parseAuthorizedKeys returns the keys of an authorized_keys file. Lines that
+// are not keys are left out.
+func parseAuthorizedKeys(path string, b []byte) []AuthorizedKey {
+	var keys []AuthorizedKey
+	for _, line := range lines(b) {
+		if k, ok := parseAuthorizedKey(line); ok {
+			k.File = path
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// parseAuthorizedKey parses a line of the form
+// [options] keytype base64-key [comment]. Options may hold quoted spaces, so
+// the key is found as the first key type followed by a valid key.
+func parseAuthorizedKey(line string) (AuthorizedKey, bool) {
+	f := strings.Fields(line)
+	for i := 0; i+1 < len(f); i++ {
+		if !isKeyType(f[i]) {
+			continue
+		}
+		blob, err := base64.StdEncoding.DecodeString(f[i+1])
+		if err != nil || len(blob) == 0 {
+			continue
+		}
+		sum := sha256.Sum256(blob)
+		k := AuthorizedKey{
+			Type:        f[i],
+			Fingerprint: "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]),
+			Comment:     strings.Join(f[i+2:], " "),
+		}
+		if i > 0 {
+			k.Options = strings.Join(f[:i], " ")
+		}
+		return k, true
+	}
+	return AuthorizedKey{}, false
+}
+
+func isKeyType(s string) bool {
+	for _, p := range keyTypePrefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package userinfo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/spf13/afero"
+)
+
+// maxSudoersDepth limits how deeply sudoers files may include others.
+const maxSudoersDepth = 8
+
+var sudoAliases = []string{"User_Alias", "Runas_Alias", "Host_Alias", "Cmnd_Alias", "Cmd_Alias"}
+
+// readSudoers adds the entries of a sudoers file, and of the files it
+// includes, to g.
+func (g *UserInfoGroup) readSudoers(path string, depth int) {
+	if depth > maxSudoersDepth {
+		g.Errors = append(g.Errors, path+": too many levels of includes")
+		return
+	}
+	b, ok := g.read(path)
+	if !ok {
+		return
+	}
+	for _, line := range sudoersLines(b) {
+		if dir, ok := sudoersDirective(line, "includedir"); ok {
+			for _, inc := range g.sudoersDir(resolve(path, dir)) {
+				g.readSudoers(inc, depth+1)
+			}
+			continue
+		}
+		if inc, ok := sudoersDirective(line, "include"); ok {
+			g.readSudoers(resolve(path, inc), depth+1)
+			continue
+		}
+		// Lines beginning with # are comments, except for user specifications
+		// by uid such as "#1000 ALL=(ALL) ALL".
+		if strings.HasPrefix(line, "#") && !(len(line) > 1 && line[1] >= '0' && line[1] <= '9') {
+			continue
+		}
+		g.Sudoers = append(g.Sudoers, SudoEntry{File: path, Kind: sudoKind(line), Entry: line})
+	}
+}
+
+// sudoersLines returns the lines of a sudoers file, with lines ending in a
+// backslash joined to the next, leaving out blank lines.
+func sudoersLines(b []byte) []string {
+	var out []string
+	var cont string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasSuffix(line, "\\") {
+			cont += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		line = strings.TrimSpace(cont + line)
+		cont = ""
+		if line != "" {
+			out = append(out, strings.Join(strings.Fields(line), " "))
+		}
+	}
+	if cont = strings.TrimSpace(cont); cont != "" {
+		out = append(out, cont)
+	}
+	return out
+}
+
+// sudoersDirective returns the argument of an #include or #includedir
+// directive, which may also begin with @, if line is that directive.
+func sudoersDirective(line, name string) (string, bool) {
+	for _, prefix := range []string{"#" + name + " ", "@" + name + " "} {
+		if strings.HasPrefix(line, prefix) {
+			return strings.Trim(strings.TrimSpace(line[len(prefix):]), `"`), true
+		}
+	}
+	return "", false
+}
+
+// resolve returns path relative to the directory of the file including it.
+func resolve(from, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(from), path)
+}
+
+// sudoersDir returns the files of an included directory in order. As sudo
+// does, files whose names contain a dot or end in ~ are skipped.
+func (g *UserInfoGroup) sudoersDir(dir string) []string {
+	infos, err := afero.ReadDir(util.Fs, dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			g.Errors = append(g.Errors, err.Error())
+		}
+		return nil
+	}
+	var files []string
+	for _, fi := range infos {
+		name := fi.Name()
+		if fi.IsDir() || strings.Contains(name, ".") || strings.HasSuffix(name, "~") {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	return files
+}
+
+func sudoKind(line string) string {
+	word := strings.Fields(line)[0]
+	if strings.HasPrefix(word, "Defaults") {
+		return "defaults"
+	}
+	for _, a := range sudoAliases {
+		if word == a {
+			return "alias"
+		}
+	}
+	return "rule"
+}
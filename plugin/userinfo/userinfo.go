@@ -0,0 +1,287 @@
+package userinfo
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cleardataeng/mirach/plugin"
+	"github.com/cleardataeng/mirach/util"
+)
+
+// Files read.
+const (
+	PasswdPath  = "/etc/passwd"
+	GroupPath   = "/etc/group"
+	ShadowPath  = "/etc/shadow"
+	SudoersPath = "/etc/sudoers"
+)
+
+// Password statuses.
+const (
+	PasswordSet      = "set"      // a password hash is set
+	PasswordLocked   = "locked"   // the password is locked with a leading !
+	PasswordDisabled = "disabled" // no password can be used to log in, as with *
+	PasswordEmpty    = "empty"    // no password is needed to log in
+)
+
+// UserInfoGroup lists the local users and groups, the sudoers entries, and the
+// keys authorized to log in as each user over SSH. Files that could not be
+// read, other than missing ones, are listed in Errors.
+type UserInfoGroup struct {
+	Users   []User      `json:"users"`
+	Groups  []Group     `json:"groups"`
+	Sudoers []SudoEntry `json:"sudoers"`
+	Errors  []string    `json:"errors,omitempty"`
+}
+
+// User is a local user from /etc/passwd. Groups are the names of its primary
+// and supplementary groups. Password is only known when /etc/shadow can be
+// read.
+type User struct {
+	Name           string          `json:"name"`
+	UID            int             `json:"uid"`
+	GID            int             `json:"gid"`
+	Gecos          string          `json:"gecos,omitempty"`
+	Home           string          `json:"home"`
+	Shell          string          `json:"shell"`
+	Groups         []string        `json:"groups"`
+	Password       *Password       `json:"password,omitempty"`
+	AuthorizedKeys []AuthorizedKey `json:"authorized_keys,omitempty"`
+}
+
+// Password is the status and aging of a user's password from /etc/shadow.
+// The password hash itself is never read into it. Dates are YYYY-MM-DD and
+// ages in days, and are left out when not set.
+type Password struct {
+	Status       string `json:"status"`
+	LastChanged  string `json:"last_changed,omitempty"`
+	MustChange   bool   `json:"must_change,omitempty"` // last changed is 0
+	MinDays      int    `json:"min_days,omitempty"`
+	MaxDays      int    `json:"max_days,omitempty"`
+	WarnDays     int    `json:"warn_days,omitempty"`
+	InactiveDays int    `json:"inactive_days,omitempty"`
+	Expires      string `json:"expires,omitempty"`
+}
+
+// Group is a local group from /etc/group.
+type Group struct {
+	Name    string   `json:"name"`
+	GID     int      `json:"gid"`
+	Members []string `json:"members"`
+}
+
+// SudoEntry is an entry of the sudoers file or a file it includes, with
+// continued lines joined. Kind is defaults, alias, or rule.
+type SudoEntry struct {
+	File  string `json:"file"`
+	Kind  string `json:"kind"`
+	Entry string `json:"entry"`
+}
+
+// AuthorizedKey is a key of a user's ~/.ssh/authorized_keys, identified by
+// its SHA256 fingerprint as printed by ssh-keygen -l.
+type AuthorizedKey struct {
+	File        string `json:"file"`
+	Type        string `json:"type"`
+	Fingerprint string `json:"fingerprint"`
+	Comment     string `json:"comment,omitempty"`
+	Options     string `json:"options,omitempty"`
+}
+
+// GetInfo reads the users, groups, sudoers, and authorized keys.
+func (g *UserInfoGroup) GetInfo() {
+	b, err := util.ReadFile(PasswdPath)
+	if err != nil {
+		panic(err)
+	}
+	g.Users = parsePasswd(b)
+	g.Groups = []Group{}
+	if b, ok := g.read(GroupPath); ok {
+		g.Groups = parseGroup(b)
+	}
+	if b, ok := g.read(ShadowPath); ok {
+		pw := parseShadow(b)
+		for i, u := range g.Users {
+			if p, ok := pw[u.Name]; ok {
+				g.Users[i].Password = &p
+			}
+		}
+	}
+	setGroups(g.Users, g.Groups)
+	g.Sudoers = []SudoEntry{}
+	g.readSudoers(SudoersPath, 0)
+	for i, u := range g.Users {
+		if u.Home == "" || u.Home == "/" {
+			continue
+		}
+		path := filepath.Join(u.Home, ".ssh", "authorized_keys")
+		if b, ok := g.read(path); ok {
+			g.Users[i].AuthorizedKeys = parseAuthorizedKeys(path, b)
+		}
+	}
+}
+
+// String marshal UserInfoGroup to string and return
+func (g *UserInfoGroup) String() string {
+	s, _ := json.Marshal(g)
+	return string(s)
+}
+
+// GetInfo will load up and return the UserInfoGroup.
+func GetInfo() plugin.InfoGroup {
+	g := new(UserInfoGroup)
+	g.GetInfo()
+	return g
+}
+
+// String will load up and return the UserInfoGroup as a string.
+func String() string {
+	return GetInfo().String()
+}
+
+// read returns the contents of a file. Files that do not exist are skipped
+// quietly and other errors recorded.
+func (g *UserInfoGroup) read(path string) ([]byte, bool) {
+	b, err := util.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			g.Errors = append(g.Errors, err.Error())
+		}
+		return nil, false
+	}
+	return b, true
+}
+
+// lines returns the lines of b that are neither blank nor comments.
+func lines(b []byte) []string {
+	var out []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// parsePasswd parses /etc/passwd, leaving out NIS entries such as +::::::.
+func parsePasswd(b []byte) []User {
+	users := []User{}
+	for _, line := range lines(b) {
+		f := strings.Split(line, ":")
+		if len(f) != 7 || strings.HasPrefix(f[0], "+") || strings.HasPrefix(f[0], "-") {
+			continue
+		}
+		uid, err := strconv.Atoi(f[2])
+		if err != nil {
+			continue
+		}
+		gid, _ := strconv.Atoi(f[3])
+		users = append(users, User{
+			Name:   f[0],
+			UID:    uid,
+			GID:    gid,
+			Gecos:  f[4],
+			Home:   f[5],
+			Shell:  f[6],
+			Groups: []string{},
+		})
+	}
+	return users
+}
+
+func parseGroup(b []byte) []Group {
+	groups := []Group{}
+	for _, line := range lines(b) {
+		f := strings.Split(line, ":")
+		if len(f) != 4 || strings.HasPrefix(f[0], "+") || strings.HasPrefix(f[0], "-") {
+			continue
+		}
+		gid, err := strconv.Atoi(f[2])
+		if err != nil {
+			continue
+		}
+		members := []string{}
+		for _, m := range strings.Split(f[3], ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				members = append(members, m)
+			}
+		}
+		groups = append(groups, Group{Name: f[0], GID: gid, Members: members})
+	}
+	return groups
+}
+
+// setGroups sets the names of each user's primary and supplementary groups.
+func setGroups(users []User, groups []Group) {
+	for i, u := range users {
+		names := []string{}
+		for _, g := range groups {
+			if g.GID == u.GID {
+				names = append(names, g.Name)
+				continue
+			}
+			for _, m := range g.Members {
+				if m == u.Name {
+					names = append(names, g.Name)
+					break
+				}
+			}
+		}
+		sort.Strings(names)
+		users[i].Groups = names
+	}
+}
+
+// parseShadow returns the password status and aging of each user in
+// /etc/shadow, by name. The hash is only looked at for its status.
+func parseShadow(b []byte) map[string]Password {
+	pws := map[string]Password{}
+	for _, line := range lines(b) {
+		f := strings.Split(line, ":")
+		if len(f) < 8 {
+			continue
+		}
+		p := Password{Status: passwordStatus(f[1])}
+		if days, err := strconv.Atoi(f[2]); err == nil {
+			if days == 0 {
+				p.MustChange = true
+			} else {
+				p.LastChanged = shadowDate(days)
+			}
+		}
+		p.MinDays, _ = strconv.Atoi(f[3])
+		p.MaxDays, _ = strconv.Atoi(f[4])
+		p.WarnDays, _ = strconv.Atoi(f[5])
+		p.InactiveDays, _ = strconv.Atoi(f[6])
+		if days, err := strconv.Atoi(f[7]); err == nil {
+			p.Expires = shadowDate(days)
+		}
+		pws[f[0]] = p
+	}
+	return pws
+}
+
+func passwordStatus(hash string) string {
+	switch {
+	case hash == "":
+		return PasswordEmpty
+	case strings.HasPrefix(hash, "!"):
+		return PasswordLocked
+	case strings.HasPrefix(hash, "$") || len(hash) == 13:
+		// Crypt hashes begin with $id$, except for DES, which is 13 characters.
+		return PasswordSet
+	}
+	return PasswordDisabled
+}
+
+// shadowDate returns the date days after the epoch, as /etc/shadow counts.
+func shadowDate(days int) string {
+	return time.Unix(int64(days)*24*60*60, 0).UTC().Format("2006-01-02")
+}
@@ -0,0 +1,156 @@
+// +build unit
+
+package userinfo
+
+import (
+	"testing"
+
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+const testKey = "AAAAC3NzaC1lZDI1NTE5AAAAIMz4JhpbjgnDZ4su/kTjTIxVmwXFSPYhYxIsyGDPG6Aq"
+
+func writeTestFiles(t *testing.T) {
+	util.ResetTestFs()
+	util.SetFs(util.TestFs)
+	files := map[string]string{
+		PasswdPath: `root:x:0:0:root:/root:/bin/bash
+daemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin
+alice:x:1000:1000:Alice,,,:/home/alice:/bin/bash
+bob:x:1001:1001::/home/bob:/bin/sh
++::::::
+`,
+		GroupPath: `root:x:0:
+sudo:x:27:alice
+docker:x:998:alice,bob
+alice:x:1000:
+bob:x:1001:
+`,
+		ShadowPath: `root:*:19000:0:99999:7:::
+daemon:*:19000:0:99999:7:::
+alice:$6$salt$hash:19509:1:90:14:30:19723:
+bob:!$6$salt$hash:0:0:99999:7:::
+`,
+		SudoersPath: `# sudoers
+Defaults	env_reset
+Defaults	secure_path="/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin"
+Cmnd_Alias RESTART = /bin/systemctl restart nginx, \
+	/bin/systemctl restart php-fpm
+root	ALL=(ALL:ALL) ALL
+%sudo	ALL=(ALL:ALL) ALL
+#1001 ALL = NOPASSWD: RESTART
+@includedir /etc/sudoers.d
+`,
+		"/etc/sudoers.d/90-cloud-init-users": "# Created by cloud-init\nalice ALL=(ALL) NOPASSWD:ALL\n",
+		"/etc/sudoers.d/README":              "# files in this directory are included\n",
+		"/etc/sudoers.d/old.bak":             "bob ALL=(ALL) ALL\n",
+		"/etc/sudoers.d/editing~":            "bob ALL=(ALL) ALL\n",
+		"/home/alice/.ssh/authorized_keys": "# laptop\nssh-ed25519 " + testKey + " alice@laptop\n" +
+			`from="10.0.0.0/8",command="/usr/bin/rsync --server" ssh-ed25519 ` + testKey + " backup\n" +
+			"not a key\n",
+	}
+	for path, content := range files {
+		if err := afero.WriteFile(util.TestFs, path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGetInfo(t *testing.T) {
+	assert := assert.New(t)
+	writeTestFiles(t)
+	g := new(UserInfoGroup)
+	g.GetInfo()
+	assert.Empty(g.Errors)
+	if !assert.Len(g.Users, 4) {
+		return
+	}
+	alice := g.Users[2]
+	assert.Equal("alice", alice.Name)
+	assert.Equal(1000, alice.UID)
+	assert.Equal("Alice,,,", alice.Gecos)
+	assert.Equal([]string{"alice", "docker", "sudo"}, alice.Groups)
+	assert.Equal(&Password{
+		Status:       PasswordSet,
+		LastChanged:  "2023-06-01",
+		MinDays:      1,
+		MaxDays:      90,
+		WarnDays:     14,
+		InactiveDays: 30,
+		Expires:      "2024-01-01",
+	}, alice.Password)
+	assert.Equal([]AuthorizedKey{
+		{
+			File:        "/home/alice/.ssh/authorized_keys",
+			Type:        "ssh-ed25519",
+			Fingerprint: "SHA256:XU0a9pD0zOSN/MAp0paD+L6IRkiqFCUjRj0306e46hw",
+			Comment:     "alice@laptop",
+		},
+		{
+			File:        "/home/alice/.ssh/authorized_keys",
+			Type:        "ssh-ed25519",
+			Fingerprint: "SHA256:XU0a9pD0zOSN/MAp0paD+L6IRkiqFCUjRj0306e46hw",
+			Comment:     "backup",
+			Options:     `from="10.0.0.0/8",command="/usr/bin/rsync --server"`,
+		},
+	}, alice.AuthorizedKeys)
+
+	bob := g.Users[3]
+	assert.Equal([]string{"bob", "docker"}, bob.Groups)
+	assert.Equal(&Password{Status: PasswordLocked, MustChange: true, MaxDays: 99999, WarnDays: 7}, bob.Password)
+	assert.Empty(bob.AuthorizedKeys)
+	assert.Equal(PasswordDisabled, g.Users[0].Password.Status)
+
+	assert.Equal([]Group{
+		{Name: "root", GID: 0, Members: []string{}},
+		{Name: "sudo", GID: 27, Members: []string{"alice"}},
+		{Name: "docker", GID: 998, Members: []string{"alice", "bob"}},
+		{Name: "alice", GID: 1000, Members: []string{}},
+		{Name: "bob", GID: 1001, Members: []string{}},
+	}, g.Groups)
+
+	assert.Equal([]SudoEntry{
+		{SudoersPath, "defaults", "Defaults env_reset"},
+		{SudoersPath, "defaults", `Defaults secure_path="/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin"`},
+		{SudoersPath, "alias", "Cmnd_Alias RESTART = /bin/systemctl restart nginx, /bin/systemctl restart php-fpm"},
+		{SudoersPath, "rule", "root ALL=(ALL:ALL) ALL"},
+		{SudoersPath, "rule", "%sudo ALL=(ALL:ALL) ALL"},
+		{SudoersPath, "rule", "#1001 ALL = NOPASSWD: RESTART"},
+		{"/etc/sudoers.d/90-cloud-init-users", "rule", "alice ALL=(ALL) NOPASSWD:ALL"},
+	}, g.Sudoers)
+}
+
+func TestGetInfoUnreadable(t *testing.T) {
+	assert := assert.New(t)
+	writeTestFiles(t)
+	util.TestFs.Remove(ShadowPath)
+	util.TestFs.Remove(SudoersPath)
+	g := new(UserInfoGroup)
+	g.GetInfo()
+	assert.Empty(g.Errors, "missing files are not errors")
+	assert.Nil(g.Users[0].Password)
+	assert.Empty(g.Sudoers)
+}
+
+func TestSudoersIncludeLoop(t *testing.T) {
+	assert := assert.New(t)
+	util.ResetTestFs()
+	util.SetFs(util.TestFs)
+	afero.WriteFile(util.TestFs, SudoersPath, []byte("#include sudoers\n"), 0440)
+	g := new(UserInfoGroup)
+	g.readSudoers(SudoersPath, 0)
+	assert.Equal([]string{SudoersPath + ": too many levels of includes"}, g.Errors)
+}
+
+func TestPasswordStatus(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(PasswordSet, passwordStatus("$y$j9T$salt$hash"))
+	assert.Equal(PasswordSet, passwordStatus("abJnggxhB/yWI"))
+	assert.Equal(PasswordLocked, passwordStatus("!$6$salt$hash"))
+	assert.Equal(PasswordLocked, passwordStatus("!!"))
+	assert.Equal(PasswordDisabled, passwordStatus("*"))
+	assert.Equal(PasswordEmpty, passwordStatus(""))
+}
@@ -0,0 +1,39 @@
+/*
+Package vulninfo is a plugin that reports installed packages affected by known
+vulnerabilities.
+
+Installed packages, as listed by pkginfo, are matched against local
+vulnerability feeds in the vulninfo directory of the configuration directories:
+OSV json files, holding one vulnerability or an array of them, and OVAL xml
+definitions such as those published by Red Hat, Debian, Ubuntu, and SUSE.
+Versions are compared as dpkg does on Debian based systems and as rpm does on
+Red Hat and SUSE based systems; other platforms are not supported.
+
+OSV entries apply when their ecosystem, such as Debian:11 or Rocky Linux:8,
+names the running distribution and release. Releases are read as each
+distribution writes them, such as Ubuntu:Pro:18.04:LTS,
+Red Hat:enterprise_linux:9::appstream, or SUSE:Linux Enterprise Server 15 SP5,
+which applies only to 15.5; entries whose release cannot be read are ignored.
+On Debian based systems, feeds name source packages, so each binary package
+built from an affected source package is reported, compared by the version of
+its source package. Of OVAL definitions, only package version tests are
+evaluated; other tests, such as those checking the distribution release or
+signing key, are assumed to pass, so a feed should be chosen for the platform
+it is used on.
+
+Each finding gives the package, its installed version, the advisory or
+vulnerability ID, its CVEs, severity, and the version that fixes it, if any.
+
+Calling via the CLI
+
+To run this plugin from the command line interface:
+
+	mirach vulninfo
+
+Calling via the API
+
+To use this plugin via the API:
+
+	vulninfo.String()
+*/
+package vulninfo
@@ -0,0 +1,280 @@
+package vulninfo
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// osvVuln is a vulnerability in the OSV schema (https://ossf.github.io/osv-schema).
+type osvVuln struct {
+	ID               string                 `json:"id"`
+	Aliases          []string               `json:"aliases"`
+	Upstream         []string               `json:"upstream"`
+	Severity         []osvSeverity          `json:"severity"`
+	Affected         []osvAffected          `json:"affected"`
+	DatabaseSpecific map[string]interface{} `json:"database_specific"`
+}
+
+type osvSeverity struct {
+	Type  string `json:"type"`
+	Score string `json:"score"`
+}
+
+type osvAffected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Severity          []osvSeverity          `json:"severity"`
+	Ranges            []osvRange             `json:"ranges"`
+	Versions          []string               `json:"versions"`
+	EcosystemSpecific map[string]interface{} `json:"ecosystem_specific"`
+	DatabaseSpecific  map[string]interface{} `json:"database_specific"`
+}
+
+type osvRange struct {
+	Type   string     `json:"type"`
+	Events []osvEvent `json:"events"`
+}
+
+type osvEvent struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+}
+
+// osvFeed is a set of OSV vulnerabilities.
+type osvFeed []osvVuln
+
+// osvEcosystem maps an OSV ecosystem to the platform family whose package
+// versions it uses and the platforms it applies to; any platform of the
+// family if none are listed.
+type osvEcosystem struct {
+	family    string
+	platforms []string
+}
+
+var osvEcosystems = map[string]osvEcosystem{
+	"Debian":      {"debian", []string{"debian"}},
+	"Ubuntu":      {"debian", []string{"ubuntu"}},
+	"Red Hat":     {"rhel", []string{"redhat", "centos"}},
+	"Rocky Linux": {"rhel", []string{"rocky"}},
+	"AlmaLinux":   {"rhel", []string{"almalinux"}},
+	"SUSE":        {"suse", nil},
+	"openSUSE":    {"suse", nil},
+}
+
+// parseOSV parses a feed of OSV vulnerabilities: either a single vulnerability
+// or an array of them.
+func parseOSV(b []byte) (osvFeed, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '[' {
+		var f osvFeed
+		err := json.Unmarshal(b, &f)
+		return f, err
+	}
+	var v osvVuln
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return osvFeed{v}, nil
+}
+
+func (f osvFeed) match(inv inventory, p platform) []Finding {
+	cmp := p.compare()
+	if cmp == nil {
+		return nil
+	}
+	var findings []Finding
+	for _, v := range f {
+		for _, a := range v.Affected {
+			if !osvAppliesTo(a.Package.Ecosystem, p) {
+				continue
+			}
+			for _, pkg := range inv[a.Package.Name] {
+				affected, fixed := a.affects(pkg.versionFor(a.Package.Name), cmp)
+				if !affected {
+					continue
+				}
+				findings = append(findings, Finding{
+					Package:      pkg.Name,
+					Version:      pkg.Version,
+					ID:           v.ID,
+					CVEs:         cves(append(append([]string{v.ID}, v.Aliases...), v.Upstream...)...),
+					Severity:     v.severity(a),
+					FixedVersion: fixed,
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// osvAppliesTo reports whether an ecosystem, such as Debian:11 or
+// Ubuntu:22.04:LTS, applies to the platform. A release in the ecosystem must
+// match the platform's version or, except on SUSE, its major version; an
+// ecosystem whose release cannot be parsed applies to no platform.
+func osvAppliesTo(ecosystem string, p platform) bool {
+	parts := strings.Split(ecosystem, ":")
+	e, ok := osvEcosystems[parts[0]]
+	if !ok || e.family != p.Family {
+		return false
+	}
+	if len(e.platforms) > 0 && p.Name != "" {
+		found := false
+		for _, name := range e.platforms {
+			found = found || name == p.Name
+		}
+		if !found {
+			return false
+		}
+	}
+	release, ok := osvRelease(parts)
+	if !ok {
+		return false
+	}
+	if release == "" || p.Version == "" {
+		return true
+	}
+	if e.family == "suse" {
+		// Service packs are releases of their own: 15 is not 15.5.
+		return p.Version == release
+	}
+	return p.Version == release || strings.HasPrefix(p.Version, release+".")
+}
+
+var (
+	osvNumericRE = regexp.MustCompile(`^\d+(\.\d+)*$`)
+	osvSUSERE    = regexp.MustCompile(`\b(\d+)(?:\.(\d+)| SP(\d+))?\b`)
+)
+
+// osvRelease returns the release of a split ecosystem as a version such as
+// 22.04, 9, or 15.5, or an empty string if it gives none. It reports false
+// if a release is given but cannot be parsed. Ubuntu releases may follow
+// qualifiers such as Pro, Red Hat's are CPE-like, as in
+// enterprise_linux:9::appstream, and SUSE's are product names, as in
+// Linux Enterprise Server 15 SP5.
+func osvRelease(parts []string) (string, bool) {
+	if len(parts) < 2 || parts[1] == "" {
+		return "", true
+	}
+	switch parts[0] {
+	case "SUSE", "openSUSE":
+		m := osvSUSERE.FindStringSubmatch(parts[1])
+		if m == nil {
+			return "", false
+		}
+		switch {
+		case m[2] != "":
+			return m[1] + "." + m[2], true
+		case m[3] != "":
+			return m[1] + "." + m[3], true
+		}
+		return m[1], true
+	case "Ubuntu", "Red Hat":
+		for _, part := range parts[1:] {
+			if osvNumericRE.MatchString(part) {
+				return part, true
+			}
+		}
+		return "", false
+	}
+	if osvNumericRE.MatchString(parts[1]) {
+		return parts[1], true
+	}
+	return "", false
+}
+
+// affects reports whether the installed version is affected, and the version,
+// if any, that fixes it.
+func (a osvAffected) affects(installed string, cmp func(a, b string) int) (bool, string) {
+	for _, v := range a.Versions {
+		if cmp(installed, v) == 0 {
+			return true, a.fixedAfter(installed, cmp)
+		}
+	}
+	for _, r := range a.Ranges {
+		if r.Type != "ECOSYSTEM" {
+			continue
+		}
+		if r.affects(installed, cmp) {
+			return true, a.fixedAfter(installed, cmp)
+		}
+	}
+	return false, ""
+}
+
+// affects evaluates the range's events in version order: an introduced event
+// at or before the installed version starts an affected span, which a fixed
+// event at or before it, or a last_affected event before it, ends.
+func (r osvRange) affects(installed string, cmp func(a, b string) int) bool {
+	events := append([]osvEvent(nil), r.Events...)
+	sort.SliceStable(events, func(i, j int) bool {
+		return cmp(events[i].version(), events[j].version()) < 0
+	})
+	affected := false
+	for _, e := range events {
+		switch {
+		case e.Introduced != "":
+			if e.Introduced == "0" || cmp(installed, e.Introduced) >= 0 {
+				affected = true
+			}
+		case e.Fixed != "":
+			if cmp(installed, e.Fixed) >= 0 {
+				affected = false
+			}
+		case e.LastAffected != "":
+			if cmp(installed, e.LastAffected) > 0 {
+				affected = false
+			}
+		}
+	}
+	return affected
+}
+
+func (e osvEvent) version() string {
+	switch {
+	case e.Introduced != "":
+		return e.Introduced
+	case e.Fixed != "":
+		return e.Fixed
+	}
+	return e.LastAffected
+}
+
+// fixedAfter returns the lowest fixed version newer than the installed one.
+func (a osvAffected) fixedAfter(installed string, cmp func(a, b string) int) string {
+	fixed := ""
+	for _, r := range a.Ranges {
+		for _, e := range r.Events {
+			if e.Fixed == "" || cmp(e.Fixed, installed) <= 0 {
+				continue
+			}
+			if fixed == "" || cmp(e.Fixed, fixed) < 0 {
+				fixed = e.Fixed
+			}
+		}
+	}
+	return fixed
+}
+
+// severity returns the distribution's severity of the vulnerability, such as
+// Debian's urgency, if given, or else its first severity score.
+func (v osvVuln) severity(a osvAffected) string {
+	for _, m := range []map[string]interface{}{a.EcosystemSpecific, a.DatabaseSpecific, v.DatabaseSpecific} {
+		for _, k := range []string{"severity", "urgency"} {
+			if s, ok := m[k].(string); ok && s != "" {
+				return s
+			}
+		}
+	}
+	for _, s := range append(a.Severity, v.Severity...) {
+		if s.Score != "" {
+			return s.Score
+		}
+	}
+	return ""
+}
@@ -0,0 +1,268 @@
+package vulninfo
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// ovalFeed is an OVAL definitions document as published by Red Hat, Debian,
+// Ubuntu, and SUSE. Only package version tests (rpminfo and dpkginfo tests
+// with an evr state) are evaluated; other tests, such as those checking the
+// release of the platform, are assumed to pass, as a feed is chosen for its
+// platform.
+type ovalFeed struct {
+	Definitions []ovalDefinition `xml:"definitions>definition"`
+	Tests       ovalElements     `xml:"tests"`
+	Objects     ovalElements     `xml:"objects"`
+	States      ovalElements     `xml:"states"`
+	Variables   struct {
+		Items []ovalVariable `xml:",any"`
+	} `xml:"variables"`
+
+	tests     map[string]ovalElement
+	objects   map[string]ovalElement
+	states    map[string]ovalElement
+	variables map[string][]string
+}
+
+type ovalDefinition struct {
+	ID       string        `xml:"id,attr"`
+	Title    string        `xml:"metadata>title"`
+	Refs     []ovalRef     `xml:"metadata>reference"`
+	Severity string        `xml:"metadata>advisory>severity"`
+	CVEs     []ovalCVE     `xml:"metadata>advisory>cve"`
+	Criteria *ovalCriteria `xml:"criteria"`
+}
+
+type ovalRef struct {
+	Source string `xml:"source,attr"`
+	ID     string `xml:"ref_id,attr"`
+}
+
+type ovalCVE struct {
+	ID string `xml:",chardata"`
+}
+
+type ovalCriteria struct {
+	Operator  string          `xml:"operator,attr"`
+	Negate    bool            `xml:"negate,attr"`
+	Criteria  []ovalCriteria  `xml:"criteria"`
+	Criterion []ovalCriterion `xml:"criterion"`
+}
+
+type ovalCriterion struct {
+	TestRef string `xml:"test_ref,attr"`
+	Negate  bool   `xml:"negate,attr"`
+}
+
+// ovalElement is a test, object, or state; which fields are set depends on
+// its kind.
+type ovalElement struct {
+	XMLName xml.Name
+	ID      string `xml:"id,attr"`
+	Object  struct {
+		Ref string `xml:"object_ref,attr"`
+	} `xml:"object"`
+	State struct {
+		Ref string `xml:"state_ref,attr"`
+	} `xml:"state"`
+	Name struct {
+		Value  string `xml:",chardata"`
+		VarRef string `xml:"var_ref,attr"`
+	} `xml:"name"`
+	EVR struct {
+		Value     string `xml:",chardata"`
+		Operation string `xml:"operation,attr"`
+	} `xml:"evr"`
+}
+
+type ovalElements struct {
+	Items []ovalElement `xml:",any"`
+}
+
+type ovalVariable struct {
+	ID     string   `xml:"id,attr"`
+	Values []string `xml:"value"`
+}
+
+// parseOVAL parses an OVAL definitions document.
+func parseOVAL(b []byte) (*ovalFeed, error) {
+	f := new(ovalFeed)
+	if err := xml.Unmarshal(b, f); err != nil {
+		return nil, err
+	}
+	f.tests = indexOVAL(f.Tests.Items)
+	f.objects = indexOVAL(f.Objects.Items)
+	f.states = indexOVAL(f.States.Items)
+	f.variables = map[string][]string{}
+	for _, v := range f.Variables.Items {
+		f.variables[v.ID] = v.Values
+	}
+	return f, nil
+}
+
+func indexOVAL(elems []ovalElement) map[string]ovalElement {
+	m := make(map[string]ovalElement, len(elems))
+	for _, e := range elems {
+		m[e.ID] = e
+	}
+	return m
+}
+
+func (f *ovalFeed) match(inv inventory, p platform) []Finding {
+	cmp := p.compare()
+	if cmp == nil {
+		return nil
+	}
+	var findings []Finding
+	for _, d := range f.Definitions {
+		if d.Criteria == nil {
+			continue
+		}
+		var matched []Finding
+		if !f.eval(*d.Criteria, inv, cmp, &matched) {
+			continue
+		}
+		id, ids := d.ids()
+		for _, m := range matched {
+			m.ID, m.CVEs, m.Severity = id, cves(ids...), d.Severity
+			findings = append(findings, m)
+		}
+	}
+	return findings
+}
+
+// ids returns the definition's advisory ID, such as RHSA-2023:0001 or
+// USN-6000-1, falling back to its first CVE or its own ID, and all IDs it
+// references.
+func (d ovalDefinition) ids() (string, []string) {
+	var advisory, cve string
+	var ids []string
+	for _, r := range d.Refs {
+		ids = append(ids, r.ID)
+		switch {
+		case r.Source == "CVE" || strings.HasPrefix(r.ID, "CVE-"):
+			if cve == "" {
+				cve = r.ID
+			}
+		case advisory == "":
+			advisory = r.ID
+		}
+	}
+	for _, c := range d.CVEs {
+		ids = append(ids, strings.TrimSpace(c.ID))
+	}
+	switch {
+	case advisory != "":
+		return advisory, ids
+	case cve != "":
+		return cve, ids
+	}
+	return d.ID, ids
+}
+
+// eval evaluates criteria, appending to matched the installed packages that
+// failed version tests that contributed to a true result.
+func (f *ovalFeed) eval(c ovalCriteria, inv inventory, cmp func(a, b string) int, matched *[]Finding) bool {
+	or := c.Operator == "OR"
+	result := !or
+	var found []Finding
+	for _, sub := range c.Criteria {
+		var m []Finding
+		r := f.eval(sub, inv, cmp, &m)
+		if r {
+			found = append(found, m...)
+		}
+		result = combine(result, r, or)
+	}
+	for _, cr := range c.Criterion {
+		m, r := f.test(cr.TestRef, inv, cmp)
+		if cr.Negate {
+			r, m = !r, nil
+		}
+		if r {
+			found = append(found, m...)
+		}
+		result = combine(result, r, or)
+	}
+	if c.Negate {
+		return !result
+	}
+	if result {
+		*matched = append(*matched, found...)
+	}
+	return result
+}
+
+func combine(acc, r, or bool) bool {
+	if or {
+		return acc || r
+	}
+	return acc && r
+}
+
+// test evaluates the test with the given ID. A package test without a state
+// checks that the package is installed; with an evr state it checks the
+// installed version, returning the matching packages with the state's version
+// as their fixed version if the operation is "less than".
+func (f *ovalFeed) test(id string, inv inventory, cmp func(a, b string) int) ([]Finding, bool) {
+	t, ok := f.tests[id]
+	if !ok {
+		return nil, true
+	}
+	kind := t.XMLName.Local
+	if kind != "rpminfo_test" && kind != "dpkginfo_test" {
+		return nil, true
+	}
+	obj := f.objects[t.Object.Ref]
+	names := []string{strings.TrimSpace(obj.Name.Value)}
+	if obj.Name.VarRef != "" {
+		names = f.variables[obj.Name.VarRef]
+	}
+	var state *ovalElement
+	if t.State.Ref != "" {
+		s, ok := f.states[t.State.Ref]
+		if !ok || s.EVR.Value == "" {
+			return nil, true
+		}
+		state = &s
+	}
+	var found []Finding
+	result := false
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		for _, pkg := range inv[name] {
+			if state != nil && !evrMatches(pkg.versionFor(name), *state, cmp) {
+				continue
+			}
+			result = true
+			if state != nil && state.EVR.Operation == "less than" {
+				found = append(found, Finding{
+					Package:      pkg.Name,
+					Version:      pkg.Version,
+					FixedVersion: strings.TrimSpace(state.EVR.Value),
+				})
+			}
+		}
+	}
+	return found, result
+}
+
+// evrMatches reports whether the installed version satisfies the state's evr
+// operation.
+func evrMatches(installed string, s ovalElement, cmp func(a, b string) int) bool {
+	c := cmp(installed, strings.TrimSpace(s.EVR.Value))
+	switch s.EVR.Operation {
+	case "less than":
+		return c < 0
+	case "less than or equal":
+		return c <= 0
+	case "greater than":
+		return c > 0
+	case "greater than or equal":
+		return c >= 0
+	case "not equal":
+		return c != 0
+	}
+	return c == 0
+}
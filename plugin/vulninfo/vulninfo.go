@@ -0,0 +1,237 @@
+package vulninfo
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cleardataeng/mirach/plugin"
+	"github.com/cleardataeng/mirach/plugin/pkginfo/parsers"
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/shirou/gopsutil/host"
+	"github.com/spf13/afero"
+	jww "github.com/spf13/jwalterweatherman"
+)
+
+// FeedDir is the directory, under each of the configuration directories, from
+// which vulnerability feeds are read.
+const FeedDir = "vulninfo"
+
+// Exceptions is a list of strings containing error strings that are expected
+// on some systems and should not be reported as failures.
+var Exceptions = []string{
+	"no vulnerability feeds found",
+	"vulnerability matching is not supported on this platform",
+}
+
+// VulnInfoGroup lists the installed packages affected by the vulnerabilities
+// in the local feeds.
+type VulnInfoGroup struct {
+	OS       string    `json:"os"`
+	Feeds    []string  `json:"feeds"`
+	Findings []Finding `json:"findings"`
+}
+
+// Finding is an installed package affected by a vulnerability.
+type Finding struct {
+	Package      string   `json:"package"`
+	Version      string   `json:"version"` // installed version
+	ID           string   `json:"id"`      // advisory or vulnerability ID
+	CVEs         []string `json:"cves,omitempty"`
+	Severity     string   `json:"severity,omitempty"`
+	FixedVersion string   `json:"fixed_version,omitempty"` // empty if no fix is known
+	Feed         string   `json:"feed"`
+}
+
+// platform identifies the running system, to choose the entries of a feed that
+// apply to it and how its package versions compare.
+type platform struct {
+	Family  string // pkginfo's platform family, e.g. debian or rhel
+	Name    string // e.g. ubuntu or centos
+	Version string // e.g. 22.04 or 8.9
+}
+
+// compare returns the version comparison for the platform's package manager,
+// or nil if there is none.
+func (p platform) compare() func(a, b string) int {
+	switch p.Family {
+	case "debian":
+		return parsers.CompareDebVersions
+	case "rhel", "suse":
+		return parsers.CompareRPMVersions
+	}
+	return nil
+}
+
+// inventory indexes installed packages by name and, on Debian based systems,
+// by source package, since feeds for Debian name source packages and give
+// their versions.
+type inventory map[string][]installedPkg
+
+// installedPkg is an installed package and its name.
+type installedPkg struct {
+	Name string
+	parsers.LinuxPackage
+}
+
+// versionFor returns the version of the package to compare against a feed's
+// entry for the named package: that of its source package if it was indexed
+// by source.
+func (p installedPkg) versionFor(name string) string {
+	if name == p.Source && name != p.Name && p.SourceVersion != "" {
+		return p.SourceVersion
+	}
+	return p.Version
+}
+
+func newInventory(pkgs map[string]parsers.LinuxPackage, family string) inventory {
+	inv := inventory{}
+	keys := make([]string, 0, len(pkgs))
+	for k := range pkgs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := installedPkg{pkgs[k].Name(), pkgs[k]}
+		inv[p.Name] = append(inv[p.Name], p)
+		if family == "debian" && p.Source != "" && p.Source != p.Name {
+			inv[p.Source] = append(inv[p.Source], p)
+		}
+	}
+	return inv
+}
+
+// feed is a source of vulnerabilities that can be matched against installed
+// packages.
+type feed interface {
+	match(inv inventory, p platform) []Finding
+}
+
+// GetInfo matches the installed packages against the feeds found in the
+// vulninfo directory of the configuration directories.
+func (v *VulnInfoGroup) GetInfo() {
+	p, err := getPlatform()
+	if err != nil {
+		panic(err)
+	}
+	v.OS = p.Family
+	if p.compare() == nil {
+		panic(plugin.ExceptionOrError(errors.New("vulnerability matching is not supported on this platform"), Exceptions))
+	}
+	paths, err := feedPaths()
+	if err != nil {
+		panic(err)
+	}
+	if len(paths) == 0 {
+		panic(plugin.ExceptionOrError(errors.New("no vulnerability feeds found"), Exceptions))
+	}
+	pkgs, err := parsers.GetInstalledPkgs(p.Family)
+	if err != nil {
+		panic(err)
+	}
+	v.Feeds = []string{}
+	v.Findings = []Finding{}
+	inv := newInventory(pkgs, p.Family)
+	for _, path := range paths {
+		f, err := loadFeed(path)
+		if err != nil {
+			jww.ERROR.Printf("vulninfo: skipping feed %s: %s", path, err)
+			continue
+		}
+		v.Feeds = append(v.Feeds, path)
+		for _, finding := range f.match(inv, p) {
+			finding.Feed = filepath.Base(path)
+			v.Findings = append(v.Findings, finding)
+		}
+	}
+	sortFindings(v.Findings)
+}
+
+// String marshal VulnInfoGroup to string and return
+func (v *VulnInfoGroup) String() string {
+	s, _ := json.Marshal(v)
+	return string(s)
+}
+
+// GetInfo will load up and return the VulnInfoGroup.
+func GetInfo() plugin.InfoGroup {
+	v := new(VulnInfoGroup)
+	v.GetInfo()
+	return v
+}
+
+// String will load up and return the VulnInfoGroup as a string.
+func String() string {
+	return GetInfo().String()
+}
+
+func getPlatform() (platform, error) {
+	h, err := host.Info()
+	if err != nil {
+		return platform{}, err
+	}
+	return platform{Family: h.PlatformFamily, Name: h.Platform, Version: h.PlatformVersion}, nil
+}
+
+// feedPaths returns the OSV (*.json) and OVAL (*.xml) feeds in the vulninfo
+// directory of each configuration directory.
+func feedPaths() ([]string, error) {
+	dirs, err := util.GetConfDirs()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, dir := range dirs {
+		for _, pattern := range []string{"*.json", "*.xml"} {
+			matches, err := afero.Glob(util.Fs, filepath.Join(dir, FeedDir, pattern))
+			if err != nil {
+				return nil, err
+			}
+			sort.Strings(matches)
+			paths = append(paths, matches...)
+		}
+	}
+	return paths, nil
+}
+
+// loadFeed reads the OSV or OVAL feed at path, according to its extension.
+func loadFeed(path string) (feed, error) {
+	b, err := util.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(path, ".xml") {
+		return parseOVAL(b)
+	}
+	return parseOSV(b)
+}
+
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Version < b.Version
+	})
+}
+
+// cves returns the CVE IDs among ids, sorted and without duplicates.
+func cves(ids ...string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, id := range ids {
+		if strings.HasPrefix(id, "CVE-") && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
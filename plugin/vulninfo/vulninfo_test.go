@@ -0,0 +1,180 @@
+// +build unit
+
+package vulninfo
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/cleardataeng/mirach/plugin/pkginfo/parsers"
+	"github.com/cleardataeng/mirach/util"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+)
+
+func testInventory(family string, pkgs ...installedPkg) inventory {
+	inv := inventory{}
+	for _, p := range pkgs {
+		inv[p.Name] = append(inv[p.Name], p)
+		if family == "debian" && p.Source != "" && p.Source != p.Name {
+			inv[p.Source] = append(inv[p.Source], p)
+		}
+	}
+	return inv
+}
+
+func testFeed(t *testing.T, name string) feed {
+	f, err := loadFeed(filepath.Join("../../test_resources/vulninfo", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestMatchOSV(t *testing.T) {
+	assert := assert.New(t)
+	util.SetFs(util.OSFs)
+	inv := testInventory("debian",
+		installedPkg{"openssl", parsers.LinuxPackage{Version: "1.1.1n-0+deb11u4", Source: "openssl"}},
+		installedPkg{"libssl1.1", parsers.LinuxPackage{Version: "1.1.1n-0+deb11u4", Source: "openssl"}},
+		installedPkg{"libc6", parsers.LinuxPackage{Version: "2.31-13+deb11u5", Source: "glibc"}},
+		installedPkg{"zlib1g", parsers.LinuxPackage{Version: "1:1.2.11.dfsg-2+deb11u2", Source: "zlib"}},
+		installedPkg{"bash", parsers.LinuxPackage{Version: "5.0-4", Source: "bash"}},
+	)
+	findings := testFeed(t, "debian.json").match(inv, platform{"debian", "debian", "11.7"})
+	sortFindings(findings)
+	assert.Equal([]Finding{
+		{
+			Package:      "bash",
+			Version:      "5.0-4",
+			ID:           "CVE-2022-1111",
+			CVEs:         []string{"CVE-2022-1111"},
+			FixedVersion: "5.1-4",
+		},
+		{
+			Package:      "libssl1.1",
+			Version:      "1.1.1n-0+deb11u4",
+			ID:           "DSA-5417-1",
+			CVEs:         []string{"CVE-2023-0464", "CVE-2023-2650"},
+			Severity:     "high",
+			FixedVersion: "1.1.1n-0+deb11u5",
+		},
+		{
+			Package:      "openssl",
+			Version:      "1.1.1n-0+deb11u4",
+			ID:           "DSA-5417-1",
+			CVEs:         []string{"CVE-2023-0464", "CVE-2023-2650"},
+			Severity:     "high",
+			FixedVersion: "1.1.1n-0+deb11u5",
+		},
+		{
+			Package:  "zlib1g",
+			Version:  "1:1.2.11.dfsg-2+deb11u2",
+			ID:       "CVE-2023-9999",
+			CVEs:     []string{"CVE-2023-9999"},
+			Severity: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H",
+		},
+	}, findings)
+
+	findings = testFeed(t, "debian.json").match(inv, platform{"debian", "ubuntu", "22.04"})
+	assert.Len(findings, 2, "only the Ubuntu entry applies to Ubuntu")
+	for _, f := range findings {
+		assert.Equal("UBUNTU-CVE-2023-2650", f.ID)
+	}
+	assert.Empty(testFeed(t, "debian.json").match(inv, platform{"rhel", "centos", "8.9"}))
+}
+
+func TestMatchOSVSourceVersion(t *testing.T) {
+	assert := assert.New(t)
+	util.SetFs(util.OSFs)
+	// A binNMU: the binary version is newer than the fixed source version, but
+	// it was rebuilt from the affected source.
+	inv := testInventory("debian",
+		installedPkg{"libssl1.1", parsers.LinuxPackage{Version: "1.1.1n-0+deb11u5+b1", Source: "openssl", SourceVersion: "1.1.1n-0+deb11u4"}},
+	)
+	findings := testFeed(t, "debian.json").match(inv, platform{"debian", "debian", "11.7"})
+	if assert.Len(findings, 1) {
+		assert.Equal("libssl1.1", findings[0].Package)
+		assert.Equal("1.1.1n-0+deb11u5+b1", findings[0].Version, "installed version reported")
+		assert.Equal("1.1.1n-0+deb11u5", findings[0].FixedVersion)
+	}
+}
+
+func TestOSVAppliesTo(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(osvAppliesTo("Debian:11", platform{"debian", "debian", "11.7"}))
+	assert.True(osvAppliesTo("Debian", platform{"debian", "debian", "11.7"}))
+	assert.False(osvAppliesTo("Debian:1", platform{"debian", "debian", "11.7"}))
+	assert.True(osvAppliesTo("Ubuntu:22.04:LTS", platform{"debian", "ubuntu", "22.04"}))
+	assert.False(osvAppliesTo("Ubuntu:20.04:LTS", platform{"debian", "ubuntu", "22.04"}))
+	assert.True(osvAppliesTo("Ubuntu:Pro:18.04:LTS", platform{"debian", "ubuntu", "18.04"}))
+	assert.False(osvAppliesTo("Ubuntu:Pro:18.04:LTS", platform{"debian", "ubuntu", "22.04"}))
+	assert.False(osvAppliesTo("Ubuntu:Pro:LTS", platform{"debian", "ubuntu", "22.04"}), "release given but not parsed")
+	assert.True(osvAppliesTo("Rocky Linux:8", platform{"rhel", "rocky", "8.9"}))
+	assert.False(osvAppliesTo("AlmaLinux:8", platform{"rhel", "rocky", "8.9"}))
+	assert.True(osvAppliesTo("Red Hat:enterprise_linux:9::appstream", platform{"rhel", "redhat", "9.2"}))
+	assert.False(osvAppliesTo("Red Hat:enterprise_linux:9::appstream", platform{"rhel", "redhat", "8.9"}))
+	assert.True(osvAppliesTo("SUSE:Linux Enterprise Server 15 SP5", platform{"suse", "sles", "15.5"}))
+	assert.False(osvAppliesTo("SUSE:Linux Enterprise Server 15 SP5", platform{"suse", "sles", "12.5"}))
+	assert.False(osvAppliesTo("SUSE:Linux Enterprise Server 15", platform{"suse", "sles", "15.5"}))
+	assert.True(osvAppliesTo("openSUSE:Leap 15.5", platform{"suse", "opensuse-leap", "15.5"}))
+	assert.False(osvAppliesTo("Alpine:v3.18", platform{"alpine", "alpine", "3.18.4"}))
+	assert.False(osvAppliesTo("PyPI", platform{"debian", "debian", "11.7"}))
+}
+
+func TestMatchOVALRedHat(t *testing.T) {
+	assert := assert.New(t)
+	util.SetFs(util.OSFs)
+	inv := testInventory("rhel",
+		installedPkg{"openssl", parsers.LinuxPackage{Version: "1:1.1.1k-7.el8_6"}},
+		installedPkg{"openssl-libs", parsers.LinuxPackage{Version: "1:1.1.1k-9.el8_7"}},
+	)
+	findings := testFeed(t, "rhel8.xml").match(inv, platform{"rhel", "redhat", "8.7"})
+	assert.Equal([]Finding{{
+		Package:      "openssl",
+		Version:      "1:1.1.1k-7.el8_6",
+		ID:           "RHSA-2023:1405",
+		CVEs:         []string{"CVE-2023-0215", "CVE-2023-0286"},
+		Severity:     "Important",
+		FixedVersion: "1:1.1.1k-9.el8_7",
+	}}, findings)
+}
+
+func TestMatchOVALUbuntu(t *testing.T) {
+	assert := assert.New(t)
+	util.SetFs(util.OSFs)
+	inv := testInventory("debian",
+		installedPkg{"libfoo1", parsers.LinuxPackage{Version: "1.2-2", Source: "foo"}},
+	)
+	findings := testFeed(t, "jammy.xml").match(inv, platform{"debian", "ubuntu", "22.04"})
+	assert.Equal([]Finding{{
+		Package:      "libfoo1",
+		Version:      "1.2-2",
+		ID:           "CVE-2023-0001",
+		CVEs:         []string{"CVE-2023-0001"},
+		Severity:     "Medium",
+		FixedVersion: "0:1.2-3ubuntu0.1",
+	}}, findings)
+
+	inv = testInventory("debian",
+		installedPkg{"libfoo1", parsers.LinuxPackage{Version: "1.2-3ubuntu0.1", Source: "foo"}},
+	)
+	assert.Empty(testFeed(t, "jammy.xml").match(inv, platform{"debian", "ubuntu", "22.04"}))
+}
+
+func TestFeedPaths(t *testing.T) {
+	assert := assert.New(t)
+	util.ResetTestFs()
+	util.SetFs(util.TestFs)
+	for _, name := range []string{"b.json", "a.json", "oval.xml", "notes.txt"} {
+		assert.NoError(afero.WriteFile(util.TestFs, filepath.Join("/etc/mirach", FeedDir, name), []byte("[]"), 0644))
+	}
+	paths, err := feedPaths()
+	assert.NoError(err)
+	assert.Equal([]string{
+		"/etc/mirach/vulninfo/a.json",
+		"/etc/mirach/vulninfo/b.json",
+		"/etc/mirach/vulninfo/oval.xml",
+	}, paths)
+}
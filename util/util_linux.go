@@ -1,6 +1,9 @@
 package util
 
 import (
+	"io/ioutil"
+	"net"
+	"os"
 	"path/filepath"
 
 	homedir "github.com/mitchellh/go-homedir"
@@ -18,3 +21,48 @@ func GetConfDirs() ([]string, error) {
 	dirs = append(dirs, ".", user, sys)
 	return dirs, nil
 }
+
+// ControlSocket returns the default path of the daemon's control socket.
+func ControlSocket() string {
+	return "/var/run/mirach.sock"
+}
+
+// ListenPrivate listens on a Unix domain socket at path that only the current
+// user can connect to. The socket is created in a private directory, made
+// owner-only, and only then moved to path, so that others never have a chance
+// to connect. The process umask, shared by every goroutine, is left alone.
+func ListenPrivate(path string) (net.Listener, error) {
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".mirach-sock-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "sock")
+	l, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	// The socket is removed under its final path when closed instead.
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return &privateListener{Listener: l, path: path}, nil
+}
+
+// privateListener removes its socket when closed.
+type privateListener struct {
+	net.Listener
+	path string
+}
+
+func (l *privateListener) Close() error {
+	err := l.Listener.Close()
+	os.Remove(l.path)
+	return err
+}
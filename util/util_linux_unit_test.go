@@ -0,0 +1,40 @@
+// +build unit
+
+package util
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenPrivate(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "mirach-listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mirach.sock")
+	l, err := ListenPrivate(path)
+	if !assert.NoError(err) {
+		return
+	}
+	fi, err := os.Stat(path)
+	if assert.NoError(err) {
+		assert.Equal(os.ModeSocket|0600, fi.Mode())
+	}
+	conn, err := net.Dial("unix", path)
+	if assert.NoError(err) {
+		conn.Close()
+	}
+	entries, _ := ioutil.ReadDir(dir)
+	assert.Len(entries, 1, "private directory removed")
+	assert.NoError(l.Close())
+	_, err = os.Stat(path)
+	assert.True(os.IsNotExist(err), "socket removed on close")
+}
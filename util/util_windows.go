@@ -1,6 +1,7 @@
 package util
 
 import (
+	"net"
 	"os"
 	"path/filepath"
 )
@@ -13,3 +14,15 @@ func GetConfDirs() ([]string, error) {
 	dirs = append(dirs, ".", user, sys)
 	return dirs, nil
 }
+
+// ControlSocket returns the default path of the daemon's control socket.
+func ControlSocket() string {
+	return filepath.Join(os.Getenv("ProgramData"), "mirach", "control.sock")
+}
+
+// ListenPrivate listens on a Unix domain socket at path. Who can connect is
+// governed by the ACL of the directory it is created in, which for the default
+// path is limited to administrators.
+func ListenPrivate(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}